@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 就绪检查中单个依赖的超时时间
+const readyCheckTimeout = 3 * time.Second
+
+// Component 单个组件的检查结果
+type Component struct {
+	Name    string      `json:"name"`
+	Healthy bool        `json:"healthy"`
+	Error   string      `json:"error,omitempty"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+// 就绪检查的依赖，测试中可替换
+var dependencies = []struct {
+	name  string
+	check func(ctx context.Context) error
+}{
+	{"postgres", dao.Ping},
+	{"redis", cache.Ping},
+	{"producer", func(ctx context.Context) error { return bus.Default().Ping(ctx) }},
+}
+
+var (
+	consumerStatuses = consumer.ConsumerStatuses
+	upstreamStats    = remote_service.UpstreamStats
+)
+
+// healthz GET /healthz 存活检查，消费者异常退出后不会自动重启，需要重启进程
+func healthz(w http.ResponseWriter, r *http.Request) {
+	var failed []Component
+	for _, status := range consumerStatuses() {
+		if status.State == consumer.StateFailed {
+			failed = append(failed, consumerComponent(status))
+		}
+	}
+	if len(failed) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unhealthy", "components": failed})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz GET /readyz 就绪检查，返回各组件状态，任一组件不可用时返回 503
+func readyz(w http.ResponseWriter, r *http.Request) {
+	components := checkDependencies(r.Context())
+	for _, status := range consumerStatuses() {
+		components = append(components, consumerComponent(status))
+	}
+	for _, stat := range upstreamStats() {
+		component := Component{Name: "upstream:" + stat.Host, Healthy: stat.Healthy(), Detail: stat}
+		if !component.Healthy {
+			component.Error = fmt.Sprintf("success rate %.0f%% of %d requests", stat.SuccessRate*100, stat.Requests)
+		}
+		components = append(components, component)
+	}
+
+	status, code := "ok", http.StatusOK
+	for _, component := range components {
+		if !component.Healthy {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "components": components})
+}
+
+// checkDependencies 并发检查各依赖
+func checkDependencies(ctx context.Context) []Component {
+	components := make([]Component, len(dependencies))
+	var wg sync.WaitGroup
+	for i, dependency := range dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			components[i] = Component{Name: dependency.name, Healthy: true}
+			checkCtx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+			defer cancel()
+			if err := runCheck(checkCtx, dependency.check); err != nil {
+				components[i].Healthy = false
+				components[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return components
+}
+
+// runCheck 执行检查，panic 视为失败
+func runCheck(ctx context.Context, check func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return check(ctx)
+}
+
+// consumerComponent 只有正在消费的消费者视为可用
+func consumerComponent(status consumer.ConsumerStatus) Component {
+	component := Component{
+		Name:    "consumer:" + status.Name,
+		Healthy: status.State == consumer.StateRunning,
+		Detail:  status,
+	}
+	if !component.Healthy {
+		component.Error = status.State
+		if status.Error != "" {
+			component.Error += ": " + status.Error
+		}
+	}
+	return component
+}
@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/services"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 手动刷新行情和检测的超时时间
+	refreshTimeout   = time.Minute
+	detectionTimeout = 5 * time.Minute
+)
+
+// 同一时间只允许一次全量实体绑定
+var bindingEntities atomic.Bool
+
+// getTokenCache GET /intelligences/{id}/tokens
+func getTokenCache(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	tokens, err := services.ReadTokenCache(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ttl, err := cache.TTL(r.Context(), services.IntelligenceCoinCacheKeyPrefix+id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"intelligence_id": id,
+		"ttl_seconds":     int64(ttl.Seconds()),
+		"tokens":          tokens,
+	})
+}
+
+// purgeTokenCache DELETE /intelligences/{id}/tokens
+func purgeTokenCache(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := services.PurgeTokenCache(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	lr.I().Infof("Admin purged token cache of intelligence %s", id)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "purged"})
+}
+
+// getShowedTokens GET /intelligences/{id}/showed-tokens
+func getShowedTokens(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	intelligence, err := dao.GetIntelligenceByID(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if intelligence == nil {
+		writeError(w, http.StatusNotFound, errors.New("intelligence not found"))
+		return
+	}
+	showedTokens, err := intelligence.GetShowedTokens()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"intelligence_id": id,
+		"showed_tokens":   showedTokens,
+	})
+}
+
+// getRankingHistory GET /intelligences/{id}/ranking-history?limit=
+func getRankingHistory(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r.URL.Query().Get("limit"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %w", err))
+		return
+	}
+	history, err := services.GetRankingHistory(r.Context(), r.PathValue("id"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// refreshMarket POST /intelligences/{id}/market-refresh
+func refreshMarket(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), refreshTimeout)
+	defer cancel()
+	if err := services.TriggerMarketDataUpdate(ctx, id); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	lr.I().Infof("Admin refreshed market data of intelligence %s", id)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "refreshed"})
+}
+
+// runDetection POST /intelligences/{id}/detect
+// 持有情报检测锁，消费者正在处理该情报时返回 409，避免同时写入币缓存
+func runDetection(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx, cancel := context.WithTimeout(r.Context(), detectionTimeout)
+	defer cancel()
+	lock, err := services.AcquireIntelligenceLock(ctx, id)
+	if errors.Is(err, services.ErrIntelligenceLocked) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer lock.Release(ctx)
+
+	if err := services.RunDetectionRound(ctx, id); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	lr.I().Infof("Admin ran detection round of intelligence %s", id)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "detected"})
+}
+
+// bindAllEntities POST /entities/bind，全量绑定耗时较长，后台执行
+func bindAllEntities(w http.ResponseWriter, r *http.Request) {
+	if !bindingEntities.CompareAndSwap(false, true) {
+		writeError(w, http.StatusConflict, errors.New("entity binding already running"))
+		return
+	}
+
+	go func() {
+		defer bindingEntities.Store(false)
+		defer func() {
+			if r := recover(); r != nil {
+				lr.E().Errorf("Panic in BindAllEntities: %v", r)
+			}
+		}()
+
+		lr.I().Infof("Admin triggered BindAllEntities")
+		if err := services.BindAllEntities(context.WithoutCancel(r.Context())); err != nil {
+			lr.E().Errorf("BindAllEntities failed: %v", err)
+			return
+		}
+		lr.I().Infof("BindAllEntities finished")
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+// listJobs GET /jobs 正在进行的多轮检测
+func listJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"detections":       services.ListDetectionJobs(),
+		"binding_entities": bindingEntities.Load(),
+	})
+}
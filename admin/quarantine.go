@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/model/dto"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+	// 修改后的消息体大小上限
+	maxRequeueBody = 16 << 20
+)
+
+// listQuarantine GET /quarantine?queue=&status=&offset=&limit=
+func listQuarantine(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid offset: %w", err))
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), defaultListLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %w", err))
+		return
+	}
+	limit = min(max(limit, 1), maxListLimit)
+
+	filter := dao.QuarantineFilter{Queue: query.Get("queue"), Status: query.Get("status")}
+	messages, err := dao.ListQuarantinedMessages(r.Context(), filter, max(offset, 0), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]consumer.QuarantineView, 0, len(messages))
+	for _, message := range messages {
+		views = append(views, consumer.NewQuarantineView(message))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// getQuarantine GET /quarantine/{id}
+func getQuarantine(w http.ResponseWriter, r *http.Request) {
+	message, err := dao.GetQuarantinedMessage(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if message == nil {
+		writeError(w, http.StatusNotFound, errors.New("quarantined message not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, consumer.NewQuarantineView(*message))
+}
+
+// requeueQuarantine POST /quarantine/{id}/requeue，请求体不为空时替换原消息体
+func requeueQuarantine(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequeueBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := consumer.RequeueQuarantined(r.Context(), r.PathValue("id"), body); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": dto.QuarantineStatusRequeued})
+}
+
+// discardQuarantine POST /quarantine/{id}/discard
+func discardQuarantine(w http.ResponseWriter, r *http.Request) {
+	if err := consumer.DiscardQuarantined(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": dto.QuarantineStatusDiscarded})
+}
+
+func queryInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
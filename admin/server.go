@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/metrics"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 管理接口配置，ADMIN_ADDR 未设置时监听 :8080，设置为空时不启动
+// 请求需带 Authorization: Bearer <ADMIN_TOKEN>，未配置 ADMIN_TOKEN 时只提供健康检查和指标
+var (
+	Addr  = adminAddr()
+	Token = os.Getenv("ADMIN_TOKEN")
+)
+
+// adminAddr 与其他包的 getEnv 不同，显式设置为空要保留，用于关闭管理接口
+func adminAddr() string {
+	if addr, ok := os.LookupEnv("ADMIN_ADDR"); ok {
+		return addr
+	}
+	return ":8080"
+}
+
+var server *http.Server
+
+// Start 启动管理接口
+func Start() {
+	if Addr == "" {
+		return
+	}
+	if Token == "" {
+		lr.E().Error("ADMIN_TOKEN is not set, only health and metrics endpoints are served")
+	}
+
+	server = &http.Server{
+		Addr:              Addr,
+		Handler:           NewHandler(Token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		lr.I().Infof("Admin server listening on %s", Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			lr.E().Errorf("Admin server error: %v", err)
+		}
+	}()
+}
+
+// Stop 停止接收新请求，等待处理中的请求完成
+func Stop(ctx context.Context) error {
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// NewHandler 管理接口路由，健康检查和指标不需要认证，token 为空时不提供其他接口
+func NewHandler(token string) http.Handler {
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", healthz)
+	root.HandleFunc("GET /readyz", readyz)
+	root.Handle("GET /metrics", metrics.Handler())
+	if token == "" {
+		return root
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /intelligences/{id}/tokens", getTokenCache)
+	mux.HandleFunc("DELETE /intelligences/{id}/tokens", purgeTokenCache)
+	mux.HandleFunc("GET /intelligences/{id}/showed-tokens", getShowedTokens)
+	mux.HandleFunc("GET /intelligences/{id}/ranking-history", getRankingHistory)
+	mux.HandleFunc("POST /intelligences/{id}/market-refresh", refreshMarket)
+	mux.HandleFunc("POST /intelligences/{id}/detect", runDetection)
+	mux.HandleFunc("POST /entities/bind", bindAllEntities)
+	mux.HandleFunc("GET /jobs", listJobs)
+	mux.HandleFunc("GET /quarantine", listQuarantine)
+	mux.HandleFunc("GET /quarantine/{id}", getQuarantine)
+	mux.HandleFunc("POST /quarantine/{id}/requeue", requeueQuarantine)
+	mux.HandleFunc("POST /quarantine/{id}/discard", discardQuarantine)
+	root.Handle("/", withAuth(token, mux))
+	return root
+}
+
+// withAuth 校验 Bearer token
+func withAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		lr.E().Errorf("Failed to write admin response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "secret"
+
+func serve(handler http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestQuarantineRoutes(t *testing.T) {
+	handler := NewHandler(testToken)
+
+	recorder := serve(handler, http.MethodGet, "/quarantine?limit=abc", testToken)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.JSONEq(t, `{"error":"invalid limit: strconv.Atoi: parsing \"abc\": invalid syntax"}`, recorder.Body.String())
+
+	recorder = serve(handler, http.MethodGet, "/quarantine/1/requeue", testToken)
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestAuth(t *testing.T) {
+	handler := NewHandler(testToken)
+
+	for _, token := range []string{"", "wrong"} {
+		recorder := serve(handler, http.MethodGet, "/jobs", token)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.JSONEq(t, `{"error":"unauthorized"}`, recorder.Body.String())
+	}
+
+	recorder := serve(handler, http.MethodGet, "/jobs", testToken)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"detections":[],"binding_entities":false}`, recorder.Body.String())
+
+	recorder = serve(handler, http.MethodGet, "/intelligences/1/ranking-history?limit=-", testToken)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	dependencies[0].check = func(context.Context) error { return nil }
+	dependencies[1].check = func(context.Context) error { return errors.New("connection refused") }
+	dependencies[2].check = func(context.Context) error { return nil }
+	statuses := []consumer.ConsumerStatus{{Name: "intelligence", State: consumer.StateRunning}}
+	consumerStatuses = func() []consumer.ConsumerStatus { return statuses }
+	upstreamStats = func() []remote_service.UpstreamStat { return nil }
+
+	// 健康检查不需要认证，未配置 token 时也可用
+	handler := NewHandler("")
+	recorder := serve(handler, http.MethodGet, "/healthz", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, http.StatusNotFound, serve(handler, http.MethodGet, "/jobs", testToken).Code)
+
+	recorder = serve(handler, http.MethodGet, "/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	var body struct {
+		Status     string      `json:"status"`
+		Components []Component `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "unavailable", body.Status)
+	assert.Len(t, body.Components, 4)
+	assert.False(t, body.Components[1].Healthy)
+	assert.Equal(t, "connection refused", body.Components[1].Error)
+	assert.Equal(t, "consumer:intelligence", body.Components[3].Name)
+	assert.True(t, body.Components[3].Healthy)
+
+	// 消费者退出后存活检查失败
+	dependencies[1].check = func(context.Context) error { return nil }
+	assert.Equal(t, http.StatusOK, serve(handler, http.MethodGet, "/readyz", "").Code)
+	statuses[0].State, statuses[0].Error = consumer.StateFailed, "delivery channel closed"
+	assert.Equal(t, http.StatusServiceUnavailable, serve(handler, http.MethodGet, "/healthz", "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(handler, http.MethodGet, "/readyz", "").Code)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	recorder := serve(NewHandler(testToken), http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	// 按标签区分的指标在有数据后才输出
+	assert.Contains(t, recorder.Body.String(), "# TYPE dogex_detection_top3_changes_total counter")
+	assert.Contains(t, recorder.Body.String(), "# TYPE go_goroutines gauge")
+}
@@ -0,0 +1,137 @@
+// quarantine 查看和处理隔离消息
+//
+//	quarantine list [-queue q] [-status quarantined] [-offset 0] [-limit 50]
+//	quarantine show <id>
+//	quarantine requeue [-body-file fixed.json] <id>
+//	quarantine discard <id>
+package main
+
+import (
+	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage:
+  quarantine list [-queue q] [-status quarantined|requeued|discarded] [-offset 0] [-limit 50]
+  quarantine show <id>
+  quarantine requeue [-body-file file] <id>
+  quarantine discard <id>`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	lr.Init()
+	dao.Init()
+
+	ctx := context.Background()
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "list":
+		err = list(ctx, args)
+	case "show":
+		err = show(ctx, args)
+	case "requeue":
+		err = requeue(ctx, args)
+	case "discard":
+		err = discard(ctx, args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func list(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	queue := flags.String("queue", "", "original queue")
+	status := flags.String("status", "quarantined", "status, empty for all")
+	offset := flags.Int("offset", 0, "offset")
+	limit := flags.Int("limit", 50, "limit")
+	flags.Parse(args)
+
+	messages, err := dao.ListQuarantinedMessages(ctx, dao.QuarantineFilter{Queue: *queue, Status: *status}, *offset, *limit)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tQUEUE\tMESSAGE\tATTEMPTS\tSTATUS\tERROR")
+	for _, message := range messages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", message.ID, message.CreatedAt.Format(time.DateTime),
+			message.Queue, message.MessageID, message.Attempts, message.Status, message.Error)
+	}
+	return w.Flush()
+}
+
+func show(ctx context.Context, args []string) error {
+	id, err := argID("show", args)
+	if err != nil {
+		return err
+	}
+	message, err := dao.GetQuarantinedMessage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if message == nil {
+		return fmt.Errorf("quarantined message not found: %s", id)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(consumer.NewQuarantineView(*message))
+}
+
+func requeue(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("requeue", flag.ExitOnError)
+	bodyFile := flags.String("body-file", "", "replace the message body with the file content")
+	flags.Parse(args)
+
+	id, err := argID("requeue", flags.Args())
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if *bodyFile != "" {
+		if body, err = os.ReadFile(*bodyFile); err != nil {
+			return err
+		}
+	}
+
+	// redis 消息总线需要先初始化 cache
+	cache.Init()
+	if err := bus.Init(); err != nil {
+		return err
+	}
+	defer bus.Close()
+	return consumer.RequeueQuarantined(ctx, id, body)
+}
+
+func discard(ctx context.Context, args []string) error {
+	id, err := argID("discard", args)
+	if err != nil {
+		return err
+	}
+	return consumer.DiscardQuarantined(ctx, id)
+}
+
+func argID(command string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("usage: quarantine " + command + " <id>")
+	}
+	return args[0], nil
+}
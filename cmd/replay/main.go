@@ -0,0 +1,106 @@
+// replay 从消息归档重放消息，默认 dry-run 只解码和校验
+//
+//	replay -handler intelligence -from "2025-06-01 00:00:00" -to "2025-06-02 00:00:00"
+//	replay -handler intelligence -ids i1,i2 -live -reprocess
+//	replay -handler intelligence -from "2025-06-01 00:00:00" -diff，处理但只记录将要做的修改，见 DRY_RUN_OUTPUT
+package main
+
+import (
+	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/services"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 退出时等待写入行情的最长时间
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	handler := flag.String("handler", "intelligence", "consumer handler name")
+	from := flag.String("from", "", "start of received time, RFC3339 or 2006-01-02 15:04:05 in local time")
+	to := flag.String("to", "", "end of received time, exclusive")
+	ids := flag.String("ids", "", "comma separated message ids")
+	dir := flag.String("dir", consumer.ArchiveDir, "archive directory, defaults to ARCHIVE_DIR")
+	live := flag.Bool("live", false, "process messages with the handler, default dry-run only decodes and validates")
+	diff := flag.Bool("diff", false, "process messages in dry-run mode, recording writes instead of applying them")
+	reprocess := flag.Bool("reprocess", false, "ignore finished processing records")
+	limit := flag.Int("limit", 0, "max messages to replay, 0 uses DEFAULT_MAX_HISTORICAL_MESSAGES")
+	flag.Parse()
+
+	opts := consumer.ReplayOptions{Handler: *handler, Live: *live, Reprocess: *reprocess, Limit: *limit}
+	var err error
+	if opts.From, err = parseTime(*from); err != nil {
+		exit(fmt.Errorf("invalid -from: %w", err))
+	}
+	if opts.To, err = parseTime(*to); err != nil {
+		exit(fmt.Errorf("invalid -to: %w", err))
+	}
+	for _, id := range strings.Split(*ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			opts.IDs = append(opts.IDs, id)
+		}
+	}
+	consumer.ArchiveDir = *dir
+	if *diff {
+		dryrun.SetEnabled(true)
+		*live = true
+	}
+	if dryrun.Enabled() {
+		defer dryrun.Close()
+	}
+
+	lr.Init()
+	if *live {
+		dao.Init()
+		cache.Init()
+		if err := bus.Init(); err != nil {
+			exit(err)
+		}
+		defer bus.Close()
+		remote_service.Init()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := consumer.Replay(ctx, opts)
+	fmt.Printf("matched %d, processed %d, invalid %d, failed %d\n", result.Matched, result.Processed, result.Invalid, result.Failed)
+
+	if *live {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer flushCancel()
+		if err := services.FlushMarketDataSink(flushCtx); err != nil {
+			lr.E().Errorf("Failed to flush market data: %v", err)
+		}
+	}
+	if err != nil {
+		exit(err)
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateTime, value, time.Local)
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,191 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions 自适应并发配置，MinConcurrency 大于0时启用
+// 每个周期内失败比例或平均处理时间超过阈值时并发乘以 Decrease，否则加1，范围 [MinConcurrency, MaxConcurrency]
+type AdaptiveOptions struct {
+	MinConcurrency int           // 并发下限
+	MaxConcurrency int           // 并发上限，0 使用 Concurrency
+	TargetLatency  time.Duration // 平均处理时间超过该值时减小并发，0 不按处理时间调整
+	MaxErrorRate   float64       // 失败比例超过该值时减小并发，0 不按失败比例调整
+	Interval       time.Duration // 调整周期，0 为10秒
+
+	// Load 负载来源，设置后按它返回的耗时和失败比例判断过载，不使用消息的处理时间和结果
+	// 处理时间主要由固定等待决定的消息（如按间隔多轮检测）需要按上游请求判断，参数为调整周期
+	Load func(window time.Duration) AdaptiveLoad
+}
+
+// AdaptiveLoad 一个调整周期内的负载
+type AdaptiveLoad struct {
+	Latency   time.Duration // 平均耗时
+	ErrorRate float64       // 失败比例
+}
+
+// 并发减小的比例
+const adaptiveDecrease = 0.75
+
+func (o AdaptiveOptions) enabled() bool {
+	return o.MinConcurrency > 0
+}
+
+// limiter 可调整上限的信号量
+type limiter struct {
+	mutex   sync.Mutex
+	limit   int
+	active  int
+	changed chan struct{} // 上限变化或释放时关闭并替换
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: limit, changed: make(chan struct{})}
+}
+
+// acquire 获取一个额度，ctx 取消时返回错误
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mutex.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mutex.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.active--
+	l.notify()
+}
+
+func (l *limiter) setLimit(limit int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limit = limit
+	l.notify()
+}
+
+func (l *limiter) getLimit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit
+}
+
+func (l *limiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// adaptiveController 按处理时间和失败比例调整并发和预取
+type adaptiveController struct {
+	reg      *registration
+	options  AdaptiveOptions
+	limiter  *limiter
+	prefetch chan int // 新的预取数量，传给消息总线
+
+	mutex    sync.Mutex
+	count    int
+	failures int
+	latency  time.Duration
+}
+
+func newAdaptiveController(reg *registration, limiter *limiter) *adaptiveController {
+	options := reg.options.Adaptive
+	if options.MaxConcurrency <= 0 {
+		options.MaxConcurrency = reg.options.Concurrency
+	}
+	options.MinConcurrency = min(options.MinConcurrency, options.MaxConcurrency)
+	if options.Interval <= 0 {
+		options.Interval = 10 * time.Second
+	}
+	return &adaptiveController{
+		reg:      reg,
+		options:  options,
+		limiter:  limiter,
+		prefetch: make(chan int, 1),
+	}
+}
+
+// observe 记录一条消息的处理结果
+func (c *adaptiveController) observe(latency time.Duration, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count++
+	c.latency += latency
+	if failed {
+		c.failures++
+	}
+}
+
+func (c *adaptiveController) run(ctx context.Context) {
+	ticker := time.NewTicker(c.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.adjust()
+		}
+	}
+}
+
+// adjust 按上一周期的统计调整并发，周期内没有消息时不调整
+func (c *adaptiveController) adjust() {
+	c.mutex.Lock()
+	count, failures, latency := c.count, c.failures, c.latency
+	c.count, c.failures, c.latency = 0, 0, 0
+	c.mutex.Unlock()
+	if count == 0 {
+		return
+	}
+
+	current := c.limiter.getLimit()
+	errorRate := float64(failures) / float64(count)
+	avgLatency := latency / time.Duration(count)
+	if c.options.Load != nil {
+		load := c.options.Load(c.options.Interval)
+		errorRate, avgLatency = load.ErrorRate, load.Latency
+	}
+	overloaded := (c.options.MaxErrorRate > 0 && errorRate > c.options.MaxErrorRate) ||
+		(c.options.TargetLatency > 0 && avgLatency > c.options.TargetLatency)
+
+	next := current + 1
+	if overloaded {
+		next = int(float64(current) * adaptiveDecrease)
+	}
+	next = max(c.options.MinConcurrency, min(c.options.MaxConcurrency, next))
+	if next == current {
+		return
+	}
+
+	c.limiter.setLimit(next)
+	recordConcurrencyLimit(c.reg.queue, next)
+	if overloaded {
+		lr.I().Infof("Consumer %s concurrency %d -> %d, error rate %.2f, avg latency %s",
+			c.reg.name, current, next, errorRate, avgLatency)
+	}
+
+	// 预取按并发等比例调整，至少为1
+	prefetch := max(1, c.reg.options.Prefetch*next/max(1, c.reg.options.Concurrency))
+	select {
+	case <-c.prefetch:
+	default:
+	}
+	c.prefetch <- prefetch
+}
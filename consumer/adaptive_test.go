@@ -0,0 +1,107 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1)
+	ctx := context.Background()
+	assert.NoError(t, l.acquire(ctx))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.acquire(timeoutCtx), context.DeadlineExceeded)
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.acquire(ctx) }()
+	l.setLimit(2)
+	select {
+	case err := <-acquired:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("acquire not released after raising limit")
+	}
+	l.release()
+	l.release()
+}
+
+func TestAdaptiveControllerAdjust(t *testing.T) {
+	useMemoryBus(t)
+	reg := &registration{
+		name:  "test_adaptive",
+		queue: "test-adaptive",
+		options: HandlerOptions{
+			Prefetch:    20,
+			Concurrency: 10,
+			Adaptive:    AdaptiveOptions{MinConcurrency: 4, TargetLatency: time.Second, MaxErrorRate: 0.2},
+		},
+	}
+	l := newLimiter(10)
+	c := newAdaptiveController(reg, l)
+
+	// 没有消息时不调整
+	c.adjust()
+	assert.Equal(t, 10, l.getLimit())
+
+	// 失败比例过高时乘性减小，预取等比例减小
+	for i := 0; i < 10; i++ {
+		c.observe(100*time.Millisecond, i < 3)
+	}
+	c.adjust()
+	assert.Equal(t, 7, l.getLimit())
+	assert.Equal(t, 14, <-c.prefetch)
+	assert.Equal(t, 7, ConcurrencyLimit("test-adaptive"))
+
+	// 处理时间过长时减小，不低于下限
+	for round := 0; round < 3; round++ {
+		c.observe(2*time.Second, false)
+		c.adjust()
+	}
+	assert.Equal(t, 4, l.getLimit())
+
+	// 恢复后加性增加，不超过上限
+	for round := 0; round < 10; round++ {
+		c.observe(100*time.Millisecond, false)
+		c.adjust()
+	}
+	assert.Equal(t, 10, l.getLimit())
+	assert.Equal(t, 20, <-c.prefetch)
+}
+
+func TestAdaptiveControllerExternalLoad(t *testing.T) {
+	useMemoryBus(t)
+	load := AdaptiveLoad{Latency: 100 * time.Millisecond}
+	reg := &registration{
+		name:  "test_adaptive_load",
+		queue: "test-adaptive-load",
+		options: HandlerOptions{
+			Prefetch:    10,
+			Concurrency: 10,
+			Adaptive: AdaptiveOptions{MinConcurrency: 4, TargetLatency: time.Second, MaxErrorRate: 0.2,
+				Load: func(time.Duration) AdaptiveLoad { return load }},
+		},
+	}
+	l := newLimiter(8)
+	c := newAdaptiveController(reg, l)
+
+	// 消息处理时间很长但上游正常时不减小并发
+	c.observe(5*time.Minute, false)
+	c.adjust()
+	assert.Equal(t, 9, l.getLimit())
+
+	// 上游变慢或失败增多时减小并发
+	load = AdaptiveLoad{Latency: 2 * time.Second}
+	c.observe(time.Minute, false)
+	c.adjust()
+	assert.Equal(t, 6, l.getLimit())
+
+	load = AdaptiveLoad{Latency: 100 * time.Millisecond, ErrorRate: 0.5}
+	c.observe(time.Minute, false)
+	c.adjust()
+	assert.Equal(t, 4, l.getLimit())
+}
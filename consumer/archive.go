@@ -0,0 +1,269 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/lr"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 消息归档配置，ARCHIVE_DIR 为空时不归档
+// 每个队列一个目录，按小时（UTC）切分 JSONL 文件，超过保留天数的文件在切分时删除
+var (
+	ArchiveDir       = getEnv("ARCHIVE_DIR", "")
+	ArchiveRetention = time.Duration(getEnvInt("ARCHIVE_RETENTION_DAYS", 7)) * 24 * time.Hour
+)
+
+const (
+	archiveFileLayout = "2006010215"
+	archiveFileExt    = ".jsonl"
+	// 单行最大长度，与消息体大小上限一致
+	archiveMaxLine = 16 << 20
+)
+
+// ArchivedMessage 归档的原始消息
+type ArchivedMessage struct {
+	Queue           string                 `json:"queue"`
+	ID              string                 `json:"id"`
+	ReceivedAt      time.Time              `json:"received_at"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Priority        uint8                  `json:"priority,omitempty"`
+	ContentType     string                 `json:"content_type,omitempty"`
+	ContentEncoding string                 `json:"content_encoding,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+	Body            []byte                 `json:"body"` // 原始字节，JSON 中为 base64，gzip 等非 UTF-8 消息体也能原样还原
+}
+
+// Delivery 还原为消费时的消息
+func (m ArchivedMessage) Delivery() *bus.Delivery {
+	return &bus.Delivery{
+		ID:              m.ID,
+		Queue:           m.Queue,
+		Body:            m.Body,
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		Headers:         m.Headers,
+		Timestamp:       m.Timestamp,
+		Priority:        m.Priority,
+	}
+}
+
+// archive 追加写入的消息归档
+type archive struct {
+	dir       string
+	retention time.Duration
+
+	mutex sync.Mutex
+	files map[string]*archiveFile // queue -> 当前小时的文件
+}
+
+type archiveFile struct {
+	hour string
+	file *os.File
+}
+
+var (
+	defaultArchive     *archive
+	defaultArchiveOnce sync.Once
+)
+
+func newArchive(dir string, retention time.Duration) *archive {
+	return &archive{dir: dir, retention: retention, files: make(map[string]*archiveFile)}
+}
+
+// archiveMsg 归档消费到的消息，归档失败不影响处理
+func archiveMsg(msg *bus.Delivery) {
+	defaultArchiveOnce.Do(func() {
+		if ArchiveDir != "" {
+			defaultArchive = newArchive(ArchiveDir, ArchiveRetention)
+		}
+	})
+	if defaultArchive == nil {
+		return
+	}
+	if err := defaultArchive.append(msg, time.Now()); err != nil {
+		lr.E().Errorf("Failed to archive message %s from queue %s: %v", msg.ID, msg.Queue, err)
+	}
+}
+
+func (a *archive) append(msg *bus.Delivery, receivedAt time.Time) error {
+	record := ArchivedMessage{
+		Queue:           msg.Queue,
+		ID:              msg.ID,
+		ReceivedAt:      receivedAt,
+		Timestamp:       msg.Timestamp,
+		Priority:        msg.Priority,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Headers:         msg.Headers,
+		Body:            msg.Body,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		// RabbitMQ 头信息可能包含无法序列化的类型，去掉头信息后归档
+		record.Headers = nil
+		if line, err = json.Marshal(record); err != nil {
+			return err
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	f, err := a.file(msg.Queue, receivedAt)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// file 获取队列当前小时的归档文件，跨小时时切换文件并清理过期文件
+func (a *archive) file(queue string, now time.Time) (*os.File, error) {
+	hour := now.UTC().Format(archiveFileLayout)
+	if current, ok := a.files[queue]; ok {
+		if current.hour == hour {
+			return current.file, nil
+		}
+		if err := current.file.Close(); err != nil {
+			lr.E().Errorf("Failed to close archive file of queue %s: %v", queue, err)
+		}
+		delete(a.files, queue)
+	}
+
+	dir := filepath.Join(a.dir, queue)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, hour+archiveFileExt), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	a.files[queue] = &archiveFile{hour: hour, file: f}
+	a.cleanup(dir, now)
+	return f, nil
+}
+
+// cleanup 删除超过保留时间的归档文件
+func (a *archive) cleanup(dir string, now time.Time) {
+	if a.retention <= 0 {
+		return
+	}
+	files, err := archiveFiles(dir)
+	if err != nil {
+		lr.E().Errorf("Failed to list archive files in %s: %v", dir, err)
+		return
+	}
+	for _, file := range files {
+		if file.hour.Add(time.Hour).Before(now.Add(-a.retention)) {
+			if err := os.Remove(file.path); err != nil {
+				lr.E().Errorf("Failed to remove archive file %s: %v", file.path, err)
+			}
+		}
+	}
+}
+
+func (a *archive) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for queue, current := range a.files {
+		if err := current.file.Close(); err != nil {
+			lr.E().Errorf("Failed to close archive file of queue %s: %v", queue, err)
+		}
+		delete(a.files, queue)
+	}
+}
+
+type archiveFileInfo struct {
+	path string
+	hour time.Time
+}
+
+// archiveFiles 目录下的归档文件，按时间排序
+func archiveFiles(dir string) ([]archiveFileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []archiveFileInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, archiveFileExt) {
+			continue
+		}
+		hour, err := time.Parse(archiveFileLayout, strings.TrimSuffix(name, archiveFileExt))
+		if err != nil {
+			continue
+		}
+		files = append(files, archiveFileInfo{path: filepath.Join(dir, name), hour: hour})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].hour.Before(files[j].hour) })
+	return files, nil
+}
+
+// readArchive 按时间顺序读取队列在 [from, to) 内收到的消息，from/to 为零时不限制，fn 返回 false 时停止
+func readArchive(dir, queue string, from, to time.Time, fn func(ArchivedMessage) bool) error {
+	files, err := archiveFiles(filepath.Join(dir, queue))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !from.IsZero() && file.hour.Add(time.Hour).Before(from) {
+			continue
+		}
+		if !to.IsZero() && !file.hour.Before(to) {
+			break
+		}
+		next, err := readArchiveFile(file.path, from, to, fn)
+		if err != nil {
+			return err
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+func readArchiveFile(path string, from, to time.Time, fn func(ArchivedMessage) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), archiveMaxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var msg ArchivedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// 进程退出时可能留下不完整的行
+			lr.E().Errorf("Skip invalid archive line %s:%d: %v", path, line, err)
+			continue
+		}
+		if !from.IsZero() && msg.ReceivedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !msg.ReceivedAt.Before(to) {
+			continue
+		}
+		if !fn(msg) {
+			return false, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read archive %s: %w", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveRotateAndRead(t *testing.T) {
+	useMemoryBus(t)
+	dir := t.TempDir()
+	a := newArchive(dir, 48*time.Hour)
+	t.Cleanup(a.close)
+
+	start := time.Date(2025, 6, 1, 10, 30, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		msg := &bus.Delivery{ID: id, Queue: "q", Body: []byte(`{"id":"` + id + `"}`), Headers: map[string]interface{}{"n": i}}
+		assert.NoError(t, a.append(msg, start.Add(time.Duration(i)*time.Hour)))
+	}
+	files, err := archiveFiles(filepath.Join(dir, "q"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	var ids []string
+	assert.NoError(t, readArchive(dir, "q", start.Add(time.Hour), time.Time{}, func(msg ArchivedMessage) bool {
+		ids = append(ids, msg.ID)
+		return true
+	}))
+	assert.Equal(t, []string{"b", "c"}, ids)
+
+	// 跨小时切换文件时删除超过保留时间的文件
+	assert.NoError(t, a.append(&bus.Delivery{ID: "d", Queue: "q", Body: []byte(`{}`)}, start.Add(50*time.Hour)))
+	files, err = archiveFiles(filepath.Join(dir, "q"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	_, err = os.Stat(filepath.Join(dir, "q", "2025060111.jsonl"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "q", "2025060112.jsonl"))
+	assert.NoError(t, err)
+}
+
+func TestArchiveBinaryBody(t *testing.T) {
+	useMemoryBus(t)
+	dir := t.TempDir()
+	a := newArchive(dir, 0)
+	t.Cleanup(a.close)
+
+	// gzip 压缩的消息体不是合法的 UTF-8，需要按原始字节还原
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`{"id":"z"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	body := buf.Bytes()
+
+	assert.NoError(t, a.append(&bus.Delivery{ID: "z", Queue: "q", Body: body, ContentEncoding: "gzip"}, time.Now()))
+
+	var restored []*bus.Delivery
+	assert.NoError(t, readArchive(dir, "q", time.Time{}, time.Time{}, func(msg ArchivedMessage) bool {
+		restored = append(restored, msg.Delivery())
+		return true
+	}))
+	assert.Len(t, restored, 1)
+	assert.Equal(t, body, restored[0].Body)
+	assert.Equal(t, "gzip", restored[0].ContentEncoding)
+}
+
+func TestReplay(t *testing.T) {
+	useMemoryBus(t)
+	dir := t.TempDir()
+	a := newArchive(dir, 0)
+	t.Cleanup(a.close)
+	original := ArchiveDir
+	ArchiveDir = dir
+	t.Cleanup(func() { ArchiveDir = original })
+
+	var processed []string
+	Register(Handler[testMessage]{
+		Name:  "test_replay",
+		Queue: "test-replay",
+		Process: func(ctx context.Context, msg *testMessage) error {
+			processed = append(processed, msg.ID)
+			if msg.ID == "fail" {
+				return errors.New("upstream unavailable")
+			}
+			return nil
+		},
+	})
+
+	now := time.Now()
+	for _, body := range []string{`{"id":"ok"}`, `not json`, `{"id":"fail"}`, `{"id":"later"}`} {
+		assert.NoError(t, a.append(&bus.Delivery{ID: "m-" + body, Queue: "test-replay", Body: []byte(body)}, now))
+	}
+
+	result, err := Replay(context.Background(), ReplayOptions{Handler: "test_replay"})
+	assert.NoError(t, err)
+	assert.Equal(t, ReplayResult{Matched: 4, Processed: 3, Invalid: 1}, result)
+	assert.Empty(t, processed)
+
+	result, err = Replay(context.Background(), ReplayOptions{Handler: "test_replay", Live: true, IDs: []string{"ok", "fail"}})
+	assert.NoError(t, err)
+	assert.Equal(t, ReplayResult{Matched: 2, Processed: 1, Failed: 1}, result)
+	assert.Equal(t, []string{"ok", "fail"}, processed)
+
+	result, err = Replay(context.Background(), ReplayOptions{Handler: "test_replay", Live: true, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, ReplayResult{Matched: 1, Processed: 1}, result)
+
+	_, err = Replay(context.Background(), ReplayOptions{Handler: "missing"})
+	assert.ErrorContains(t, err, "unknown consumer handler")
+}
+
+func TestWithReprocess(t *testing.T) {
+	assert.JSONEq(t, `{"id":"i1","reprocess":true}`, string(withReprocess([]byte(`{"id":"i1","reprocess":false}`))))
+	assert.Equal(t, "not json", string(withReprocess([]byte("not json"))))
+}
@@ -1,174 +1,269 @@
 package consumer
 
 import (
-	"back_ai_gun_data/pkg/model"
 	"back_ai_gun_data/utils"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
-	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/health"
 	"back_ai_gun_data/pkg/lr"
-	"back_ai_gun_data/services"
+)
+
+// 按重试策略重新发送消息的超时时间
+const retryPublishTimeout = 5 * time.Second
 
-	amqp "github.com/rabbitmq/amqp091-go"
+// 订阅失败或投递通道关闭后重新订阅的退避时间，从最小值开始翻倍，订阅成功后重置
+var (
+	resubscribeMinBackoff = time.Second
+	resubscribeMaxBackoff = 30 * time.Second
 )
 
-func StartIntelligenceConsumer(ctx context.Context) {
+// startRegistered 启动已注册处理器的consumer
+func startRegistered(ctx context.Context, reg *registration) {
+	setConsumerState(reg, StateStarting, nil)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				lr.E().Errorf("Panic in intelligence consumer: %v", r)
+				lr.E().Errorf("Panic in %s consumer: %v", reg.name, r)
+				setConsumerState(reg, StateFailed, fmt.Errorf("panic: %v", r))
 			}
 		}()
 
-		if err := startConsumer(ctx, consts.QUEUE_INTELLIGENCE_SORT, consts.CONSUMER_TAG_INTELLIGENCE); err != nil {
-			lr.E().Errorf("Intelligence consumer error: %v", err)
+		if err := startConsumer(ctx, reg); err != nil {
+			lr.E().Errorf("%s consumer error: %v", reg.name, err)
+			setConsumerState(reg, StateFailed, err)
+			return
 		}
+		setConsumerState(reg, StateStopped, nil)
 	}()
 }
 
-// 启动ETL实体数据consumer
-func StartETLEntityConsumer(ctx context.Context) {
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				lr.E().Errorf("Panic in ETL entity consumer: %v", r)
-			}
-		}()
-		if err := startConsumer(ctx, consts.QUEUE_ETL_ENTITY_DATA, consts.CONSUMER_TAG_ETL_ENTITY); err != nil {
-			lr.E().Errorf("ETL entity consumer error: %v", err)
-		}
+// 通用的consumer启动函数，传输层由 MESSAGE_BUS 决定
+func startConsumer(ctx context.Context, reg *registration) error {
+	// 订阅中断时取消处理中的消息，放回队列
+	poolCtx, cancel := context.WithCancel(ctx)
+	pool := newWorkerPool(poolCtx, reg)
+	defer func() {
+		cancel()
+		pool.stop()
 	}()
-}
-
-// 通用的consumer启动函数
-func startConsumer(ctx context.Context, queueName, consumerTag string) error {
-	conn, err := amqp.Dial(getEnv("RABBITMQ_URL", consts.DEFAULT_RABBITMQ_URL))
-	if err != nil {
-		lr.E().Errorf("Failed to connect to RabbitMQ: %v", err)
-		return fmt.Errorf("connect failed: %w", err)
-	}
-	defer conn.Close()
-
-	ch, err := conn.Channel()
-	if err != nil {
-		lr.E().Errorf("Failed to open channel: %v", err)
-		return fmt.Errorf("channel failed: %w", err)
-	}
-	defer ch.Close()
 
-	err = ch.Qos(getEnvInt("PREFETCH", consts.DEFAULT_PREFETCH_COUNT), 0, false)
-	if err != nil {
-		lr.E().Errorf("Failed to set QoS: %v", err)
-		return fmt.Errorf("qos failed: %w", err)
-	}
+	messageBus := bus.Default()
+	fullConsumerTag := fmt.Sprintf("%s-%d", reg.consumerTag, os.Getpid())
+	backoff := resubscribeMinBackoff
+	for {
+		msgs, err := messageBus.Subscribe(ctx, reg.queue, bus.SubscribeOptions{
+			ConsumerTag:     fullConsumerTag,
+			Prefetch:        reg.options.Prefetch,
+			PrefetchUpdates: pool.prefetchUpdates(),
+		})
+		if err != nil {
+			lr.E().Errorf("Failed to subscribe queue %s on %s, retry in %s: %v", reg.queue, messageBus.Name(), backoff, err)
+			setConsumerState(reg, StateReconnecting, fmt.Errorf("subscribe failed: %w", err))
+		} else {
+			backoff = resubscribeMinBackoff
+			setConsumerState(reg, StateRunning, nil)
+			lr.I().Infof("Consumer %s started on %s, listening on queue: %s with tag: %s, prefetch %d, concurrency %d, reserved %d",
+				reg.name, messageBus.Name(), reg.queue, fullConsumerTag, reg.options.Prefetch, reg.options.Concurrency, reg.options.Priority.Reserved)
 
-	_, err = ch.QueueDeclare(queueName, true, false, false, false, nil)
-	if err != nil {
-		lr.E().Errorf("Failed to declare queue: %v", err)
-		return fmt.Errorf("queue declare failed: %w", err)
-	}
+			err = consume(ctx, poolCtx, reg, pool, msgs)
+			if err == nil {
+				return nil
+			}
+			// 连接或通道断开，已投递未确认的消息由 broker 重新投递
+			lr.E().Errorf("Consumer %s lost subscription, resubscribe in %s: %v", reg.name, backoff, err)
+			setConsumerState(reg, StateReconnecting, err)
+		}
 
-	// 处理历史消息
-	//if err := processHistoricalMessages(ch, queueName); err != nil {
-	//	lr.E().Errorf("Failed to process historical messages: %v", err)
-	//	// 不返回错误，继续处理新消息
-	//}
-
-	fullConsumerTag := fmt.Sprintf("%s-%d", consumerTag, os.Getpid())
-	msgs, err := ch.Consume(queueName, fullConsumerTag, false, false, false, false, nil)
-	if err != nil {
-		lr.E().Errorf("Failed to register consumer: %v", err)
-		return fmt.Errorf("consume failed: %w", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, resubscribeMaxBackoff)
 	}
+}
 
-	lr.I().Infof("Consumer started, listening on queue: %s with tag: %s", queueName, fullConsumerTag)
-
-	var semaphore = make(chan struct{}, getEnvInt("MAX_CONCURRENT", consts.DEFAULT_MAX_CONCURRENT))
-
+// consume 从投递通道接收消息交给工作池，ctx 取消时返回 nil，通道关闭时返回错误
+func consume(ctx, poolCtx context.Context, reg *registration, pool *workerPool, msgs <-chan *bus.Delivery) error {
 	for {
+		// Redis 或 PG 不可用时暂停拉取消息，未确认的消息达到预取上限后 broker 不再投递
+		if !health.Healthy() {
+			lr.E().Errorf("Consumer %s paused, waiting for dependencies to recover", reg.name)
+			setConsumerState(reg, StatePaused, nil)
+			if err := health.WaitHealthy(ctx); err != nil {
+				return nil
+			}
+			lr.I().Infof("Consumer %s resumed", reg.name)
+			setConsumerState(reg, StateRunning, nil)
+		}
+
 		select {
 		case <-ctx.Done():
-			lr.I().Info("Consumer context cancelled, stopping...")
+			lr.I().Infof("Consumer %s context cancelled, stopping...", reg.name)
 			return nil
-		case msg := <-msgs:
-			semaphore <- struct{}{} // 获取信号量
-			go func(msg amqp.Delivery) {
-				defer func() {
-					if r := recover(); r != nil {
-						lr.E().Errorf("Panic in message handler for queue %s: %v", queueName, r)
-					}
-				}()
-				defer func() { <-semaphore }() // 释放信号量
-				handleMsg(msg, queueName)
-			}(msg)
+		case msg, ok := <-msgs:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("delivery channel of queue %s closed", reg.queue)
+			}
+			consumedMessages.WithLabelValues(reg.queue).Inc()
+			// 归档原始消息，用于重放
+			archiveMsg(msg)
+			pool.submit(poolCtx, msg)
 		}
 	}
 }
 
-func handleMsg(msg amqp.Delivery, queueName string) {
+// handleMsg 处理单条消息：成功确认，需要稍后重试的消息延迟重新发送，无效消息和不可重试错误转入隔离队列，其余失败按重试策略处理
+// 返回按重试策略处理的错误，用于调整并发
+func handleMsg(ctx context.Context, reg *registration, msg *bus.Delivery, prepared preparedMsg) (failed error) {
+	start := time.Now()
+	outcome := outcomeAcked
+	inFlightDone := trackInFlight(reg.queue)
+	defer func() {
+		inFlightDone()
+		handlerDuration.WithLabelValues(reg.queue, outcome).Observe(time.Since(start).Seconds())
+	}()
+
 	defer func() {
 		if r := recover(); r != nil {
+			outcome = outcomePanic
+			stack := utils.GetStack()
 			lr.E().WithFields(lr.F{
-				"backtrace": utils.GetStack(),
-			}).Errorf("Panic in message handler for queue %s: %v", queueName, r)
-			err := msg.Nack(false, true)
-			if err != nil {
-				lr.E().Error(err)
-				return
-			} // 重新入队
+				"backtrace": stack,
+			}).Errorf("Panic in message handler for queue %s: %v", reg.queue, r)
+			failed = &panicError{value: r, stack: stack}
+			retryMsg(ctx, reg, msg, failed)
 		}
 	}()
 
-	var err error
-	switch queueName {
-	case consts.QUEUE_INTELLIGENCE_SORT:
-		var messageData model.IntelligenceMessage
-		if err = json.Unmarshal(msg.Body, &messageData); err != nil {
-			lr.E().Errorf("Failed to unmarshal intelligence message: %v", err)
-			msg.Nack(false, false)
-			return
+	handleCtx := withDelivery(ctx, msg)
+	if reg.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		handleCtx, cancel = context.WithTimeout(handleCtx, reg.options.Timeout)
+		defer cancel()
+	}
+
+	err := prepared.err
+	if err == nil {
+		err = prepared.process(handleCtx)
+	}
+	switch {
+	case err == nil:
+		if err := msg.Ack(); err != nil {
+			lr.E().Error(err)
 		}
-		err = processIntelligenceMessage(context.Background(), &messageData)
-	case consts.QUEUE_ETL_ENTITY_DATA:
-		var messageData model.ETLEntityMessage
-		if err = json.Unmarshal(msg.Body, &messageData); err != nil {
-			lr.E().Errorf("Failed to unmarshal ETL entity message: %v", err)
-			msg.Nack(false, false)
-			return
+	case ctx.Err() != nil:
+		// 退出时中断的消息直接放回队列，不计入失败次数
+		outcome = outcomeRequeued
+		lr.I().Infof("Consumer %s stopping, requeue message %s", reg.name, msg.ID)
+		if err := msg.Nack(true); err != nil {
+			lr.E().Error(err)
 		}
-		err = processETLEntityMessage(context.Background(), &messageData)
+	case errors.Is(handleCtx.Err(), context.DeadlineExceeded):
+		outcome = outcomeTimeout
+		recordTimeout(reg.queue)
+		lr.E().Errorf("Message %s from queue %s timed out after %s: %v", msg.ID, reg.queue, reg.options.Timeout, err)
+		retryMsg(ctx, reg, msg, err)
+		return err
+	case isRetryLater(err):
+		outcome = outcomeDeferred
+		after := retryLaterDelay(err)
+		lr.I().Infof("Message %s from queue %s deferred for %s: %v", msg.ID, reg.queue, after, err)
+		deferMsg(ctx, reg, msg, after)
+	case isInvalidMessage(err), isPermanent(err):
+		outcome = outcomeQuarantined
+		quarantineMsg(reg, msg, err, deliveryAttempts(msg)+1)
 	default:
-		lr.E().Errorf("Unknown queue type: %s", queueName)
-		msg.Nack(false, false)
-		return
+		outcome = outcomeFailed
+		lr.E().Errorf("Failed to process message %s from queue %s: %v", msg.ID, reg.queue, err)
+		retryMsg(ctx, reg, msg, err)
+		return err
 	}
+	return nil
+}
 
-	if err != nil {
-		lr.E().Errorf("Failed to process message from queue %s: %v", queueName, err)
-		err := msg.Nack(false, true)
-		if err != nil {
+// retryMsg 按重试策略处理失败的消息
+// 不限次数时直接重新入队；限制次数时带上失败次数重新发送，达到上限后转入隔离队列
+func retryMsg(ctx context.Context, reg *registration, msg *bus.Delivery, cause error) {
+	policy := reg.options.Retry
+	if policy.Backoff > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(policy.Backoff):
+		}
+	}
+
+	if policy.MaxAttempts <= 0 {
+		if err := msg.Nack(true); err != nil { // 重新入队
 			lr.E().Error(err)
-			return
-		} // 重新入队
+		}
 		return
 	}
 
-	err = msg.Ack(false)
-	if err != nil {
-		lr.E().Error(err)
+	attempts := deliveryAttempts(msg) + 1
+	if attempts >= policy.MaxAttempts {
+		lr.E().Errorf("Message %s from queue %s failed %d times, giving up: %v", msg.ID, reg.queue, attempts, cause)
+		quarantineMsg(reg, msg, cause, attempts)
 		return
 	}
-}
 
-func processIntelligenceMessage(ctx context.Context, messageData *model.IntelligenceMessage) error {
-	return services.ProcessIntelligenceData(ctx, messageData)
+	retry := msg.Message()
+	headers := make(map[string]interface{}, len(retry.Headers)+1)
+	for k, v := range retry.Headers {
+		headers[k] = v
+	}
+	headers[AttemptsHeader] = int64(attempts)
+	retry.Headers = headers
+
+	// 使用独立的 ctx，退出时也能把消息放回队列
+	publishCtx, cancel := context.WithTimeout(context.Background(), retryPublishTimeout)
+	defer cancel()
+	if err := bus.Default().Publish(publishCtx, reg.queue, retry); err != nil {
+		lr.E().Errorf("Failed to republish message %s to %s, requeue instead: %v", msg.ID, reg.queue, err)
+		if err := msg.Nack(true); err != nil {
+			lr.E().Error(err)
+		}
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		lr.E().Error(err)
+	}
 }
 
-func processETLEntityMessage(ctx context.Context, messageData *model.ETLEntityMessage) error {
-	return services.ProcessETLEntityData(ctx, messageData)
+// deferMsg 等待 after 后原样重新发送消息，不占用 worker
+// 重新发送前不确认，等待期间进程中断时由 broker 重新投递；consumer 退出时放回队列
+func deferMsg(ctx context.Context, reg *registration, msg *bus.Delivery, after time.Duration) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := msg.Nack(true); err != nil {
+				lr.E().Error(err)
+			}
+			return
+		case <-time.After(after):
+		}
+
+		publishCtx, cancel := context.WithTimeout(context.Background(), retryPublishTimeout)
+		defer cancel()
+		if err := bus.Default().Publish(publishCtx, reg.queue, msg.Message()); err != nil {
+			lr.E().Errorf("Failed to republish deferred message %s to %s, requeue instead: %v", msg.ID, reg.queue, err)
+			if err := msg.Nack(true); err != nil {
+				lr.E().Error(err)
+			}
+			return
+		}
+		if err := msg.Ack(); err != nil {
+			lr.E().Error(err)
+		}
+	}()
 }
 
 func getEnv(key, defaultValue string) string {
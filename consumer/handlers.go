@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/model"
+	"back_ai_gun_data/services"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"errors"
+	"time"
+)
+
+func init() {
+	// 情报排序数据consumer，单条情报包含多轮新币检测，处理时间较长
+	// 重复投递的情报正在其他消费者处理时，等处理记录的租约过期后重新发送，不计入失败次数；失败 5 次后转入隔离队列
+	// 有价值的高分情报进入高优先级档，另有预留并发；已存在的队列无法修改参数，需配置 CONSUMER_INTELLIGENCE_MAX_PRIORITY 后使用新队列
+	// 处理时间主要是固定间隔的多轮检测，按 GMGN 等外部接口的请求耗时和失败比例判断过载，自动降低并发
+	Register(Handler[model.IntelligenceMessage]{
+		Name:        "intelligence",
+		Queue:       consts.QUEUE_INTELLIGENCE_SORT,
+		ConsumerTag: consts.CONSUMER_TAG_INTELLIGENCE,
+		Options: HandlerOptions{
+			Timeout:  10 * time.Minute,
+			Retry:    RetryPolicy{MaxAttempts: 5, Backoff: 30 * time.Second},
+			Priority: PriorityOptions{HighPriority: services.IntelligencePriorityHigh, Reserved: 10},
+			Adaptive: AdaptiveOptions{MinConcurrency: 10, TargetLatency: 5 * time.Second, MaxErrorRate: 0.2, Load: upstreamLoad},
+		},
+		Version: model.IntelligenceMessageVersion,
+		Upcasters: map[string]Upcaster{
+			"":  model.UpcastIntelligenceV1,
+			"1": model.UpcastIntelligenceV1,
+		},
+		Priority: services.IntelligencePriority,
+		Process:  processIntelligenceMessage,
+	})
+
+	// ETL实体数据consumer
+	Register(Handler[model.ETLEntityMessage]{
+		Name:        "etl_entity",
+		Queue:       consts.QUEUE_ETL_ENTITY_DATA,
+		ConsumerTag: consts.CONSUMER_TAG_ETL_ENTITY,
+		Options:     HandlerOptions{Timeout: 5 * time.Minute},
+		Process:     processETLEntityMessage,
+	})
+
+	// 隔离队列consumer，保存转入的消息供查看、修改后重新投递或丢弃
+	// 消息体可能不是合法JSON，按原样保存；PG 不可用时一直重新入队
+	Register(Handler[[]byte]{
+		Name:    "quarantine",
+		Queue:   QuarantineQueue,
+		Options: HandlerOptions{Timeout: 30 * time.Second, Retry: RetryPolicy{Backoff: 10 * time.Second}},
+		Decode: func(body []byte) (*[]byte, error) {
+			return &body, nil
+		},
+		Process: processQuarantinedMessage,
+	})
+}
+
+func processQuarantinedMessage(ctx context.Context, _ *[]byte) error {
+	return storeQuarantined(ctx, deliveryFromContext(ctx))
+}
+
+// upstreamLoad 外部接口在调整周期内的平均请求耗时和失败比例
+func upstreamLoad(window time.Duration) AdaptiveLoad {
+	latency, errorRate := remote_service.UpstreamLoad(window)
+	return AdaptiveLoad{Latency: latency, ErrorRate: errorRate}
+}
+
+// intelligenceRetryMargin 重复情报在租约之外多等待的时间，确保重新发送时原处理记录的心跳已过期
+const intelligenceRetryMargin = 10 * time.Second
+
+func processIntelligenceMessage(ctx context.Context, messageData *model.IntelligenceMessage) error {
+	err := services.ProcessIntelligenceData(ctx, messageData)
+	if errors.Is(err, services.ErrIntelligenceRunInProgress) {
+		return RetryLater(err, consts.INTELLIGENCE_RUN_LEASE+intelligenceRetryMargin)
+	}
+	return err
+}
+
+func processETLEntityMessage(ctx context.Context, messageData *model.ETLEntityMessage) error {
+	return services.ProcessETLEntityData(ctx, messageData)
+}
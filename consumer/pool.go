@@ -0,0 +1,178 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/health"
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"sync"
+	"time"
+)
+
+// 优先级档
+const (
+	PriorityBandHigh   = "high"
+	PriorityBandNormal = "normal"
+)
+
+type job struct {
+	msg      *bus.Delivery
+	prepared preparedMsg
+}
+
+// workerPool 按优先级分档处理消息
+// 普通 worker 有高优先级档的消息时先处理高优先级档，预留 worker 只处理高优先级档，低优先级消息积压时不会占满所有 worker
+type workerPool struct {
+	reg        *registration
+	high       chan job
+	normal     chan job
+	limiter    *limiter // 自适应并发时限制普通 worker 的并发
+	controller *adaptiveController
+	wg         sync.WaitGroup
+}
+
+// newWorkerPool 启动 worker，ctx 取消后 worker 处理完当前消息退出
+func newWorkerPool(ctx context.Context, reg *registration) *workerPool {
+	// 未确认消息不超过 prefetch，缓冲足够时投递不会阻塞
+	size := max(reg.options.Prefetch, reg.options.Concurrency, 1)
+	p := &workerPool{
+		reg:    reg,
+		high:   make(chan job, size),
+		normal: make(chan job, size),
+	}
+	workers := reg.options.Concurrency
+	if reg.options.Adaptive.enabled() {
+		p.limiter = newLimiter(0)
+		p.controller = newAdaptiveController(reg, p.limiter)
+		workers = p.controller.options.MaxConcurrency
+		limit := max(p.controller.options.MinConcurrency, min(workers, reg.options.Concurrency))
+		p.limiter.setLimit(limit)
+		recordConcurrencyLimit(reg.queue, limit)
+		go p.controller.run(ctx)
+	} else {
+		recordConcurrencyLimit(reg.queue, workers)
+	}
+	for i := 0; i < workers; i++ {
+		p.start(ctx, p.runShared)
+	}
+	reservedWorkers.Store(reg.queue, reg.options.Priority.Reserved)
+	for i := 0; i < reg.options.Priority.Reserved; i++ {
+		p.start(ctx, p.runReserved)
+	}
+	return p
+}
+
+func (p *workerPool) start(ctx context.Context, run func(ctx context.Context)) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		run(ctx)
+	}()
+}
+
+// submit 解码消息后放入对应的优先级档
+func (p *workerPool) submit(ctx context.Context, msg *bus.Delivery) {
+	prepared := p.reg.prepare(msg)
+	queue := p.normal
+	if p.reg.band(prepared.priority) == PriorityBandHigh {
+		queue = p.high
+	}
+	select {
+	case queue <- job{msg: msg, prepared: prepared}:
+	case <-ctx.Done():
+		if err := msg.Nack(true); err != nil {
+			lr.E().Error(err)
+		}
+	}
+}
+
+// prefetchUpdates 自适应并发调整后的预取数量，未启用时为 nil
+func (p *workerPool) prefetchUpdates() <-chan int {
+	if p.controller == nil {
+		return nil
+	}
+	return p.controller.prefetch
+}
+
+// acquire 依赖可用且并发未达上限时返回
+func (p *workerPool) acquire(ctx context.Context) error {
+	if err := health.WaitHealthy(ctx); err != nil {
+		return err
+	}
+	if p.limiter != nil {
+		return p.limiter.acquire(ctx)
+	}
+	return nil
+}
+
+func (p *workerPool) release() {
+	if p.limiter != nil {
+		p.limiter.release()
+	}
+}
+
+func (p *workerPool) runShared(ctx context.Context) {
+	for {
+		if err := p.acquire(ctx); err != nil {
+			return
+		}
+
+		// 先处理高优先级档
+		select {
+		case j := <-p.high:
+			p.handle(ctx, j)
+			p.release()
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			p.release()
+			return
+		case j := <-p.high:
+			p.handle(ctx, j)
+		case j := <-p.normal:
+			p.handle(ctx, j)
+		}
+		p.release()
+	}
+}
+
+func (p *workerPool) runReserved(ctx context.Context) {
+	for {
+		if err := health.WaitHealthy(ctx); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-p.high:
+			p.handle(ctx, j)
+		}
+	}
+}
+
+func (p *workerPool) handle(ctx context.Context, j job) {
+	if !j.msg.Timestamp.IsZero() {
+		recordQueueWait(p.reg.queue, p.reg.band(j.prepared.priority), time.Since(j.msg.Timestamp))
+	}
+	start := time.Now()
+	err := handleMsg(ctx, p.reg, j.msg, j.prepared)
+	if p.controller != nil {
+		p.controller.observe(time.Since(start), err != nil)
+	}
+}
+
+// stop 等待 worker 退出，未处理的消息放回队列
+func (p *workerPool) stop() {
+	p.wg.Wait()
+	for _, queue := range []chan job{p.high, p.normal} {
+		for len(queue) > 0 {
+			j := <-queue
+			if err := j.msg.Nack(true); err != nil {
+				lr.E().Error(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"back_ai_gun_data/pkg/model/dto"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// QuarantineQueue 隔离队列，可通过 QUARANTINE_QUEUE 覆盖
+// 转入的消息由 quarantine 处理器保存到 message_quarantine 表
+var QuarantineQueue = getEnv("QUARANTINE_QUEUE", consts.QUEUE_QUARANTINE)
+
+// 生产者压缩消息体时使用的编码
+const contentEncodingGzip = "gzip"
+
+// 隔离消息附带的头信息
+const (
+	QuarantineQueueHeader   = "x-original-queue"    // 原队列
+	QuarantineHandlerHeader = "x-handler"           // 处理器名称
+	QuarantineErrorHeader   = "x-error"             // 错误信息
+	QuarantineStackHeader   = "x-stack"             // 处理时 panic 的堆栈
+	ValidationErrorsHeader  = "x-validation-errors" // 字段校验错误，JSON数组
+	QuarantinedAtHeader     = "x-quarantined-at"    // 转入时间，毫秒时间戳
+	RequeuedFromHeader      = "x-requeued-from"     // 从隔离表重新投递时对应的记录ID
+)
+
+// 重新投递时去掉的头信息，重新计算失败次数
+var quarantineHeaders = []string{
+	QuarantineQueueHeader, QuarantineHandlerHeader, QuarantineErrorHeader, QuarantineStackHeader,
+	ValidationErrorsHeader, QuarantinedAtHeader, AttemptsHeader,
+}
+
+// panicError 处理消息时的 panic，保留堆栈用于排查
+type panicError struct {
+	value interface{}
+	stack string
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+type deliveryKey struct{}
+
+// withDelivery 处理消息时在 ctx 中带上原始消息，供需要头信息的处理器使用，dry-run 时记录为修改的来源
+func withDelivery(ctx context.Context, msg *bus.Delivery) context.Context {
+	ctx = dryrun.WithSource(ctx, msg.Queue+"/"+msg.ID)
+	return context.WithValue(ctx, deliveryKey{}, msg)
+}
+
+func deliveryFromContext(ctx context.Context) *bus.Delivery {
+	msg, _ := ctx.Value(deliveryKey{}).(*bus.Delivery)
+	return msg
+}
+
+// quarantineMsg 将处理失败的消息连同错误信息转入隔离队列，转入失败时放回原队列
+func quarantineMsg(reg *registration, msg *bus.Delivery, cause error, attempts int) {
+	// 隔离队列本身的消息无法保存时放回队列，避免循环转入
+	if reg.queue == QuarantineQueue {
+		lr.E().Errorf("Failed to store quarantined message %s, requeue: %v", msg.ID, cause)
+		if err := msg.Nack(true); err != nil {
+			lr.E().Error(err)
+		}
+		return
+	}
+
+	quarantined := msg.Message()
+	headers := make(map[string]interface{}, len(quarantined.Headers)+7)
+	for k, v := range quarantined.Headers {
+		headers[k] = v
+	}
+	headers[QuarantineQueueHeader] = reg.queue
+	headers[QuarantineHandlerHeader] = reg.name
+	headers[QuarantineErrorHeader] = cause.Error()
+	headers[QuarantinedAtHeader] = time.Now().UnixMilli()
+	headers[AttemptsHeader] = int64(attempts)
+	var validationErrors model.ValidationErrors
+	if errors.As(cause, &validationErrors) {
+		if data, err := json.Marshal(validationErrors); err == nil {
+			headers[ValidationErrorsHeader] = string(data)
+		}
+	}
+	var panicErr *panicError
+	if errors.As(cause, &panicErr) {
+		headers[QuarantineStackHeader] = panicErr.stack
+	}
+	quarantined.Headers = headers
+
+	publishCtx, cancel := context.WithTimeout(context.Background(), retryPublishTimeout)
+	defer cancel()
+	if err := bus.Default().Publish(publishCtx, QuarantineQueue, quarantined); err != nil {
+		lr.E().Errorf("Failed to quarantine message %s from queue %s, requeue instead: %v", msg.ID, reg.queue, err)
+		if err := msg.Nack(true); err != nil {
+			lr.E().Error(err)
+		}
+		return
+	}
+
+	lr.E().Errorf("Quarantined message %s from queue %s to %s: %v", msg.ID, reg.queue, QuarantineQueue, cause)
+	if err := msg.Ack(); err != nil {
+		lr.E().Error(err)
+	}
+}
+
+// storeQuarantined 保存隔离队列中的消息
+func storeQuarantined(ctx context.Context, msg *bus.Delivery) error {
+	if msg == nil {
+		return errors.New("quarantined delivery not found in context")
+	}
+	header := func(name string) string {
+		if value, ok := msg.Headers[name].(string); ok {
+			return value
+		}
+		return ""
+	}
+
+	record := &dto.QuarantinedMessage{
+		MessageID:       msg.ID,
+		Queue:           header(QuarantineQueueHeader),
+		Handler:         header(QuarantineHandlerHeader),
+		Body:            msg.Body,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Error:           header(QuarantineErrorHeader),
+		Attempts:        deliveryAttempts(msg),
+	}
+	if stack := header(QuarantineStackHeader); stack != "" {
+		record.Stack = &stack
+	}
+
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for k, v := range msg.Headers {
+		switch k {
+		case QuarantineQueueHeader, QuarantineHandlerHeader, QuarantineErrorHeader, QuarantineStackHeader, AttemptsHeader:
+			continue
+		}
+		headers[k] = v
+	}
+	if len(headers) > 0 {
+		data, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+		value := string(data)
+		record.Headers = &value
+	}
+	return dao.CreateQuarantinedMessage(ctx, record)
+}
+
+// QuarantineView 隔离消息详情，gzip 消息体解压后展示，头信息按原样展示
+type QuarantineView struct {
+	dto.QuarantinedMessage
+	Body    string                 `json:"body"`
+	Headers map[string]interface{} `json:"headers,omitempty"`
+}
+
+// NewQuarantineView 隔离消息详情，解压失败时按原样展示
+func NewQuarantineView(message dto.QuarantinedMessage) QuarantineView {
+	view := QuarantineView{QuarantinedMessage: message, Body: string(message.Body), Headers: quarantinedHeaders(message)}
+	if message.ContentEncoding == contentEncodingGzip {
+		body, err := gunzip(message.Body)
+		if err != nil {
+			lr.E().Errorf("Failed to decompress body of quarantined message %s: %v", message.ID, err)
+		} else {
+			view.Body = string(body)
+		}
+	}
+	return view
+}
+
+// quarantinedHeaders 解码隔离记录保存的头信息
+func quarantinedHeaders(message dto.QuarantinedMessage) map[string]interface{} {
+	if message.Headers == nil {
+		return nil
+	}
+	var headers map[string]interface{}
+	if err := json.Unmarshal([]byte(*message.Headers), &headers); err != nil {
+		lr.E().Errorf("Failed to decode headers of quarantined message %s: %v", message.ID, err)
+	}
+	return headers
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// RequeueQuarantined 将隔离消息重新投递到原队列，body 不为空时使用修改后的消息体（未压缩）
+func RequeueQuarantined(ctx context.Context, id string, body []byte) error {
+	record, err := dao.GetQuarantinedMessage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("quarantined message not found: %s", id)
+	}
+	if record.Queue == "" {
+		return fmt.Errorf("quarantined message %s has no original queue", id)
+	}
+
+	// 先占用记录，避免重复投递
+	if err := dao.TransitionQuarantinedMessage(ctx, id, dto.QuarantineStatusPending, dto.QuarantineStatusRequeued); err != nil {
+		return err
+	}
+
+	msg := requeueMessage(*record, body)
+	if err := bus.Default().Publish(ctx, record.Queue, msg); err != nil {
+		// 投递失败时恢复为待处理，可以再次重试
+		if revertErr := dao.TransitionQuarantinedMessage(context.WithoutCancel(ctx), id, dto.QuarantineStatusRequeued, dto.QuarantineStatusPending); revertErr != nil {
+			lr.E().Errorf("Failed to revert quarantined message %s: %v", id, revertErr)
+		}
+		return fmt.Errorf("requeue quarantined message %s to %s: %w", id, record.Queue, err)
+	}
+
+	lr.I().Infof("Requeued quarantined message %s to %s", id, record.Queue)
+	return nil
+}
+
+// requeueMessage 按隔离记录还原消息，去掉隔离时附加的头信息
+func requeueMessage(record dto.QuarantinedMessage, body []byte) bus.Message {
+	saved := quarantinedHeaders(record)
+	headers := make(map[string]interface{}, len(saved)+1)
+	for k, v := range saved {
+		headers[k] = v
+	}
+	for _, name := range quarantineHeaders {
+		delete(headers, name)
+	}
+	headers[RequeuedFromHeader] = record.ID
+
+	msg := bus.Message{
+		ID:              record.MessageID,
+		Body:            record.Body,
+		ContentType:     record.ContentType,
+		ContentEncoding: record.ContentEncoding,
+		Headers:         headers,
+	}
+	// 修改后的消息体是查看时解压后的明文，不再带原消息的编码
+	if len(body) > 0 {
+		msg.Body = body
+		msg.ContentEncoding = ""
+	}
+	return msg
+}
+
+// DiscardQuarantined 丢弃隔离消息，记录保留用于审计
+func DiscardQuarantined(ctx context.Context, id string) error {
+	if err := dao.TransitionQuarantinedMessage(ctx, id, dto.QuarantineStatusPending, dto.QuarantineStatusDiscarded); err != nil {
+		return err
+	}
+	lr.I().Infof("Discarded quarantined message %s", id)
+	return nil
+}
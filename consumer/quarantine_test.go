@@ -0,0 +1,101 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantinePanicWithStack(t *testing.T) {
+	b := useMemoryBus(t)
+	quarantine, err := b.Subscribe(context.Background(), QuarantineQueue, bus.SubscribeOptions{})
+	assert.NoError(t, err)
+
+	Register(Handler[testMessage]{
+		Name:    "test_quarantine_panic",
+		Queue:   "test-quarantine-panic",
+		Options: HandlerOptions{Retry: RetryPolicy{MaxAttempts: 2}},
+		Process: func(ctx context.Context, msg *testMessage) error {
+			panic("nil entity")
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_quarantine_panic"))
+
+	assert.NoError(t, b.Publish(ctx, "test-quarantine-panic", bus.Message{
+		ID:      "1",
+		Body:    []byte(`{"id":"1"}`),
+		Headers: map[string]interface{}{"trace-id": "t1"},
+	}))
+
+	select {
+	case msg := <-quarantine:
+		assert.Equal(t, "panic: nil entity", msg.Headers[QuarantineErrorHeader])
+		assert.NotEmpty(t, msg.Headers[QuarantineStackHeader])
+		assert.Equal(t, 2, deliveryAttempts(msg))
+		assert.Equal(t, "t1", msg.Headers["trace-id"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not quarantined")
+	}
+}
+
+func TestRequeueMessage(t *testing.T) {
+	headers := `{"trace-id":"t1","x-original-queue":"q","x-quarantined-at":1,"x-validation-errors":"[]"}`
+	record := dto.QuarantinedMessage{
+		ID:          "r1",
+		MessageID:   "m1",
+		Queue:       "q",
+		Body:        []byte(`{"id":"m1"}`),
+		ContentType: "application/json",
+		Headers:     &headers,
+	}
+
+	msg := requeueMessage(record, nil)
+	assert.Equal(t, "m1", msg.ID)
+	assert.Equal(t, `{"id":"m1"}`, string(msg.Body))
+	assert.Equal(t, "application/json", msg.ContentType)
+	assert.Equal(t, map[string]interface{}{"trace-id": "t1", RequeuedFromHeader: "r1"}, msg.Headers)
+
+	// 修改后的消息体替换原消息体
+	msg = requeueMessage(record, []byte(`{"id":"m1","fixed":true}`))
+	assert.Equal(t, `{"id":"m1","fixed":true}`, string(msg.Body))
+
+	view := NewQuarantineView(record)
+	assert.Equal(t, `{"id":"m1"}`, view.Body)
+	assert.Equal(t, "t1", view.Headers["trace-id"])
+}
+
+func TestRequeueGzipMessage(t *testing.T) {
+	initLogOnce.Do(lr.Init)
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, _ = writer.Write([]byte(`{"id":"m1"}`))
+	assert.NoError(t, writer.Close())
+	record := dto.QuarantinedMessage{ID: "r1", MessageID: "m1", Queue: "q", Body: buf.Bytes(), ContentEncoding: "gzip"}
+
+	// 查看时解压
+	assert.Equal(t, `{"id":"m1"}`, NewQuarantineView(record).Body)
+
+	// 原样重新投递时保留编码
+	msg := requeueMessage(record, nil)
+	assert.Equal(t, buf.Bytes(), msg.Body)
+	assert.Equal(t, "gzip", msg.ContentEncoding)
+
+	// 修改后的消息体是明文，去掉编码
+	msg = requeueMessage(record, []byte(`{"id":"m1","fixed":true}`))
+	assert.Equal(t, `{"id":"m1","fixed":true}`, string(msg.Body))
+	assert.Empty(t, msg.ContentEncoding)
+
+	// 解压失败时按原样展示
+	record.Body = []byte("not gzip")
+	assert.Equal(t, "not gzip", NewQuarantineView(record).Body)
+}
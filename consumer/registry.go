@@ -0,0 +1,315 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/consts"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttemptsHeader 消息已处理失败的次数，按重试策略重新入队时递增
+const AttemptsHeader = "x-attempts"
+
+// Handler 队列处理器：解码、校验、处理
+// 新增队列只需定义消息类型和 Handler，在 init 中调用 Register 注册
+type Handler[T any] struct {
+	Name        string // 处理器名称，用于配置和日志，eg intelligence
+	Queue       string // 消费的队列
+	ConsumerTag string // 消费者标识前缀
+	Options     HandlerOptions
+
+	// Version 消息当前版本号，为空时不检查版本
+	Version string
+	// Upcasters 旧版本到新版本的升级函数，按消息中的 version 查找
+	Upcasters map[string]Upcaster
+	// Decode 解码消息体，为空时按 Version 和 Upcasters 解码JSON
+	Decode func(body []byte) (*T, error)
+	// Validate 校验解码后的消息，为空时使用消息类型的 Validate 方法
+	Validate func(msg *T) error
+	// Priority 消息未指定优先级时按内容计算优先级，为空时为0
+	Priority func(msg *T) uint8
+	// Process 处理消息，返回 Permanent 包装的错误时不重试，直接转入隔离队列
+	Process func(ctx context.Context, msg *T) error
+}
+
+// HandlerOptions 处理器配置，可通过 CONSUMER_{NAME}_* 环境变量覆盖
+type HandlerOptions struct {
+	Prefetch    int           // 未确认消息上限，0 使用 PREFETCH
+	Concurrency int           // 并发处理数，0 使用 MAX_CONCURRENT
+	Timeout     time.Duration // 单条消息处理超时，0 不限制
+	Retry       RetryPolicy
+	Priority    PriorityOptions
+	Adaptive    AdaptiveOptions
+	Disabled    bool // 默认不启动，需在 CONSUMERS 中显式开启
+}
+
+// PriorityOptions 优先级配置
+// 不低于 HighPriority 的消息进入高优先级档，普通 worker 优先处理高优先级档，另有 Reserved 个 worker 只处理高优先级档
+type PriorityOptions struct {
+	MaxPriority  uint8 // 队列最大优先级，大于0时声明为 RabbitMQ 优先级队列
+	HighPriority uint8 // 高优先级档的下限，0 不分档
+	Reserved     int   // 为高优先级档预留的并发数，在 Concurrency 之外
+}
+
+// RetryPolicy 处理失败时的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多处理次数，达到后转入隔离队列；0 不限制，失败后一直重新入队
+	Backoff     time.Duration // 重新入队前等待的时间
+}
+
+// permanentError 不可重试的错误，消息转入隔离队列
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 标记错误不可重试，如消息格式错误
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var target *permanentError
+	return errors.As(err, &target)
+}
+
+// retryLaterError 需要稍后重试的错误，如同一消息正在其他消费者中处理
+type retryLaterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryLaterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryLaterError) Unwrap() error {
+	return e.err
+}
+
+// RetryLater 标记错误等待 after 后重新发送，不计入失败次数
+// 等待期间消息不确认，进程中断时由 broker 重新投递
+func RetryLater(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryLaterError{err: err, after: after}
+}
+
+func isRetryLater(err error) bool {
+	var target *retryLaterError
+	return errors.As(err, &target)
+}
+
+func retryLaterDelay(err error) time.Duration {
+	var target *retryLaterError
+	if !errors.As(err, &target) {
+		return 0
+	}
+	return target.after
+}
+
+// registration 注册后的处理器，消息类型已擦除
+type registration struct {
+	name        string
+	queue       string
+	consumerTag string
+	options     HandlerOptions
+	prepare     func(msg *bus.Delivery) preparedMsg
+}
+
+// preparedMsg 解码后待处理的消息
+type preparedMsg struct {
+	priority uint8
+	err      error // 解码或校验失败时不为空
+	process  func(ctx context.Context) error
+}
+
+// band 消息所在的优先级档
+func (r *registration) band(priority uint8) string {
+	high := r.options.Priority.HighPriority
+	if high > 0 && priority >= high {
+		return PriorityBandHigh
+	}
+	return PriorityBandNormal
+}
+
+var (
+	registry      = make(map[string]*registration)
+	registryOrder []string
+	registryMutex sync.RWMutex
+)
+
+// Register 注册队列处理器，名称或队列重复时 panic
+func Register[T any](h Handler[T]) {
+	if h.Name == "" || h.Queue == "" || h.Process == nil {
+		panic(fmt.Sprintf("consumer handler %q for queue %q is incomplete", h.Name, h.Queue))
+	}
+
+	decode := h.Decode
+	switch {
+	case decode != nil:
+	case h.Version != "":
+		decode = decodeVersioned[T](h.Version, h.Upcasters)
+	default:
+		decode = decodeJSON[T]
+	}
+	validate := h.Validate
+	if validate == nil {
+		validate = func(msg *T) error {
+			if v, ok := any(msg).(validator); ok {
+				return v.Validate()
+			}
+			return nil
+		}
+	}
+	consumerTag := h.ConsumerTag
+	if consumerTag == "" {
+		consumerTag = h.Name + "-consumer"
+	}
+
+	reg := &registration{
+		name:        h.Name,
+		queue:       h.Queue,
+		consumerTag: consumerTag,
+		options:     h.Options.withEnv(h.Name),
+		prepare: func(msg *bus.Delivery) (prepared preparedMsg) {
+			prepared.priority = msg.Priority
+			defer func() {
+				if r := recover(); r != nil {
+					prepared.err = invalidMessage(fmt.Errorf("decode %s message: panic: %v", h.Name, r))
+				}
+			}()
+
+			data, err := decode(msg.Body)
+			if err != nil {
+				prepared.err = invalidMessage(fmt.Errorf("decode %s message: %w", h.Name, err))
+				return prepared
+			}
+			if err := validate(data); err != nil {
+				prepared.err = invalidMessage(fmt.Errorf("validate %s message: %w", h.Name, err))
+				return prepared
+			}
+			if prepared.priority == 0 && h.Priority != nil {
+				prepared.priority = h.Priority(data)
+			}
+			prepared.process = func(ctx context.Context) error {
+				return h.Process(ctx, data)
+			}
+			return prepared
+		},
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exists := registry[reg.name]; exists {
+		panic(fmt.Sprintf("consumer handler %q already registered", reg.name))
+	}
+	for _, existing := range registry {
+		if existing.queue == reg.queue {
+			panic(fmt.Sprintf("queue %q already handled by %q", reg.queue, existing.name))
+		}
+	}
+	registry[reg.name] = reg
+	registryOrder = append(registryOrder, reg.name)
+	bus.SetQueueMaxPriority(reg.queue, reg.options.Priority.MaxPriority)
+}
+
+func decodeJSON[T any](body []byte) (*T, error) {
+	var data T
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// enabledRegistrations 需要启动的处理器
+// CONSUMERS 为逗号分隔的处理器名称，为空时启动所有未禁用的处理器
+func enabledRegistrations() []*registration {
+	enabled := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv("CONSUMERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			enabled[name] = true
+		}
+	}
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	regs := make([]*registration, 0, len(registryOrder))
+	for _, name := range registryOrder {
+		reg := registry[name]
+		if len(enabled) > 0 && !enabled[name] {
+			continue
+		}
+		if len(enabled) == 0 && reg.options.Disabled {
+			continue
+		}
+		regs = append(regs, reg)
+	}
+	return regs
+}
+
+// withEnv 按环境变量覆盖配置，未配置的并发和预取使用全局配置
+func (o HandlerOptions) withEnv(name string) HandlerOptions {
+	prefix := "CONSUMER_" + strings.ToUpper(name) + "_"
+
+	if o.Prefetch <= 0 {
+		o.Prefetch = getEnvInt("PREFETCH", consts.DEFAULT_PREFETCH_COUNT)
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = getEnvInt("MAX_CONCURRENT", consts.DEFAULT_MAX_CONCURRENT)
+	}
+	o.Prefetch = getEnvInt(prefix+"PREFETCH", o.Prefetch)
+	o.Concurrency = getEnvInt(prefix+"CONCURRENCY", o.Concurrency)
+	o.Timeout = getEnvDuration(prefix+"TIMEOUT_SECONDS", time.Second, o.Timeout)
+	o.Retry.MaxAttempts = getEnvInt(prefix+"MAX_ATTEMPTS", o.Retry.MaxAttempts)
+	o.Retry.Backoff = getEnvDuration(prefix+"RETRY_BACKOFF_MS", time.Millisecond, o.Retry.Backoff)
+	o.Priority.MaxPriority = uint8(getEnvInt(prefix+"MAX_PRIORITY", int(o.Priority.MaxPriority)))
+	o.Priority.HighPriority = uint8(getEnvInt(prefix+"HIGH_PRIORITY", int(o.Priority.HighPriority)))
+	o.Priority.Reserved = getEnvInt(prefix+"RESERVED", o.Priority.Reserved)
+	o.Adaptive.MinConcurrency = getEnvInt(prefix+"MIN_CONCURRENCY", o.Adaptive.MinConcurrency)
+	o.Adaptive.MaxConcurrency = getEnvInt(prefix+"MAX_CONCURRENCY", o.Adaptive.MaxConcurrency)
+	o.Adaptive.TargetLatency = getEnvDuration(prefix+"TARGET_LATENCY_MS", time.Millisecond, o.Adaptive.TargetLatency)
+	o.Adaptive.MaxErrorRate = float64(getEnvInt(prefix+"MAX_ERROR_PERCENT", int(o.Adaptive.MaxErrorRate*100))) / 100
+	o.Adaptive.Interval = getEnvDuration(prefix+"ADJUST_INTERVAL_SECONDS", time.Second, o.Adaptive.Interval)
+	return o
+}
+
+// getEnvDuration 环境变量为 unit 的整数倍，未配置时使用默认值
+func getEnvDuration(key string, unit, defaultValue time.Duration) time.Duration {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+	return time.Duration(getEnvInt(key, int(defaultValue/unit))) * unit
+}
+
+// deliveryAttempts 消息已处理失败的次数
+func deliveryAttempts(msg *bus.Delivery) int {
+	switch v := msg.Headers[AttemptsHeader].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,357 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMessage struct {
+	ID string `json:"id"`
+}
+
+var initLogOnce sync.Once
+
+func useMemoryBus(t *testing.T) *bus.MemoryBus {
+	t.Helper()
+	initLogOnce.Do(lr.Init)
+	b := bus.NewMemoryBus()
+	bus.SetDefault(b)
+	return b
+}
+
+func lookup(t *testing.T, name string) *registration {
+	t.Helper()
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	reg, ok := registry[name]
+	if !ok {
+		t.Fatalf("handler %s not registered", name)
+	}
+	return reg
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func TestDefaultHandlersRegistered(t *testing.T) {
+	assert.Equal(t, "dogex-sub-dev", lookup(t, "intelligence").queue)
+	assert.Equal(t, "etl-entity-data", lookup(t, "etl_entity").queue)
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	assert.Panics(t, func() {
+		Register(Handler[testMessage]{Name: "intelligence", Queue: "other", Process: func(context.Context, *testMessage) error { return nil }})
+	})
+	assert.Panics(t, func() {
+		Register(Handler[testMessage]{Name: "other", Queue: "dogex-sub-dev", Process: func(context.Context, *testMessage) error { return nil }})
+	})
+	assert.Panics(t, func() {
+		Register(Handler[testMessage]{Name: "incomplete", Queue: "incomplete"})
+	})
+}
+
+func TestEnabledRegistrations(t *testing.T) {
+	Register(Handler[testMessage]{
+		Name:    "test_disabled",
+		Queue:   "test-disabled",
+		Options: HandlerOptions{Disabled: true},
+		Process: func(context.Context, *testMessage) error { return nil },
+	})
+
+	names := func() []string {
+		var result []string
+		for _, reg := range enabledRegistrations() {
+			result = append(result, reg.name)
+		}
+		return result
+	}
+
+	t.Setenv("CONSUMERS", "")
+	assert.Contains(t, names(), "intelligence")
+	assert.NotContains(t, names(), "test_disabled")
+
+	t.Setenv("CONSUMERS", "test_disabled, etl_entity")
+	assert.ElementsMatch(t, []string{"etl_entity", "test_disabled"}, names())
+}
+
+func TestHandlerOptionsWithEnv(t *testing.T) {
+	t.Setenv("PREFETCH", "7")
+	t.Setenv("CONSUMER_DEMO_CONCURRENCY", "3")
+	t.Setenv("CONSUMER_DEMO_TIMEOUT_SECONDS", "30")
+	t.Setenv("CONSUMER_DEMO_MAX_ATTEMPTS", "4")
+
+	options := HandlerOptions{Timeout: time.Minute, Retry: RetryPolicy{Backoff: time.Second}}.withEnv("demo")
+	assert.Equal(t, 7, options.Prefetch)
+	assert.Equal(t, 3, options.Concurrency)
+	assert.Equal(t, 30*time.Second, options.Timeout)
+	assert.Equal(t, 4, options.Retry.MaxAttempts)
+	assert.Equal(t, time.Second, options.Retry.Backoff)
+}
+
+func TestConsumerDispatch(t *testing.T) {
+	b := useMemoryBus(t)
+
+	var processed atomic.Int32
+	var failures atomic.Int32
+	Register(Handler[testMessage]{
+		Name:    "test_dispatch",
+		Queue:   "test-dispatch",
+		Options: HandlerOptions{Retry: RetryPolicy{MaxAttempts: 3}},
+		Validate: func(msg *testMessage) error {
+			if msg.ID == "" {
+				return errors.New("id is required")
+			}
+			return nil
+		},
+		Process: func(ctx context.Context, msg *testMessage) error {
+			switch msg.ID {
+			case "fail":
+				failures.Add(1)
+				return errors.New("upstream unavailable")
+			case "permanent":
+				failures.Add(1)
+				return Permanent(errors.New("bad data"))
+			}
+			processed.Add(1)
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_dispatch"))
+
+	publish := func(body string) {
+		assert.NoError(t, b.Publish(ctx, "test-dispatch", bus.Message{ID: body, Body: []byte(body)}))
+	}
+	publish(`{"id":"ok"}`)
+	publish(`not json`)
+	publish(`{}`)
+	publish(`{"id":"permanent"}`)
+	publish(`{"id":"fail"}`)
+
+	// 成功 1 次；格式错误、校验失败、不可重试错误转入隔离队列；可重试错误共处理 MaxAttempts 次后转入隔离队列
+	waitFor(t, func() bool { return processed.Load() == 1 && failures.Load() == 4 })
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(4), failures.Load())
+	assert.Equal(t, 0, b.Len("test-dispatch"))
+	assert.Equal(t, 4, b.Len(QuarantineQueue))
+}
+
+func TestConsumerRequeueWithoutAttemptLimit(t *testing.T) {
+	b := useMemoryBus(t)
+
+	var calls atomic.Int32
+	Register(Handler[testMessage]{
+		Name:  "test_requeue",
+		Queue: "test-requeue",
+		Process: func(ctx context.Context, msg *testMessage) error {
+			if calls.Add(1) < 3 {
+				panic("flaky")
+			}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_requeue"))
+
+	assert.NoError(t, b.Publish(ctx, "test-requeue", bus.Message{ID: "1", Body: []byte(`{"id":"1"}`)}))
+	waitFor(t, func() bool { return calls.Load() == 3 })
+	assert.Equal(t, 0, b.Len("test-requeue"))
+}
+
+func TestConsumerRetryLater(t *testing.T) {
+	b := useMemoryBus(t)
+
+	var calls atomic.Int32
+	Register(Handler[testMessage]{
+		Name:    "test_retry_later",
+		Queue:   "test-retry-later",
+		Options: HandlerOptions{Retry: RetryPolicy{MaxAttempts: 1}},
+		Process: func(ctx context.Context, msg *testMessage) error {
+			if calls.Add(1) < 3 {
+				return RetryLater(errors.New("being processed"), 20*time.Millisecond)
+			}
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_retry_later"))
+
+	// 稍后重试不计入失败次数，MaxAttempts 为 1 也不会转入隔离队列
+	assert.NoError(t, b.Publish(ctx, "test-retry-later", bus.Message{ID: "1", Body: []byte(`{"id":"1"}`)}))
+	waitFor(t, func() bool { return calls.Load() == 3 })
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, 0, b.Len("test-retry-later"))
+	assert.Equal(t, 0, b.Len(QuarantineQueue))
+}
+
+func TestConsumerTimeout(t *testing.T) {
+	b := useMemoryBus(t)
+
+	var calls atomic.Int32
+	Register(Handler[testMessage]{
+		Name:    "test_timeout",
+		Queue:   "test-timeout",
+		Options: HandlerOptions{Timeout: 20 * time.Millisecond, Retry: RetryPolicy{MaxAttempts: 2}},
+		Process: func(ctx context.Context, msg *testMessage) error {
+			calls.Add(1)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_timeout"))
+
+	assert.NoError(t, b.Publish(ctx, "test-timeout", bus.Message{ID: "1", Body: []byte(`{"id":"1"}`)}))
+	// 超时按重试策略处理：重试一次后转入隔离队列
+	waitFor(t, func() bool { return HandlerTimeouts("test-timeout") == 2 })
+	assert.Equal(t, int32(2), calls.Load())
+	waitFor(t, func() bool { return b.Len(QuarantineQueue) == 1 })
+	assert.Equal(t, 0, b.Len("test-timeout"))
+}
+
+func TestVersionedDecode(t *testing.T) {
+	decode := decodeVersioned[model.IntelligenceMessage](model.IntelligenceMessageVersion, map[string]Upcaster{
+		"1": model.UpcastIntelligenceV1,
+	})
+
+	msg, err := decode([]byte(`{"version":"1","data":{"id":"i1","published_at":1748779200}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "i1", msg.ID)
+	assert.Equal(t, model.IntelligenceMessageVersion, msg.Version)
+	assert.Equal(t, "1748779200", msg.Data.PublishedAt)
+	// 1 版本不带 is_visible，按可见处理
+	assert.True(t, msg.Data.IsVisible)
+
+	msg, err = decode([]byte(`{"id":"i2","version":2,"data":{"published_at":"2025-06-01 12:00:00"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "i2", msg.ID)
+
+	_, err = decode([]byte(`{"id":"i3","version":"9"}`))
+	assert.ErrorContains(t, err, "unsupported message version")
+}
+
+func TestQuarantineInvalidMessage(t *testing.T) {
+	b := useMemoryBus(t)
+	quarantine, err := b.Subscribe(context.Background(), QuarantineQueue, bus.SubscribeOptions{})
+	assert.NoError(t, err)
+
+	var processed atomic.Int32
+	Register(Handler[model.IntelligenceMessage]{
+		Name:    "test_quarantine",
+		Queue:   "test-quarantine",
+		Version: model.IntelligenceMessageVersion,
+		Process: func(ctx context.Context, msg *model.IntelligenceMessage) error {
+			processed.Add(1)
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, lookup(t, "test_quarantine"))
+
+	body := `{"id":"i1","version":"2","data":{"published_at":"yesterday","entities":[{"name":""}]}}`
+	assert.NoError(t, b.Publish(ctx, "test-quarantine", bus.Message{ID: "i1", Body: []byte(body)}))
+
+	select {
+	case msg := <-quarantine:
+		assert.Equal(t, body, string(msg.Body))
+		assert.Equal(t, "test-quarantine", msg.Headers[QuarantineQueueHeader])
+		assert.Equal(t, "test_quarantine", msg.Headers[QuarantineHandlerHeader])
+		assert.JSONEq(t, `[{"field":"data.published_at","message":"invalid time format \"yesterday\""},{"field":"data.entities[0].name","message":"is required"}]`,
+			msg.Headers[ValidationErrorsHeader].(string))
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not quarantined")
+	}
+	assert.Equal(t, int32(0), processed.Load())
+	assert.Equal(t, 0, b.Len("test-quarantine"))
+}
+
+func TestConsumerReservedPriorityWorkers(t *testing.T) {
+	b := useMemoryBus(t)
+
+	release := make(chan struct{})
+	var order []string
+	var mutex sync.Mutex
+	Register(Handler[testMessage]{
+		Name:  "test_priority",
+		Queue: "test-priority",
+		Options: HandlerOptions{
+			Prefetch:    10,
+			Concurrency: 1,
+			Priority:    PriorityOptions{HighPriority: 5, Reserved: 1},
+		},
+		Priority: func(msg *testMessage) uint8 {
+			if msg.ID == "high" {
+				return 9
+			}
+			return 1
+		},
+		Process: func(ctx context.Context, msg *testMessage) error {
+			if msg.ID == "blocking" {
+				<-release
+			}
+			mutex.Lock()
+			order = append(order, msg.ID)
+			mutex.Unlock()
+			return nil
+		},
+	})
+	reg := lookup(t, "test_priority")
+	assert.Equal(t, PriorityBandHigh, reg.band(9))
+	assert.Equal(t, PriorityBandNormal, reg.band(4))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, reg)
+
+	publish := func(id string) {
+		assert.NoError(t, b.Publish(ctx, "test-priority", bus.Message{ID: id, Body: []byte(`{"id":"` + id + `"}`)}))
+	}
+	// 普通 worker 被占用时，高优先级消息由预留 worker 处理
+	publish("blocking")
+	waitFor(t, func() bool { return QueueWait("test-priority", PriorityBandNormal).Count == 1 })
+	publish("low")
+	publish("high")
+	waitFor(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(order) == 1
+	})
+	close(release)
+	waitFor(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(order) == 3
+	})
+	assert.Equal(t, []string{"high", "blocking", "low"}, order)
+	assert.Equal(t, int64(1), QueueWait("test-priority", PriorityBandHigh).Count)
+	assert.Equal(t, int64(2), QueueWait("test-priority", PriorityBandNormal).Count)
+}
@@ -0,0 +1,136 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReplayOptions 从归档重放消息的条件
+type ReplayOptions struct {
+	Handler   string    // 处理器名称，重放该处理器队列的归档
+	From, To  time.Time // 收到消息的时间范围 [From, To)，为零时不限制
+	IDs       []string  // 只重放这些消息ID或消息体中的 id，为空时不限制
+	Live      bool      // 为 false 时只解码和校验，不调用处理器
+	Reprocess bool      // 消息体中设置 reprocess，忽略已完成的处理记录
+	Limit     int       // 最多重放的消息数量，0 使用 DEFAULT_MAX_HISTORICAL_MESSAGES
+}
+
+// ReplayResult 重放结果
+type ReplayResult struct {
+	Matched   int // 符合条件的消息
+	Processed int // 处理成功的消息，dry-run 时为校验通过的消息
+	Invalid   int // 解码或校验失败的消息
+	Failed    int // 处理失败的消息
+}
+
+// Replay 按时间范围或ID从归档重放消息，使用与消费时相同的处理器，按收到的顺序逐条处理
+// 处理失败时不重试也不转入隔离队列，只计入结果
+func Replay(ctx context.Context, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	if ArchiveDir == "" {
+		return result, errors.New("message archive disabled, ARCHIVE_DIR is empty")
+	}
+	registryMutex.RLock()
+	reg, ok := registry[opts.Handler]
+	registryMutex.RUnlock()
+	if !ok {
+		return result, fmt.Errorf("unknown consumer handler %q", opts.Handler)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = consts.DEFAULT_MAX_HISTORICAL_MESSAGES
+	}
+	ids := make(map[string]bool, len(opts.IDs))
+	for _, id := range opts.IDs {
+		ids[id] = true
+	}
+
+	err := readArchive(ArchiveDir, reg.queue, opts.From, opts.To, func(archived ArchivedMessage) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if len(ids) > 0 && !ids[archived.ID] && !ids[bodyID(archived.Body)] {
+			return true
+		}
+		result.Matched++
+
+		msg := archived.Delivery()
+		if opts.Reprocess {
+			msg.Body = withReprocess(msg.Body)
+		}
+		prepared := reg.prepare(msg)
+		if prepared.err != nil {
+			result.Invalid++
+			lr.E().Errorf("Replay %s message %s received at %s is invalid: %v", reg.name, archived.ID, archived.ReceivedAt, prepared.err)
+			return result.Matched < limit
+		}
+		if !opts.Live {
+			result.Processed++
+			lr.I().Infof("Replay %s message %s received at %s (dry-run)", reg.name, archived.ID, archived.ReceivedAt)
+			return result.Matched < limit
+		}
+
+		if err := replayMsg(ctx, reg, msg, prepared); err != nil {
+			result.Failed++
+			lr.E().Errorf("Replay %s message %s received at %s failed: %v", reg.name, archived.ID, archived.ReceivedAt, err)
+		} else {
+			result.Processed++
+			lr.I().Infof("Replayed %s message %s received at %s", reg.name, archived.ID, archived.ReceivedAt)
+		}
+		return result.Matched < limit
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	return result, err
+}
+
+// replayMsg 按处理器的超时处理一条重放的消息
+func replayMsg(ctx context.Context, reg *registration, msg *bus.Delivery, prepared preparedMsg) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: utils.GetStack()}
+		}
+	}()
+
+	ctx = withDelivery(ctx, msg)
+	if reg.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, reg.options.Timeout)
+		defer cancel()
+	}
+	return prepared.process(ctx)
+}
+
+// bodyID 消息体中的 id 字段
+func bodyID(body []byte) string {
+	var msg struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return ""
+	}
+	return msg.ID
+}
+
+// withReprocess 在JSON消息体中设置 reprocess，不是JSON对象时原样返回
+func withReprocess(body []byte) []byte {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return body
+	}
+	doc["reprocess"] = json.RawMessage("true")
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return data
+}
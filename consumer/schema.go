@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Upcaster 将旧版本的消息升级到新版本，直接修改 doc，返回升级后的版本号
+// doc 中的数字为 json.Number，避免大整数丢失精度
+type Upcaster func(doc map[string]interface{}) (string, error)
+
+// validator 消息类型自带的校验方法，Handler 未指定 Validate 时使用
+type validator interface {
+	Validate() error
+}
+
+// invalidMessageError 解码或校验失败的消息，转入隔离队列
+type invalidMessageError struct {
+	err error
+}
+
+func (e *invalidMessageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidMessageError) Unwrap() error {
+	return e.err
+}
+
+func invalidMessage(err error) error {
+	return &invalidMessageError{err: err}
+}
+
+func isInvalidMessage(err error) bool {
+	var target *invalidMessageError
+	return errors.As(err, &target)
+}
+
+// decodeVersioned 按消息中的 version 字段解码
+// 版本与 current 一致时直接解码，否则依次调用 upcasters 升级到 current，没有对应 upcaster 的版本视为无效消息
+func decodeVersioned[T any](current string, upcasters map[string]Upcaster) func(body []byte) (*T, error) {
+	return func(body []byte) (*T, error) {
+		var header struct {
+			Version json.RawMessage `json:"version"`
+		}
+		if err := json.Unmarshal(body, &header); err != nil {
+			return nil, err
+		}
+		version, isString := rawVersion(header.Version)
+		if version == current && isString {
+			return decodeJSON[T](body)
+		}
+
+		doc := make(map[string]interface{})
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+		// 每个版本最多升级一次，防止 upcaster 配置成环
+		for steps := 0; version != current; steps++ {
+			upcast, ok := upcasters[version]
+			if !ok || steps >= len(upcasters) {
+				return nil, fmt.Errorf("unsupported message version %q, current %q", version, current)
+			}
+			next, err := upcast(doc)
+			if err != nil {
+				return nil, fmt.Errorf("upcast message version %q: %w", version, err)
+			}
+			version = next
+		}
+		doc["version"] = current
+
+		upgraded, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSON[T](upgraded)
+	}
+}
+
+// rawVersion 兼容字符串和数字形式的版本号，缺失时为空字符串
+// 非字符串形式的版本号需要重新编码后才能解码到 BaseMessage.Version
+func rawVersion(raw json.RawMessage) (string, bool) {
+	var version string
+	if err := json.Unmarshal(raw, &version); err == nil {
+		return version, true
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number.String(), false
+	}
+	return "", false
+}
@@ -1,32 +1,18 @@
 package consumer
 
 import (
+	"back_ai_gun_data/pkg/lr"
 	"context"
 )
 
-// 启动所有消费者
+// 启动所有已注册且启用的消费者，CONSUMERS 可指定启用的处理器
 func StartAllConsumers(ctx context.Context) {
-	// 启动情报排序数据consumer
-	go StartIntelligenceConsumer(ctx)
-
-	// 启动ETL实体数据consumer
-	go StartETLEntityConsumer(ctx)
-}
-
-// 启动数据处理器消费者（示例）
-func StartDataProcessConsumer(ctx context.Context) {
-	// 实现数据处理的消费者逻辑
-	// 可以处理不同类型的数据
-}
-
-// 启动通知消费者（示例）
-func StartNotificationConsumer(ctx context.Context) {
-	// 实现通知相关的消费者逻辑
-	// 可以发送邮件、短信等通知
-}
-
-// 启动分析消费者（示例）
-func StartAnalyticsConsumer(ctx context.Context) {
-	// 实现数据分析的消费者逻辑
-	// 可以处理统计、报表等任务
+	regs := enabledRegistrations()
+	if len(regs) == 0 {
+		lr.E().Error("No consumer handler enabled")
+		return
+	}
+	for _, reg := range regs {
+		startRegistered(ctx, reg)
+	}
 }
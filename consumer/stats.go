@@ -0,0 +1,165 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 处理结果
+const (
+	outcomeAcked       = "acked"
+	outcomeRequeued    = "requeued" // 退出时中断，放回队列
+	outcomeTimeout     = "timeout"
+	outcomeFailed      = "failed" // 按重试策略处理
+	outcomeQuarantined = "quarantined"
+	outcomePanic       = "panic"
+	outcomeDeferred    = "deferred" // 稍后重新发送
+)
+
+var (
+	consumedMessages = metrics.NewCounterVec("consumer_messages_consumed_total", "Deliveries received per queue.", "queue")
+	handlerDuration  = metrics.NewHistogramVec("consumer_handler_duration_seconds", "Handler duration per queue and outcome.", nil, "queue", "outcome")
+	inFlightMessages = metrics.NewGaugeVec("consumer_in_flight", "Messages being handled per queue.", "queue")
+	timeoutMessages  = metrics.NewCounterVec("consumer_handler_timeouts_total", "Messages whose handler timed out per queue.", "queue")
+	queueWaitSeconds = metrics.NewHistogramVec("consumer_queue_wait_seconds", "Time from publish to handling per queue and priority band.",
+		[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900}, "queue", "band")
+	concurrencyLimitGauge = metrics.NewGaugeVec("consumer_concurrency_limit", "Current concurrency limit of shared workers per queue.", "queue")
+)
+
+func init() {
+	metrics.MustRegister(saturationCollector{desc: prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "consumer_saturation_ratio"),
+		"In-flight messages over worker capacity (limit plus reserved workers) per queue.", []string{"queue"}, nil)})
+}
+
+// 各队列处理中的消息数量
+var inFlightCounts sync.Map // queue -> *atomic.Int64
+
+// trackInFlight 记录开始处理一条消息，返回处理结束时调用的函数
+func trackInFlight(queue string) (done func()) {
+	value, _ := inFlightCounts.LoadOrStore(queue, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+	gauge := inFlightMessages.WithLabelValues(queue)
+	counter.Add(1)
+	gauge.Inc()
+	return func() {
+		counter.Add(-1)
+		gauge.Dec()
+	}
+}
+
+// InFlight 队列处理中的消息数量
+func InFlight(queue string) int64 {
+	if counter, ok := inFlightCounts.Load(queue); ok {
+		return counter.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// 各队列处理超时的消息数量
+var handlerTimeouts sync.Map // queue -> *atomic.Int64
+
+func recordTimeout(queue string) {
+	counter, _ := handlerTimeouts.LoadOrStore(queue, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+	timeoutMessages.WithLabelValues(queue).Inc()
+}
+
+// HandlerTimeouts 队列处理超时的消息数量
+func HandlerTimeouts(queue string) int64 {
+	if counter, ok := handlerTimeouts.Load(queue); ok {
+		return counter.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// WaitStats 消息从发送到开始处理的等待时间
+type WaitStats struct {
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Avg 平均等待时间
+func (s WaitStats) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+type waitRecorder struct {
+	mutex sync.Mutex
+	stats WaitStats
+}
+
+// 各队列各优先级档的等待时间
+var queueWaits sync.Map // queue|band -> *waitRecorder
+
+func recordQueueWait(queue, band string, wait time.Duration) {
+	value, _ := queueWaits.LoadOrStore(queue+"|"+band, new(waitRecorder))
+	recorder := value.(*waitRecorder)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.stats.Count++
+	recorder.stats.Total += wait
+	recorder.stats.Max = max(recorder.stats.Max, wait)
+	queueWaitSeconds.WithLabelValues(queue, band).Observe(wait.Seconds())
+}
+
+// QueueWait 队列某个优先级档的等待时间统计
+func QueueWait(queue, band string) WaitStats {
+	if value, ok := queueWaits.Load(queue + "|" + band); ok {
+		recorder := value.(*waitRecorder)
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		return recorder.stats
+	}
+	return WaitStats{}
+}
+
+// 各队列当前的并发上限
+var concurrencyLimits sync.Map // queue -> *atomic.Int64
+
+func recordConcurrencyLimit(queue string, limit int) {
+	value, _ := concurrencyLimits.LoadOrStore(queue, new(atomic.Int64))
+	value.(*atomic.Int64).Store(int64(limit))
+	concurrencyLimitGauge.WithLabelValues(queue).Set(float64(limit))
+}
+
+// 各队列为高优先级档预留的 worker 数量
+var reservedWorkers sync.Map // queue -> int
+
+// saturationCollector 采集时计算各队列处理中的消息占 worker 总数的比例
+type saturationCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c saturationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c saturationCollector) Collect(ch chan<- prometheus.Metric) {
+	concurrencyLimits.Range(func(key, value any) bool {
+		queue := key.(string)
+		capacity := value.(*atomic.Int64).Load()
+		if reserved, ok := reservedWorkers.Load(queue); ok {
+			capacity += int64(reserved.(int))
+		}
+		if capacity > 0 {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(InFlight(queue))/float64(capacity), queue)
+		}
+		return true
+	})
+}
+
+// ConcurrencyLimit 队列当前的并发上限，自适应并发时随处理情况变化
+func ConcurrencyLimit(queue string) int {
+	if value, ok := concurrencyLimits.Load(queue); ok {
+		return int(value.(*atomic.Int64).Load())
+	}
+	return 0
+}
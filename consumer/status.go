@@ -0,0 +1,60 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+)
+
+// 消费者运行状态
+const (
+	StateStarting     = "starting"     // 正在订阅队列
+	StateRunning      = "running"      // 正在消费
+	StatePaused       = "paused"       // 依赖不可用，暂停拉取消息
+	StateReconnecting = "reconnecting" // 订阅失败或连接、通道关闭，退避后重新订阅
+	StateStopped      = "stopped"      // ctx 取消后正常退出
+	StateFailed       = "failed"       // panic 后退出，不会自动重启
+)
+
+// ConsumerStatus 消费者的运行状态
+type ConsumerStatus struct {
+	Name  string    `json:"name"`
+	Queue string    `json:"queue"`
+	State string    `json:"state"`
+	Error string    `json:"error,omitempty"`
+	Since time.Time `json:"since"` // 进入当前状态的时间
+}
+
+var (
+	statusMutex sync.RWMutex
+	statuses    = make(map[string]*ConsumerStatus)
+	statusOrder []string
+)
+
+func setConsumerState(reg *registration, state string, err error) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+
+	status, ok := statuses[reg.name]
+	if !ok {
+		status = &ConsumerStatus{Name: reg.name, Queue: reg.queue}
+		statuses[reg.name] = status
+		statusOrder = append(statusOrder, reg.name)
+	}
+	status.State = state
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.Since = time.Now()
+}
+
+// ConsumerStatuses 已启动的消费者状态，按启动顺序
+func ConsumerStatuses() []ConsumerStatus {
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+	result := make([]ConsumerStatus, 0, len(statusOrder))
+	for _, name := range statusOrder {
+		result = append(result, *statuses[name])
+	}
+	return result
+}
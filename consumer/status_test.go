@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func consumerStatus(name string) ConsumerStatus {
+	for _, status := range ConsumerStatuses() {
+		if status.Name == name {
+			return status
+		}
+	}
+	return ConsumerStatus{}
+}
+
+func TestConsumerStatus(t *testing.T) {
+	b := useMemoryBus(t)
+
+	Register(Handler[testMessage]{
+		Name:    "test_status",
+		Queue:   "test-status",
+		Process: func(ctx context.Context, msg *testMessage) error { return nil },
+	})
+	reg := lookup(t, "test_status")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startRegistered(ctx, reg)
+	waitFor(t, func() bool { return consumerStatus("test_status").State == StateRunning })
+	assert.Equal(t, "test-status", consumerStatus("test_status").Queue)
+
+	assert.NoError(t, b.Publish(ctx, "test-status", bus.Message{ID: "1", Body: []byte(`{"id":"1"}`)}))
+	waitFor(t, func() bool { return b.Len("test-status") == 0 && InFlight("test-status") == 0 })
+	assert.Equal(t, float64(1), testutil.ToFloat64(consumedMessages.WithLabelValues("test-status")))
+
+	cancel()
+	waitFor(t, func() bool { return consumerStatus("test_status").State == StateStopped })
+
+	// 订阅失败后退避重试，ctx 取消后退出
+	assert.NoError(t, b.Close())
+	ctx, cancel = context.WithCancel(context.Background())
+	startRegistered(ctx, reg)
+	waitFor(t, func() bool { return consumerStatus("test_status").State == StateReconnecting })
+	assert.Contains(t, consumerStatus("test_status").Error, "message bus closed")
+	cancel()
+	waitFor(t, func() bool { return consumerStatus("test_status").State == StateStopped })
+}
+
+// flakyBus 第一次订阅返回已关闭的投递通道，模拟连接断开
+type flakyBus struct {
+	*bus.MemoryBus
+	subscribes atomic.Int32
+}
+
+func (b *flakyBus) Subscribe(ctx context.Context, queue string, opts bus.SubscribeOptions) (<-chan *bus.Delivery, error) {
+	if b.subscribes.Add(1) == 1 {
+		closed := make(chan *bus.Delivery)
+		close(closed)
+		return closed, nil
+	}
+	return b.MemoryBus.Subscribe(ctx, queue, opts)
+}
+
+func TestConsumerResubscribe(t *testing.T) {
+	useMemoryBus(t)
+	b := &flakyBus{MemoryBus: bus.NewMemoryBus()}
+	bus.SetDefault(b)
+
+	minBackoff := resubscribeMinBackoff
+	resubscribeMinBackoff = 10 * time.Millisecond
+	defer func() { resubscribeMinBackoff = minBackoff }()
+
+	Register(Handler[testMessage]{
+		Name:    "test_resubscribe",
+		Queue:   "test-resubscribe",
+		Process: func(ctx context.Context, msg *testMessage) error { return nil },
+	})
+	reg := lookup(t, "test_resubscribe")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startRegistered(ctx, reg)
+
+	// 投递通道关闭后重新订阅并继续消费
+	waitFor(t, func() bool {
+		return b.subscribes.Load() == 2 && consumerStatus("test_resubscribe").State == StateRunning
+	})
+	assert.NoError(t, b.Publish(ctx, "test-resubscribe", bus.Message{ID: "1", Body: []byte(`{"id":"1"}`)}))
+	waitFor(t, func() bool {
+		return b.Len("test-resubscribe") == 0 && InFlight("test-resubscribe") == 0
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(consumedMessages.WithLabelValues("test-resubscribe")))
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"back_ai_gun_data/admin"
 	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/producer"
+	"back_ai_gun_data/services"
 	"back_ai_gun_data/services/remote_service"
 	"back_ai_gun_data/utils"
 	"context"
@@ -9,14 +12,21 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"back_ai_gun_data/consumer"
+	"back_ai_gun_data/pkg/bus"
 	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/health"
 	"back_ai_gun_data/pkg/lr"
 
 	"github.com/sirupsen/logrus"
 )
 
+// 退出时等待收尾工作的最长时间
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -30,16 +40,60 @@ func main() {
 	lr.Init()
 	dao.Init()
 	cache.Init()
+	// 消息总线，MESSAGE_BUS 选择 rabbitmq / redis / memory
+	if err := bus.Init(); err != nil {
+		panic(err)
+	}
+	defer bus.Close()
 	remote_service.Init()
+	// 依赖检查，Redis 或 PG 不可用时暂停消费
+	health.Register("redis", cache.Ping)
+	health.Register("postgres", dao.Ping)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	health.Start(ctx)
+
+	// 隔离消息表，创建失败时隔离消息留在隔离队列中
+	if err := dao.MigrateQuarantine(ctx); err != nil {
+		lr.E().Errorf("Failed to migrate quarantine table: %v", err)
+	}
+
+	// project_chain_data 新增的列，缺列时所有写入都会失败
+	if err := dao.MigrateProjectChainData(ctx); err != nil {
+		panic(err)
+	}
+
+	services.StartMarketDataSink(ctx)
+	if dryrun.Enabled() {
+		// dry-run 只记录将要做的修改，不重发之前发送失败的消息
+		lr.I().Infof("Dry-run mode enabled, writes are recorded to %q instead of applied", dryrun.Output)
+		defer dryrun.Close()
+	} else {
+		// 重发发送失败的消息
+		producer.StartOutboxRelay(ctx)
+	}
 	consumer.StartAllConsumers(ctx)
+	// 管理接口，与消费者一起启动和停止
+	admin.Start()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sigChan
 	cancel()
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer stopCancel()
+	if err := admin.Stop(stopCtx); err != nil {
+		lr.E().Errorf("Failed to stop admin server: %v", err)
+	}
+
+	// 退出前写入剩余的行情
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer flushCancel()
+	if err := services.FlushMarketDataSink(flushCtx); err != nil {
+		lr.E().Errorf("Failed to flush market data on shutdown: %v", err)
+	}
 }
@@ -0,0 +1,248 @@
+package bus
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 消息总线类型，通过环境变量 MESSAGE_BUS 选择
+const (
+	TypeRabbitMQ = "rabbitmq" // 默认
+	TypeRedis    = "redis"    // Redis Streams，小规模部署和本地开发只需 Redis + PG
+	TypeMemory   = "memory"   // 进程内队列，用于测试
+)
+
+// ErrAcknowledged 消息已经确认过
+var ErrAcknowledged = errors.New("delivery already acknowledged")
+
+var (
+	ackedMessages  = metrics.NewCounterVec("messages_acked_total", "Deliveries acknowledged per queue.", "queue")
+	nackedMessages = metrics.NewCounterVec("messages_nacked_total", "Deliveries rejected per queue, requeue tells whether they went back to the queue.", "queue", "requeue")
+)
+
+// Message 待发送的消息
+type Message struct {
+	ID              string                 // 消息ID，下游据此去重
+	Body            []byte                 // 消息体
+	ContentType     string                 // eg application/json
+	ContentEncoding string                 // 消息体编码，eg gzip
+	Headers         map[string]interface{} // 附加头
+	Priority        uint8                  // 优先级，队列配置了最大优先级时生效，越大越先投递
+}
+
+// Delivery 收到的消息，处理完成后必须调用 Ack 或 Nack 其中之一且只能调用一次
+type Delivery struct {
+	ID              string
+	Queue           string
+	Body            []byte
+	ContentType     string
+	ContentEncoding string
+	Headers         map[string]interface{}
+	Timestamp       time.Time // 发送时间
+	Redelivered     bool      // 是否为重新投递
+	Priority        uint8     // 发送时指定的优先级
+
+	acker acknowledger
+	done  atomic.Bool
+}
+
+type acknowledger interface {
+	ack(d *Delivery) error
+	nack(d *Delivery, requeue bool) error
+}
+
+// Ack 确认消息处理成功
+func (d *Delivery) Ack() error {
+	if !d.done.CompareAndSwap(false, true) {
+		return ErrAcknowledged
+	}
+	if err := d.acker.ack(d); err != nil {
+		return err
+	}
+	ackedMessages.WithLabelValues(d.Queue).Inc()
+	return nil
+}
+
+// Nack 处理失败，requeue 为 true 时重新入队，否则丢弃
+func (d *Delivery) Nack(requeue bool) error {
+	if !d.done.CompareAndSwap(false, true) {
+		return ErrAcknowledged
+	}
+	if err := d.acker.nack(d, requeue); err != nil {
+		return err
+	}
+	nackedMessages.WithLabelValues(d.Queue, strconv.FormatBool(requeue)).Inc()
+	return nil
+}
+
+// Message 按原消息重新发送时使用
+func (d *Delivery) Message() Message {
+	return Message{
+		ID:              d.ID,
+		Body:            d.Body,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		Headers:         d.Headers,
+		Priority:        d.Priority,
+	}
+}
+
+// queueMaxPriority 队列最大优先级，RabbitMQ 声明队列时设置 x-max-priority
+var queueMaxPriority sync.Map
+
+// SetQueueMaxPriority 设置队列最大优先级，需在首次声明队列前调用
+// 已存在的队列参数不一致时 RabbitMQ 声明会失败，需要先删除队列或改用新队列
+func SetQueueMaxPriority(queue string, maxPriority uint8) {
+	if maxPriority == 0 {
+		queueMaxPriority.Delete(queue)
+		return
+	}
+	queueMaxPriority.Store(queue, maxPriority)
+}
+
+// QueueMaxPriority 队列最大优先级，未设置时为0
+func QueueMaxPriority(queue string) uint8 {
+	if v, ok := queueMaxPriority.Load(queue); ok {
+		return v.(uint8)
+	}
+	return 0
+}
+
+// SubscribeOptions 订阅选项
+type SubscribeOptions struct {
+	ConsumerTag string // 消费者标识，同一队列的多个消费者共同分担消息
+	Prefetch    int    // 未确认消息的上限，小于等于0时为1
+	// PrefetchUpdates 运行中调整未确认消息的上限，不超过 Prefetch；目前只有 RabbitMQ 支持，其他实现忽略
+	PrefetchUpdates <-chan int
+}
+
+// MessageBus 消息传输抽象
+type MessageBus interface {
+	// Name 实现类型，eg rabbitmq
+	Name() string
+	// Publish 发送持久化消息，等待传输层确认后返回
+	Publish(ctx context.Context, queue string, msg Message) error
+	// Subscribe 订阅队列，ctx 取消或连接断开时关闭返回的通道
+	Subscribe(ctx context.Context, queue string, opts SubscribeOptions) (<-chan *Delivery, error)
+	// Ping 检查传输层是否可用
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var (
+	defaultBus MessageBus
+	mutex      sync.Mutex
+)
+
+// Init 按 MESSAGE_BUS 创建默认消息总线，redis 类型需要先初始化 cache
+func Init() error {
+	b, err := New(getEnv("MESSAGE_BUS", TypeRabbitMQ))
+	if err != nil {
+		return err
+	}
+	SetDefault(b)
+	return nil
+}
+
+// New 创建指定类型的消息总线
+func New(kind string) (MessageBus, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case TypeRabbitMQ:
+		return NewRabbitMQBus(RabbitMQConfigFromEnv()), nil
+	case TypeRedis:
+		return NewRedisStreamBus(cache.MainRedis(), RedisStreamConfigFromEnv()), nil
+	case TypeMemory:
+		return NewMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("unknown message bus type %q", kind)
+	}
+}
+
+// Default 获取默认消息总线，未初始化时使用 RabbitMQ
+func Default() MessageBus {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if defaultBus == nil {
+		defaultBus = NewRabbitMQBus(RabbitMQConfigFromEnv())
+	}
+	return defaultBus
+}
+
+// SetDefault 替换默认消息总线，测试中可替换为 MemoryBus
+func SetDefault(b MessageBus) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	defaultBus = b
+}
+
+// Close 关闭默认消息总线
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if defaultBus == nil {
+		return nil
+	}
+	err := defaultBus.Close()
+	defaultBus = nil
+	return err
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// prefetchSlots 限制未确认消息数量，投递前获取，Ack/Nack 后释放
+type prefetchSlots chan struct{}
+
+func newPrefetchSlots(prefetch int) prefetchSlots {
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	return make(prefetchSlots, prefetch)
+}
+
+// acquire 至少获取一个额度，最多获取 cap 个，ctx 取消时返回0
+func (s prefetchSlots) acquire(ctx context.Context) int {
+	select {
+	case s <- struct{}{}:
+	case <-ctx.Done():
+		return 0
+	}
+	n := 1
+	for n < cap(s) {
+		select {
+		case s <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func (s prefetchSlots) release(n int) {
+	for i := 0; i < n; i++ {
+		<-s
+	}
+}
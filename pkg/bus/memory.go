@@ -0,0 +1,194 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBusClosed 消息总线已关闭
+var ErrBusClosed = errors.New("message bus closed")
+
+// MemoryBus 进程内消息总线，消息不持久化，用于测试和本地调试
+type MemoryBus struct {
+	mutex  sync.Mutex
+	queues map[string]*memoryQueue
+	closed bool
+}
+
+type memoryQueue struct {
+	mutex  sync.Mutex
+	items  []*Delivery
+	notify chan struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{queues: make(map[string]*memoryQueue)}
+}
+
+func (b *MemoryBus) Name() string {
+	return TypeMemory
+}
+
+func (b *MemoryBus) queue(name string) (*memoryQueue, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return nil, ErrBusClosed
+	}
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{notify: make(chan struct{}, 1)}
+		b.queues[name] = q
+	}
+	return q, nil
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, queue string, msg Message) error {
+	q, err := b.queue(queue)
+	if err != nil {
+		return err
+	}
+	q.push(newMemoryDelivery(queue, msg, false))
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context, queue string, opts SubscribeOptions) (<-chan *Delivery, error) {
+	q, err := b.queue(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := newPrefetchSlots(opts.Prefetch)
+	out := make(chan *Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			delivery := q.pop(ctx)
+			if delivery == nil {
+				return
+			}
+			delivery.acker = &memoryAcker{queue: q, slots: slots}
+
+			select {
+			case out <- delivery:
+			case <-ctx.Done():
+				// 未投递出去的消息放回队列
+				q.push(newMemoryDelivery(queue, delivery.Message(), delivery.Redelivered))
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Len 队列中等待消费的消息数量
+func (b *MemoryBus) Len(queue string) int {
+	q, err := b.queue(queue)
+	if err != nil {
+		return 0
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+func (b *MemoryBus) Ping(ctx context.Context) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+	return nil
+}
+
+func (b *MemoryBus) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	return nil
+}
+
+func newMemoryDelivery(queue string, msg Message, redelivered bool) *Delivery {
+	return &Delivery{
+		ID:              msg.ID,
+		Queue:           queue,
+		Body:            msg.Body,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Headers:         msg.Headers,
+		Timestamp:       time.Now(),
+		Redelivered:     redelivered,
+		Priority:        msg.Priority,
+	}
+}
+
+func (q *memoryQueue) push(d *Delivery) {
+	q.mutex.Lock()
+	q.items = append(q.items, d)
+	q.mutex.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop 取出优先级最高的消息，同优先级先进先出，队列为空时等待，ctx 取消时返回 nil
+func (q *memoryQueue) pop(ctx context.Context) *Delivery {
+	for {
+		q.mutex.Lock()
+		if len(q.items) > 0 {
+			index := 0
+			for i, item := range q.items {
+				if item.Priority > q.items[index].Priority {
+					index = i
+				}
+			}
+			d := q.items[index]
+			q.items = append(q.items[:index], q.items[index+1:]...)
+			remaining := len(q.items)
+			q.mutex.Unlock()
+			// 还有消息时唤醒其他等待的消费者
+			if remaining > 0 {
+				select {
+				case q.notify <- struct{}{}:
+				default:
+				}
+			}
+			return d
+		}
+		q.mutex.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+type memoryAcker struct {
+	queue *memoryQueue
+	slots prefetchSlots
+}
+
+func (a *memoryAcker) ack(*Delivery) error {
+	a.slots.release(1)
+	return nil
+}
+
+func (a *memoryAcker) nack(d *Delivery, requeue bool) error {
+	defer a.slots.release(1)
+	if requeue {
+		a.queue.push(newMemoryDelivery(d.Queue, d.Message(), true))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, msgs <-chan *Delivery) *Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		if !ok {
+			t.Fatal("delivery channel closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for delivery")
+	}
+	return nil
+}
+
+func TestMemoryBusPublishSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewMemoryBus()
+	assert.NoError(t, b.Publish(ctx, "q", Message{ID: "1", Body: []byte(`{"a":1}`)}))
+	assert.Equal(t, 1, b.Len("q"))
+
+	msgs, err := b.Subscribe(ctx, "q", SubscribeOptions{Prefetch: 1})
+	assert.NoError(t, err)
+
+	d := receive(t, msgs)
+	assert.Equal(t, "1", d.ID)
+	assert.Equal(t, "q", d.Queue)
+	assert.Equal(t, `{"a":1}`, string(d.Body))
+	assert.False(t, d.Redelivered)
+	assert.NoError(t, d.Ack())
+	assert.ErrorIs(t, d.Ack(), ErrAcknowledged)
+	assert.ErrorIs(t, d.Nack(true), ErrAcknowledged)
+	assert.Equal(t, 0, b.Len("q"))
+}
+
+func TestMemoryBusNackRequeue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewMemoryBus()
+	msgs, err := b.Subscribe(ctx, "q", SubscribeOptions{Prefetch: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, b.Publish(ctx, "q", Message{ID: "1"}))
+
+	d := receive(t, msgs)
+	assert.NoError(t, d.Nack(true))
+
+	d = receive(t, msgs)
+	assert.Equal(t, "1", d.ID)
+	assert.True(t, d.Redelivered)
+	assert.NoError(t, d.Nack(false))
+	assert.Equal(t, 0, b.Len("q"))
+}
+
+func TestMemoryBusPrefetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewMemoryBus()
+	for _, id := range []string{"1", "2", "3"} {
+		assert.NoError(t, b.Publish(ctx, "q", Message{ID: id}))
+	}
+
+	msgs, err := b.Subscribe(ctx, "q", SubscribeOptions{Prefetch: 2})
+	assert.NoError(t, err)
+
+	first := receive(t, msgs)
+	second := receive(t, msgs)
+	assert.Equal(t, "1", first.ID)
+	assert.Equal(t, "2", second.ID)
+
+	// 未确认消息达到上限时不再投递
+	select {
+	case d := <-msgs:
+		t.Fatalf("unexpected delivery %s before ack", d.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, first.Ack())
+	third := receive(t, msgs)
+	assert.Equal(t, "3", third.ID)
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewMemoryBus()
+	msgs, err := b.Subscribe(ctx, "q", SubscribeOptions{})
+	assert.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("delivery channel not closed after cancel")
+	}
+
+	assert.NoError(t, b.Close())
+	assert.ErrorIs(t, b.Publish(context.Background(), "q", Message{}), ErrBusClosed)
+	assert.ErrorIs(t, b.Ping(context.Background()), ErrBusClosed)
+}
+
+func TestNewUnknownBus(t *testing.T) {
+	_, err := New("kafka")
+	assert.Error(t, err)
+
+	b, err := New(" Memory ")
+	assert.NoError(t, err)
+	assert.Equal(t, TypeMemory, b.Name())
+}
+
+func TestMemoryBusPriority(t *testing.T) {
+	b := NewMemoryBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.NoError(t, b.Publish(ctx, "q", Message{ID: "low-1", Priority: 1}))
+	assert.NoError(t, b.Publish(ctx, "q", Message{ID: "high", Priority: 9}))
+	assert.NoError(t, b.Publish(ctx, "q", Message{ID: "low-2", Priority: 1}))
+
+	deliveries, err := b.Subscribe(ctx, "q", SubscribeOptions{Prefetch: 3})
+	assert.NoError(t, err)
+	for _, id := range []string{"high", "low-1", "low-2"} {
+		d := receive(t, deliveries)
+		assert.Equal(t, id, d.ID)
+		assert.NoError(t, d.Ack())
+	}
+}
@@ -0,0 +1,364 @@
+package bus
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQConfig RabbitMQ 连接配置
+type RabbitMQConfig struct {
+	URL            string        // 消费连接地址
+	PublishURL     string        // 发送连接地址
+	ConfirmTimeout time.Duration // 等待broker确认的超时时间，broker流控时也按该时间等待恢复
+}
+
+func RabbitMQConfigFromEnv() RabbitMQConfig {
+	return RabbitMQConfig{
+		URL:            getEnv("RABBITMQ_URL", consts.DEFAULT_RABBITMQ_URL),
+		PublishURL:     getEnv("RABBITMQ_PRODUCER_URL", consts.DEFAULT_RABBITMQ_URL),
+		ConfirmTimeout: time.Duration(getEnvInt("PUBLISH_CONFIRM_TIMEOUT_MS", 5000)) * time.Millisecond,
+	}
+}
+
+// RabbitMQBus 基于 RabbitMQ 的消息总线
+// 发送共用一个开启发布确认的连接；每个订阅使用独立连接，订阅结束时关闭
+type RabbitMQBus struct {
+	config RabbitMQConfig
+
+	mutex   sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	// 当前连接上已声明的队列
+	declaredQueues map[string]bool
+
+	// broker 流控状态
+	connBlocked atomic.Bool
+	flowPaused  atomic.Bool
+}
+
+func NewRabbitMQBus(config RabbitMQConfig) *RabbitMQBus {
+	return &RabbitMQBus{config: config, declaredQueues: make(map[string]bool)}
+}
+
+func (b *RabbitMQBus) Name() string {
+	return TypeRabbitMQ
+}
+
+// connect 建立发送连接
+func (b *RabbitMQBus) connect() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.conn != nil && !b.conn.IsClosed() && b.channel != nil && !b.channel.IsClosed() {
+		return nil
+	}
+	if b.conn != nil && !b.conn.IsClosed() {
+		b.conn.Close()
+	}
+
+	conn, err := amqp.Dial(b.config.PublishURL)
+	if err != nil {
+		return err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// 开启发布确认，broker 确认后才算发送成功
+	if err = channel.Confirm(false); err != nil {
+		channel.Close()
+		conn.Close()
+		return err
+	}
+
+	b.conn, b.channel = conn, channel
+	b.declaredQueues = make(map[string]bool)
+	b.watchFlowControl(conn, channel)
+	return nil
+}
+
+// ensureConnection 确保发送连接可用
+func (b *RabbitMQBus) ensureConnection() error {
+	b.mutex.RLock()
+	ok := b.conn != nil && !b.conn.IsClosed() && b.channel != nil && !b.channel.IsClosed()
+	b.mutex.RUnlock()
+	if ok {
+		return nil
+	}
+	return b.connect()
+}
+
+func declareQueue(ch *amqp.Channel, queue string) error {
+	var args amqp.Table
+	if maxPriority := QueueMaxPriority(queue); maxPriority > 0 {
+		args = amqp.Table{"x-max-priority": int32(maxPriority)}
+	}
+	_, err := ch.QueueDeclare(
+		queue, // queue name
+		true,  // durable
+		false, // auto-deleted
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments
+	)
+	return err
+}
+
+// ensureQueueDeclared 首次发送到队列时声明
+func (b *RabbitMQBus) ensureQueueDeclared(queue string) error {
+	b.mutex.RLock()
+	declared := b.declaredQueues[queue]
+	b.mutex.RUnlock()
+	if declared {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.declaredQueues[queue] {
+		return nil
+	}
+	if b.channel == nil {
+		return errors.New("rabbitmq channel not available")
+	}
+	if err := declareQueue(b.channel, queue); err != nil {
+		return err
+	}
+	b.declaredQueues[queue] = true
+	return nil
+}
+
+// watchFlowControl 监听连接阻塞和通道流控通知，连接或通道关闭时通知通道随之关闭
+func (b *RabbitMQBus) watchFlowControl(c *amqp.Connection, ch *amqp.Channel) {
+	b.connBlocked.Store(false)
+	b.flowPaused.Store(false)
+
+	blockings := c.NotifyBlocked(make(chan amqp.Blocking, 1))
+	flows := ch.NotifyFlow(make(chan bool, 1))
+
+	go func() {
+		for blocking := range blockings {
+			b.connBlocked.Store(blocking.Active)
+			if blocking.Active {
+				lr.E().Errorf("RabbitMQ producer connection blocked: %s", blocking.Reason)
+			} else {
+				lr.I().Infof("RabbitMQ producer connection unblocked")
+			}
+		}
+		b.connBlocked.Store(false)
+	}()
+
+	go func() {
+		for active := range flows {
+			b.flowPaused.Store(!active)
+			if !active {
+				lr.E().Errorf("RabbitMQ producer channel flow paused by broker")
+			} else {
+				lr.I().Infof("RabbitMQ producer channel flow resumed")
+			}
+		}
+		b.flowPaused.Store(false)
+	}()
+}
+
+// publishPaused broker 是否要求暂停发送
+func (b *RabbitMQBus) publishPaused() bool {
+	return b.connBlocked.Load() || b.flowPaused.Load()
+}
+
+// waitWritable broker 流控或连接被阻塞时等待恢复，超时返回错误
+func (b *RabbitMQBus) waitWritable(ctx context.Context) error {
+	if !b.publishPaused() {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, b.config.ConfirmTimeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("rabbitmq publishing paused by broker: %w", waitCtx.Err())
+		case <-ticker.C:
+			if !b.publishPaused() {
+				return nil
+			}
+		}
+	}
+}
+
+// Publish 发送消息并等待broker确认，超时或nack返回错误
+func (b *RabbitMQBus) Publish(ctx context.Context, queue string, msg Message) error {
+	if err := b.ensureConnection(); err != nil {
+		return err
+	}
+	if err := b.ensureQueueDeclared(queue); err != nil {
+		return err
+	}
+	if err := b.waitWritable(ctx); err != nil {
+		return err
+	}
+
+	b.mutex.RLock()
+	ch := b.channel
+	b.mutex.RUnlock()
+	if ch == nil {
+		return errors.New("rabbitmq channel not available")
+	}
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		"",    // exchange
+		queue, // routing key
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			Headers:         amqp.Table(msg.Headers),
+			ContentType:     msg.ContentType,
+			ContentEncoding: msg.ContentEncoding,
+			Body:            msg.Body,
+			DeliveryMode:    amqp.Persistent, // 消息持久化
+			MessageId:       msg.ID,
+			Timestamp:       time.Now(),
+			Priority:        msg.Priority,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, b.config.ConfirmTimeout)
+	defer cancel()
+	acked, err := confirmation.WaitContext(waitCtx)
+	if err != nil {
+		return fmt.Errorf("wait publish confirm for message %s: %w", msg.ID, err)
+	}
+	if !acked {
+		return fmt.Errorf("message %s nacked by broker", msg.ID)
+	}
+	return nil
+}
+
+// Subscribe 使用独立连接消费队列，ctx 取消时关闭连接，未确认的消息由broker重新投递
+func (b *RabbitMQBus) Subscribe(ctx context.Context, queue string, opts SubscribeOptions) (<-chan *Delivery, error) {
+	conn, err := amqp.Dial(b.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("channel failed: %w", err)
+	}
+
+	prefetch := opts.Prefetch
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	if err = ch.Qos(prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("qos failed: %w", err)
+	}
+
+	if err = declareQueue(ch, queue); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("queue declare failed: %w", err)
+	}
+
+	msgs, err := ch.Consume(queue, opts.ConsumerTag, false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("consume failed: %w", err)
+	}
+
+	out := make(chan *Delivery)
+	go func() {
+		defer close(out)
+		defer conn.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-opts.PrefetchUpdates:
+				// 已开始消费后调整需使用通道级限制，与订阅时的消费者级限制同时生效
+				if err := ch.Qos(max(n, 1), 0, true); err != nil {
+					lr.E().Errorf("Failed to update prefetch of queue %s to %d: %v", queue, n, err)
+				}
+			case msg, ok := <-msgs:
+				if !ok {
+					lr.E().Errorf("RabbitMQ delivery channel closed for queue %s", queue)
+					return
+				}
+				delivery := &Delivery{
+					ID:              msg.MessageId,
+					Queue:           queue,
+					Body:            msg.Body,
+					ContentType:     msg.ContentType,
+					ContentEncoding: msg.ContentEncoding,
+					Headers:         msg.Headers,
+					Timestamp:       msg.Timestamp,
+					Redelivered:     msg.Redelivered,
+					Priority:        msg.Priority,
+					acker:           rabbitMQAcker{delivery: msg},
+				}
+				select {
+				case out <- delivery:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Ping 检查发送连接
+func (b *RabbitMQBus) Ping(ctx context.Context) error {
+	return b.ensureConnection()
+}
+
+func (b *RabbitMQBus) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.channel != nil {
+		if err := b.channel.Close(); err != nil {
+			lr.E().Errorf("Failed to close channel: %v", err)
+		}
+		b.channel = nil
+	}
+
+	if b.conn != nil {
+		if err := b.conn.Close(); err != nil {
+			lr.E().Errorf("Failed to close connection: %v", err)
+		}
+		b.conn = nil
+	}
+	return nil
+}
+
+type rabbitMQAcker struct {
+	delivery amqp.Delivery
+}
+
+func (a rabbitMQAcker) ack(*Delivery) error {
+	return a.delivery.Ack(false)
+}
+
+func (a rabbitMQAcker) nack(_ *Delivery, requeue bool) error {
+	return a.delivery.Nack(false, requeue)
+}
@@ -0,0 +1,292 @@
+package bus
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamKeyPrefix 每个队列对应一个 stream
+const RedisStreamKeyPrefix = "dogex:stream:"
+
+// stream 消息字段
+const (
+	streamFieldID              = "id"
+	streamFieldBody            = "body"
+	streamFieldContentType     = "content_type"
+	streamFieldContentEncoding = "content_encoding"
+	streamFieldHeaders         = "headers"
+	streamFieldTimestamp       = "ts"
+	streamFieldRedelivered     = "redelivered"
+	streamFieldPriority        = "priority" // stream 按写入顺序消费，优先级只随消息传递
+
+	streamAckTimeout   = 3 * time.Second
+	streamRetryBackoff = time.Second
+)
+
+// RedisStreamConfig Redis Streams 配置
+type RedisStreamConfig struct {
+	Group         string        // 消费组，同一队列的消费者共同分担消息
+	MaxLen        int64         // stream 近似最大长度，超出后裁剪最早的消息
+	BlockTimeout  time.Duration // XREADGROUP 阻塞等待时间
+	ClaimInterval time.Duration // 回收其他消费者未确认消息的间隔
+	ClaimMinIdle  time.Duration // 未确认超过该时间的消息视为消费者已退出，需大于单条消息最长处理时间
+}
+
+func RedisStreamConfigFromEnv() RedisStreamConfig {
+	return RedisStreamConfig{
+		Group:         getEnv("REDIS_STREAM_GROUP", "back_ai_gun_data"),
+		MaxLen:        int64(getEnvInt("REDIS_STREAM_MAXLEN", 100000)),
+		BlockTimeout:  time.Duration(getEnvInt("REDIS_STREAM_BLOCK_MS", 2000)) * time.Millisecond,
+		ClaimInterval: time.Duration(getEnvInt("REDIS_STREAM_CLAIM_INTERVAL_SECONDS", 30)) * time.Second,
+		ClaimMinIdle:  time.Duration(getEnvInt("REDIS_STREAM_CLAIM_IDLE_SECONDS", 15*60)) * time.Second,
+	}
+}
+
+// RedisStreamBus 基于 Redis Streams 的消息总线
+// 使用消费组分担消息，XACK 确认；消费者崩溃留下的未确认消息由其他消费者通过 XAUTOCLAIM 回收
+type RedisStreamBus struct {
+	client redis.UniversalClient
+	config RedisStreamConfig
+}
+
+func NewRedisStreamBus(client redis.UniversalClient, config RedisStreamConfig) *RedisStreamBus {
+	return &RedisStreamBus{client: client, config: config}
+}
+
+func (b *RedisStreamBus) Name() string {
+	return TypeRedis
+}
+
+func (b *RedisStreamBus) streamKey(queue string) string {
+	return RedisStreamKeyPrefix + queue
+}
+
+func (b *RedisStreamBus) addArgs(queue string, msg Message, redelivered bool) (*redis.XAddArgs, error) {
+	values := map[string]interface{}{
+		streamFieldID:              msg.ID,
+		streamFieldBody:            msg.Body,
+		streamFieldContentType:     msg.ContentType,
+		streamFieldContentEncoding: msg.ContentEncoding,
+		streamFieldTimestamp:       time.Now().UnixMilli(),
+		streamFieldRedelivered:     strconv.FormatBool(redelivered),
+		streamFieldPriority:        msg.Priority,
+	}
+	if len(msg.Headers) > 0 {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return nil, err
+		}
+		values[streamFieldHeaders] = headers
+	}
+	return &redis.XAddArgs{
+		Stream: b.streamKey(queue),
+		MaxLen: b.config.MaxLen,
+		Approx: true,
+		Values: values,
+	}, nil
+}
+
+// Publish XADD 写入队列对应的 stream
+func (b *RedisStreamBus) Publish(ctx context.Context, queue string, msg Message) error {
+	args, err := b.addArgs(queue, msg, false)
+	if err != nil {
+		return err
+	}
+	return b.client.XAdd(ctx, args).Err()
+}
+
+// Subscribe 加入消费组消费 stream，消费组不存在时从头创建
+func (b *RedisStreamBus) Subscribe(ctx context.Context, queue string, opts SubscribeOptions) (<-chan *Delivery, error) {
+	stream := b.streamKey(queue)
+	err := b.client.XGroupCreateMkStream(ctx, stream, b.config.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, fmt.Errorf("create consumer group failed: %w", err)
+	}
+
+	consumer := opts.ConsumerTag
+	if consumer == "" {
+		hostname, _ := os.Hostname()
+		consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	out := make(chan *Delivery)
+	go b.consume(ctx, queue, consumer, newPrefetchSlots(opts.Prefetch), out)
+	return out, nil
+}
+
+func (b *RedisStreamBus) consume(ctx context.Context, queue, consumer string, slots prefetchSlots, out chan<- *Delivery) {
+	defer close(out)
+
+	stream := b.streamKey(queue)
+	claimStart := "0-0"
+	var lastClaim time.Time
+
+	for {
+		n := slots.acquire(ctx)
+		if n == 0 {
+			return
+		}
+
+		var messages []redis.XMessage
+		var err error
+		redelivered := false
+
+		// 回收其他消费者长时间未确认的消息，一轮扫描完成后等待下一个间隔
+		if time.Since(lastClaim) >= b.config.ClaimInterval {
+			messages, claimStart, err = b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    b.config.Group,
+				Consumer: consumer,
+				MinIdle:  b.config.ClaimMinIdle,
+				Start:    claimStart,
+				Count:    int64(n),
+			}).Result()
+			if err != nil {
+				lr.E().Errorf("Failed to claim pending messages of stream %s: %v", stream, err)
+				messages, claimStart = nil, "0-0"
+			}
+			if claimStart == "0-0" {
+				lastClaim = time.Now()
+			}
+			redelivered = true
+		}
+
+		if len(messages) == 0 {
+			redelivered = false
+			streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    b.config.Group,
+				Consumer: consumer,
+				Streams:  []string{stream, ">"},
+				Count:    int64(n),
+				Block:    b.config.BlockTimeout,
+			}).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				if ctx.Err() != nil {
+					return
+				}
+				lr.E().Errorf("Failed to read stream %s: %v", stream, err)
+				slots.release(n)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(streamRetryBackoff):
+				}
+				continue
+			}
+			for _, s := range streams {
+				messages = append(messages, s.Messages...)
+			}
+		}
+
+		// 归还未使用的额度
+		if len(messages) < n {
+			slots.release(n - len(messages))
+		}
+
+		for _, message := range messages {
+			delivery := b.decode(queue, message, redelivered)
+			delivery.acker = &redisStreamAcker{bus: b, stream: stream, queue: queue, streamID: message.ID, slots: slots}
+			// 已被删除或裁剪的消息只剩ID，直接确认
+			if len(message.Values) == 0 {
+				if err := delivery.Ack(); err != nil {
+					lr.E().Error(err)
+				}
+				continue
+			}
+			select {
+			case out <- delivery:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (b *RedisStreamBus) decode(queue string, message redis.XMessage, redelivered bool) *Delivery {
+	field := func(name string) string {
+		if value, ok := message.Values[name].(string); ok {
+			return value
+		}
+		return ""
+	}
+
+	delivery := &Delivery{
+		ID:              field(streamFieldID),
+		Queue:           queue,
+		Body:            []byte(field(streamFieldBody)),
+		ContentType:     field(streamFieldContentType),
+		ContentEncoding: field(streamFieldContentEncoding),
+		Redelivered:     redelivered || field(streamFieldRedelivered) == "true",
+	}
+	if priority, err := strconv.ParseUint(field(streamFieldPriority), 10, 8); err == nil {
+		delivery.Priority = uint8(priority)
+	}
+	if ms, err := strconv.ParseInt(field(streamFieldTimestamp), 10, 64); err == nil {
+		delivery.Timestamp = time.UnixMilli(ms)
+	}
+	if headers := field(streamFieldHeaders); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &delivery.Headers); err != nil {
+			lr.E().Errorf("Failed to decode headers of stream message %s: %v", message.ID, err)
+		}
+	}
+	return delivery
+}
+
+// Ping 检查 Redis 连接
+func (b *RedisStreamBus) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Close Redis 连接由 cache 管理，这里不关闭
+func (b *RedisStreamBus) Close() error {
+	return nil
+}
+
+type redisStreamAcker struct {
+	bus      *RedisStreamBus
+	stream   string
+	queue    string
+	streamID string
+	slots    prefetchSlots
+}
+
+// ack 使用独立的超时，消费者 ctx 已取消时也能确认
+func (a *redisStreamAcker) ack(*Delivery) error {
+	defer a.slots.release(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), streamAckTimeout)
+	defer cancel()
+	return a.bus.client.XAck(ctx, a.stream, a.bus.config.Group, a.streamID).Err()
+}
+
+// nack 重新入队时作为新消息追加到 stream 末尾，与确认原消息在同一事务中完成
+func (a *redisStreamAcker) nack(d *Delivery, requeue bool) error {
+	defer a.slots.release(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), streamAckTimeout)
+	defer cancel()
+
+	if !requeue {
+		return a.bus.client.XAck(ctx, a.stream, a.bus.config.Group, a.streamID).Err()
+	}
+
+	args, err := a.bus.addArgs(a.queue, d.Message(), true)
+	if err != nil {
+		return err
+	}
+	_, err = a.bus.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, args)
+		pipe.XAck(ctx, a.stream, a.bus.config.Group, a.streamID)
+		return nil
+	})
+	return err
+}
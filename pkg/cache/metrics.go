@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"back_ai_gun_data/pkg/metrics"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisDuration = metrics.NewHistogramVec("redis_operation_duration_seconds", "Redis command latency per command, pipelines are recorded as pipeline.", nil, "operation")
+	redisErrors   = metrics.NewCounterVec("redis_operation_errors_total", "Failed Redis commands per command, key misses are not errors.", "operation")
+)
+
+// metricsHook 记录每个命令的耗时和错误
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		observeRedis(cmd.Name(), start, err)
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		observeRedis("pipeline", start, err)
+		return err
+	}
+}
+
+func observeRedis(operation string, start time.Time, err error) {
+	redisDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) {
+		redisErrors.WithLabelValues(operation).Inc()
+	}
+}
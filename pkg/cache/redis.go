@@ -52,6 +52,7 @@ func createDataSource(config RedisConfig) *Instance {
 			panic(msg)
 		}
 
+		clusterClient.AddHook(metricsHook{})
 		dataSource.MasterClient = clusterClient
 	} else {
 		// 单机模式
@@ -71,6 +72,7 @@ func createDataSource(config RedisConfig) *Instance {
 			msg := fmt.Sprintf("can not ping redis. config: %+v. status: %v", config, status)
 			panic(msg)
 		}
+		client.AddHook(metricsHook{})
 		dataSource.MasterClient = client
 	}
 
@@ -106,3 +108,8 @@ func Expire(ctx context.Context, key string, expiration time.Duration) error {
 func TTL(ctx context.Context, key string) (time.Duration, error) {
 	return MainRedis().TTL(ctx, key).Result()
 }
+
+// Ping 检查Redis连接
+func Ping(ctx context.Context) error {
+	return MainRedis().Ping(ctx).Err()
+}
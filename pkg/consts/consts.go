@@ -43,4 +43,75 @@ const (
 	SOURCE_API_COINGECKO = "coin_gecko"
 	SOURCE_API_BINANCE   = "binance"
 	SOURCE_API_CMC       = "coin_market_cap"
+	SOURCE_API_GMGN      = "gmgn"
+	// GeckoTerminal 链上 DEX 交易池数据，只用于历史K线
+	SOURCE_API_GECKOTERMINAL = "gecko_terminal"
+)
+
+// 价格源配置 - 从环境变量读取
+var (
+	// 默认价格源顺序，逗号分隔，前面的失败或缺失时依次回退
+	PRICE_PROVIDERS = getEnvOrDefault("PRICE_PROVIDERS", SOURCE_API_GMGN+","+SOURCE_API_COINGECKO+","+SOURCE_API_CMC)
+	// 按链覆盖价格源顺序，eg "sui:coin_gecko,gmgn;base:gmgn,coin_market_cap"
+	PRICE_PROVIDERS_BY_CHAIN = getEnvOrDefault("PRICE_PROVIDERS_BY_CHAIN", "")
+	// 异常值剔除阈值：新价格与参考价格的倍数超过该值视为异常
+	PRICE_OUTLIER_MAX_RATIO = getEnvIntOrDefault("PRICE_OUTLIER_MAX_RATIO", 50)
+	// 连续该次数的读数（可来自不同价格源）都偏离参考价格且彼此一致时，视为真实行情接受，避免暴涨后价格冻结
+	PRICE_OUTLIER_CONFIRMATIONS = getEnvIntOrDefault("PRICE_OUTLIER_CONFIRMATIONS", 3)
+)
+
+// 预警配置 - 从环境变量读取
+var (
+	// 预警规则，JSON数组，为空时使用默认规则，格式见 services.AlertRule
+	ALERT_RULES = getEnvOrDefault("ALERT_RULES", "")
+)
+
+var (
+	// 跑路盘判定：两次刷新之间流动性下降比例达到该值
+	RUG_LIQUIDITY_DROP_RATIO = getEnvOrDefault("RUG_LIQUIDITY_DROP_RATIO", "0.7")
+	// 跑路盘判定：上次流动性低于该值（USD）时不判定流动性撤出，避免小池子误判
+	RUG_MIN_LIQUIDITY_USD = getEnvOrDefault("RUG_MIN_LIQUIDITY_USD", "1000")
+	// 跑路盘判定：价格连续未变动的刷新次数
+	RUG_FROZEN_ROUNDS = getEnvIntOrDefault("RUG_FROZEN_ROUNDS", 5)
+)
+
+var (
+	// 行情回写 project_chain_data 的刷新间隔（秒）
+	MARKET_SINK_FLUSH_INTERVAL = time.Duration(getEnvIntOrDefault("MARKET_SINK_FLUSH_INTERVAL", 30)) * time.Second
+	// 行情回写单批次的最大条数
+	MARKET_SINK_BATCH_SIZE = getEnvIntOrDefault("MARKET_SINK_BATCH_SIZE", 200)
+)
+
+var (
+	// 情报处理记录保留时间（秒），过期后同一情报可再次处理
+	INTELLIGENCE_RUN_TTL = time.Duration(getEnvIntOrDefault("INTELLIGENCE_RUN_TTL_SECONDS", 24*60*60)) * time.Second
+	// 处理中的情报超过该时间（秒）没有心跳视为中断，可由其他消费者接管并从检查点继续
+	INTELLIGENCE_RUN_LEASE = time.Duration(getEnvIntOrDefault("INTELLIGENCE_RUN_LEASE_SECONDS", 120)) * time.Second
+)
+
+var (
+	// 情报准入：评分低于该值只做一轮轻量检测
+	ADMISSION_MIN_SCORE = getEnvOrDefault("ADMISSION_MIN_SCORE", "0")
+	// 情报准入：有价值且评分不低于该值的情报提高检测频率
+	ADMISSION_PRIORITY_SCORE = getEnvOrDefault("ADMISSION_PRIORITY_SCORE", "80")
+	// 高优先级情报的检测间隔（秒）
+	ADMISSION_PRIORITY_INTERVAL = time.Duration(getEnvIntOrDefault("ADMISSION_PRIORITY_INTERVAL_SECONDS", 15)) * time.Second
+	// 高优先级情报的最大检测次数
+	ADMISSION_PRIORITY_MAX_DETECTIONS = getEnvIntOrDefault("ADMISSION_PRIORITY_MAX_DETECTIONS", 20)
+)
+
+// 排序历史配置
+var (
+	// 每个情报保留的排序历史条数
+	RANKING_HISTORY_SIZE = getEnvIntOrDefault("RANKING_HISTORY_SIZE", 50)
+)
+
+// 上游接口健康配置
+var (
+	// 统计上游成功率的时间窗口
+	UPSTREAM_WINDOW = time.Duration(getEnvIntOrDefault("UPSTREAM_WINDOW_SECONDS", 300)) * time.Second
+	// 窗口内请求数不少于该值才判断成功率
+	UPSTREAM_MIN_REQUESTS = getEnvIntOrDefault("UPSTREAM_MIN_REQUESTS", 10)
+	// 成功率（百分比）低于该值视为上游不可用
+	UPSTREAM_MIN_SUCCESS_PERCENT = getEnvIntOrDefault("UPSTREAM_MIN_SUCCESS_PERCENT", 50)
 )
@@ -3,3 +3,7 @@ package consts
 const (
 	COIN_GECKO_KEY = "CG-gXvi7c8qCYjQcjtAwviLRFPA"
 )
+
+var (
+	CMC_API_KEY = getEnvOrDefault("CMC_API_KEY", "")
+)
@@ -3,16 +3,18 @@ package dao
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 // GetChainByCoinGeckoChainName 根据 CoinGecko 链名称获取链信息
-func GetChainByCoinGeckoChainName(coinGeckoChainName string) (*dto.Chain, error) {
+func GetChainByCoinGeckoChainName(ctx context.Context, coinGeckoChainName string) (*dto.Chain, error) {
 	var chain dto.Chain
-	result := pgDB.Where("coin_gecko_chain_name = ? AND is_deleted = false", coinGeckoChainName).First(&chain)
+	result := GetDB().WithContext(ctx).Where("coin_gecko_chain_name = ? AND is_deleted = false", coinGeckoChainName).First(&chain)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			lr.I().Infof("No chain found for CoinGecko chain name: %s", coinGeckoChainName)
@@ -26,8 +28,8 @@ func GetChainByCoinGeckoChainName(coinGeckoChainName string) (*dto.Chain, error)
 }
 
 // GetChainUUIDByCoinGeckoChainName 根据 CoinGecko 链名称获取链 UUID
-func GetChainUUIDByCoinGeckoChainName(coinGeckoChainName string) (string, error) {
-	chain, err := GetChainByCoinGeckoChainName(coinGeckoChainName)
+func GetChainUUIDByCoinGeckoChainName(ctx context.Context, coinGeckoChainName string) (string, error) {
+	chain, err := GetChainByCoinGeckoChainName(ctx, coinGeckoChainName)
 	if err != nil {
 		return "", err
 	}
@@ -40,9 +42,9 @@ func GetChainUUIDByCoinGeckoChainName(coinGeckoChainName string) (string, error)
 }
 
 // GetChainByCoinMarketCapChainName 根据 CoinMarketCap 链名称获取链信息
-func GetChainByCoinMarketCapChainName(coinMarketCapChainName string) (*dto.Chain, error) {
+func GetChainByCoinMarketCapChainName(ctx context.Context, coinMarketCapChainName string) (*dto.Chain, error) {
 	var chain dto.Chain
-	result := GetDB().Where("coin_market_cap_chain_name = ?", coinMarketCapChainName).First(&chain)
+	result := GetDB().WithContext(ctx).Where("coin_market_cap_chain_name = ?", coinMarketCapChainName).First(&chain)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			lr.I().Infof("No chain found for CoinMarketCap chain name: %s", coinMarketCapChainName)
@@ -56,8 +58,8 @@ func GetChainByCoinMarketCapChainName(coinMarketCapChainName string) (*dto.Chain
 }
 
 // GetChainUUIDByCoinMarketCapChainName 根据 CoinMarketCap 链名称获取链 UUID
-func GetChainUUIDByCoinMarketCapChainName(coinMarketCapChainName string) (string, error) {
-	chain, err := GetChainByCoinMarketCapChainName(coinMarketCapChainName)
+func GetChainUUIDByCoinMarketCapChainName(ctx context.Context, coinMarketCapChainName string) (string, error) {
+	chain, err := GetChainByCoinMarketCapChainName(ctx, coinMarketCapChainName)
 	if err != nil {
 		return "", err
 	}
@@ -69,8 +71,8 @@ func GetChainUUIDByCoinMarketCapChainName(coinMarketCapChainName string) (string
 	return chain.ID, nil
 }
 
-func GetChainByCMCChainName(coinMarketCapChainName string) (*dto.Chain, error) {
-	chain, err := GetChainByCoinMarketCapChainName(coinMarketCapChainName)
+func GetChainByCMCChainName(ctx context.Context, coinMarketCapChainName string) (*dto.Chain, error) {
+	chain, err := GetChainByCoinMarketCapChainName(ctx, coinMarketCapChainName)
 	if err != nil {
 		return nil, err
 	}
@@ -155,9 +157,9 @@ func DeleteChain(db *gorm.DB, uuid string) error {
 }
 
 // GetChainBySlug 根据slug获取链信息
-func GetChainBySlug(slug string) (*dto.Chain, error) {
+func GetChainBySlug(ctx context.Context, slug string) (*dto.Chain, error) {
 	var chain dto.Chain
-	result := pgDB.Where("slug = ? AND is_deleted = false", slug).First(&chain)
+	result := GetDB().WithContext(ctx).Where("slug = ? AND is_deleted = false", slug).First(&chain)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			lr.I().Infof("No chain found for slug: %s", slug)
@@ -171,8 +173,8 @@ func GetChainBySlug(slug string) (*dto.Chain, error) {
 }
 
 // GetChainIDBySlug 根据slug获取链ID
-func GetChainIDBySlug(slug string) (string, error) {
-	chain, err := GetChainBySlug(slug)
+func GetChainIDBySlug(ctx context.Context, slug string) (string, error) {
+	chain, err := GetChainBySlug(ctx, slug)
 	if err != nil {
 		return "", err
 	}
@@ -185,13 +187,13 @@ func GetChainIDBySlug(slug string) (string, error) {
 }
 
 // GetChainsByIDs 根据链ID列表批量获取链信息
-func GetChainsByIDs(chainIDs []string) (map[string]*dto.Chain, error) {
+func GetChainsByIDs(ctx context.Context, chainIDs []string) (map[string]*dto.Chain, error) {
 	if len(chainIDs) == 0 {
 		return make(map[string]*dto.Chain), nil
 	}
 
 	var chains []dto.Chain
-	result := pgDB.Where("id IN ? AND is_deleted = false", chainIDs).Find(&chains)
+	result := GetDB().WithContext(ctx).Where("id IN ? AND is_deleted = false", chainIDs).Find(&chains)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to get chains by IDs: %v", result.Error)
 		return nil, result.Error
@@ -205,3 +207,29 @@ func GetChainsByIDs(chainIDs []string) (map[string]*dto.Chain, error) {
 
 	return chainMap, nil
 }
+
+// GetChainsBySlugs 根据slug列表批量获取链信息（不区分大小写），返回小写slug到链的映射
+func GetChainsBySlugs(ctx context.Context, slugs []string) (map[string]*dto.Chain, error) {
+	if len(slugs) == 0 {
+		return make(map[string]*dto.Chain), nil
+	}
+
+	lowerSlugs := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		lowerSlugs = append(lowerSlugs, strings.ToLower(slug))
+	}
+
+	var chains []dto.Chain
+	result := GetDB().WithContext(ctx).Where("LOWER(slug) IN ? AND is_deleted = false", lowerSlugs).Find(&chains)
+	if result.Error != nil {
+		lr.E().Errorf("Failed to get chains by slugs: %v", result.Error)
+		return nil, result.Error
+	}
+
+	chainMap := make(map[string]*dto.Chain)
+	for i := range chains {
+		chainMap[strings.ToLower(chains[i].Slug)] = &chains[i]
+	}
+
+	return chainMap, nil
+}
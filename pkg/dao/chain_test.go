@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"encoding/csv"
 	"get_coin_info_v2/pkg/lr"
 	"get_coin_info_v2/pkg/model/dto"
@@ -199,7 +200,7 @@ func TestGetChainByCoinGeckoChainName(t *testing.T) {
 	//t.Skip("需要设置测试数据库连接")
 
 	chainName := "bnb"
-	chain, err := GetChainByCoinGeckoChainName(chainName)
+	chain, err := GetChainByCoinGeckoChainName(context.Background(), chainName)
 	if err != nil {
 		t.Errorf("GetChainByCoinGeckoChainName failed: %v", err)
 	}
@@ -218,7 +219,7 @@ func TestGetChainUUIDByCoinGeckoChainName(t *testing.T) {
 	//t.Skip("需要设置测试数据库连接")
 
 	chainName := "ethereum"
-	uuid, err := GetChainUUIDByCoinGeckoChainName(chainName)
+	uuid, err := GetChainUUIDByCoinGeckoChainName(context.Background(), chainName)
 	if err != nil {
 		t.Errorf("GetChainUUIDByCoinGeckoChainName failed: %v", err)
 	}
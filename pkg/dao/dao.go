@@ -7,6 +7,9 @@ func Init() {
 	if err != nil {
 		panic(err)
 	}
+	if err := registerMetrics(pgDB); err != nil {
+		panic(err)
+	}
 
 	// 自动迁移表结构，确保GORM钩子正常工作
 	//if err := pgDB.AutoMigrate(&dto.CmcToken{}, &dto.CmcTokenPrice{}); err != nil {
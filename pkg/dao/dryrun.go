@@ -0,0 +1,32 @@
+package dao
+
+import (
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/model/dto"
+	"context"
+)
+
+// skipWrite dry-run 模式下记录将要做的修改并跳过写入
+func skipWrite(ctx context.Context, op, table, summary string, before, after interface{}) bool {
+	if !dryrun.Enabled() {
+		return false
+	}
+	dryrun.Record(ctx, dryrun.Diff{
+		Sink:    dryrun.SinkDB,
+		Op:      op,
+		Target:  table,
+		Summary: summary,
+		Before:  before,
+		After:   after,
+	})
+	return true
+}
+
+// showedTokenKeys 展示币的标识，按展示顺序
+func showedTokenKeys(tokens []dto.ShowedToken) []string {
+	keys := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		keys = append(keys, token.Slug+":"+token.ContractAddress)
+	}
+	return keys
+}
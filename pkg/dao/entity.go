@@ -3,15 +3,16 @@ package dao
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 	"errors"
 
 	"gorm.io/gorm"
 )
 
 // GetEntityBySlugAndType 根据slug和type获取entity
-func GetEntityBySlugAndType(slug, entityType string) (*dto.Entity, error) {
+func GetEntityBySlugAndType(ctx context.Context, slug, entityType string) (*dto.Entity, error) {
 	var entity dto.Entity
-	result := GetDB().Where("slug = ? AND type = ? AND is_deleted = false", slug, entityType).First(&entity)
+	result := GetDB().WithContext(ctx).Where("slug = ? AND type = ? AND is_deleted = false", slug, entityType).First(&entity)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -21,3 +22,23 @@ func GetEntityBySlugAndType(slug, entityType string) (*dto.Entity, error) {
 	}
 	return &entity, nil
 }
+
+// GetEntitiesBySlugsAndType 根据slug列表和type批量获取entity，返回slug到entity的映射
+func GetEntitiesBySlugsAndType(ctx context.Context, slugs []string, entityType string) (map[string]*dto.Entity, error) {
+	if len(slugs) == 0 {
+		return make(map[string]*dto.Entity), nil
+	}
+
+	var entities []dto.Entity
+	result := GetDB().WithContext(ctx).Where("slug IN ? AND type = ? AND is_deleted = false", slugs, entityType).Find(&entities)
+	if result.Error != nil {
+		lr.E().Errorf("Failed to get entities by slugs and type %s: %v", entityType, result.Error)
+		return nil, result.Error
+	}
+
+	entityMap := make(map[string]*dto.Entity, len(entities))
+	for i := range entities {
+		entityMap[entities[i].Slug] = &entities[i]
+	}
+	return entityMap, nil
+}
@@ -3,16 +3,18 @@ package dao
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 )
 
 // CreateEntityTag 创建entity_tag关联（如果不存在）
-func CreateEntityTag(entityTag *dto.EntityTag) error {
+func CreateEntityTag(ctx context.Context, entityTag *dto.EntityTag) error {
 	// 先检查是否已存在相同的关联
 	var existingEntityTag dto.EntityTag
-	result := GetDB().Where("entity_id = ? AND tag_id = ? AND is_deleted = false",
+	result := GetDB().WithContext(ctx).Where("entity_id = ? AND tag_id = ? AND is_deleted = false",
 		entityTag.EntityID, entityTag.TagID).First(&existingEntityTag)
 
 	if result.Error == nil {
@@ -26,8 +28,12 @@ func CreateEntityTag(entityTag *dto.EntityTag) error {
 		return result.Error
 	}
 
+	if skipWrite(ctx, "insert", "entity_tag", fmt.Sprintf("would link tag %s to entity %s", entityTag.TagID, entityTag.EntityID), nil, entityTag) {
+		return nil
+	}
+
 	// 创建新关联
-	result = GetDB().Create(entityTag)
+	result = GetDB().WithContext(ctx).Create(entityTag)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to create entity tag: %v", result.Error)
 		return result.Error
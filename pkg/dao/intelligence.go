@@ -1,21 +1,23 @@
 package dao
 
 import (
+	"back_ai_gun_data/pkg/dryrun"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
 	"back_ai_gun_data/utils"
+	"context"
 	"errors"
 	"fmt"
 
 	"gorm.io/gorm"
 )
 
-func CreateIntelligence(intelligence *dto.Intelligence) error {
+func CreateIntelligence(ctx context.Context, intelligence *dto.Intelligence) error {
 	if intelligence.ID == "" {
 		intelligence.ID = utils.GenerateUUIDV7()
 	}
 
-	result := GetDB().Create(intelligence)
+	result := GetDB().WithContext(ctx).Create(intelligence)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to create intelligence: %v", result.Error)
 		return result.Error
@@ -26,9 +28,9 @@ func CreateIntelligence(intelligence *dto.Intelligence) error {
 }
 
 // GetIntelligenceByID 根据ID获取情报
-func GetIntelligenceByID(id string) (*dto.Intelligence, error) {
+func GetIntelligenceByID(ctx context.Context, id string) (*dto.Intelligence, error) {
 	var intelligence dto.Intelligence
-	result := GetDB().Where("id = ? AND is_deleted = false", id).First(&intelligence)
+	result := GetDB().WithContext(ctx).Where("id = ? AND is_deleted = false", id).First(&intelligence)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -40,14 +42,14 @@ func GetIntelligenceByID(id string) (*dto.Intelligence, error) {
 }
 
 // CreateEntityIntelligence 创建情报实体关联
-func CreateEntityIntelligence(entityIntelligence *dto.EntityIntelligence) error {
+func CreateEntityIntelligence(ctx context.Context, entityIntelligence *dto.EntityIntelligence) error {
 	if entityIntelligence.ID == "" {
 		entityIntelligence.ID = utils.GenerateUUIDV7()
 	}
 
 	// 检查是否已存在相同的关联
 	var existing dto.EntityIntelligence
-	result := GetDB().Where("intelligence_id = ? AND entity_id = ? AND is_deleted = false",
+	result := GetDB().WithContext(ctx).Where("intelligence_id = ? AND entity_id = ? AND is_deleted = false",
 		entityIntelligence.IntelligenceID, entityIntelligence.EntityID).First(&existing)
 
 	if result.Error == nil {
@@ -61,8 +63,13 @@ func CreateEntityIntelligence(entityIntelligence *dto.EntityIntelligence) error
 		return result.Error
 	}
 
+	if skipWrite(ctx, "insert", "entity_intelligence", fmt.Sprintf("would link entity %s to intelligence %s",
+		entityIntelligence.EntityID, entityIntelligence.IntelligenceID), nil, entityIntelligence) {
+		return nil
+	}
+
 	// 创建新关联
-	result = GetDB().Create(entityIntelligence)
+	result = GetDB().WithContext(ctx).Create(entityIntelligence)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to create entity intelligence: %v", result.Error)
 		return result.Error
@@ -74,10 +81,10 @@ func CreateEntityIntelligence(entityIntelligence *dto.EntityIntelligence) error
 }
 
 // GetEntitiesByIntelligenceID 根据情报ID获取关联的实体列表
-func GetEntitiesByIntelligenceID(intelligenceID string) ([]dto.Entity, error) {
+func GetEntitiesByIntelligenceID(ctx context.Context, intelligenceID string) ([]dto.Entity, error) {
 	var entities []dto.Entity
 
-	result := GetDB().Joins("JOIN entity_intelligence ei ON entity.id = ei.entity_id").
+	result := GetDB().WithContext(ctx).Joins("JOIN entity_intelligence ei ON entity.id = ei.entity_id").
 		Where("ei.intelligence_id = ? AND ei.is_deleted = false AND entity.is_deleted = false", intelligenceID).
 		Find(&entities)
 
@@ -90,10 +97,10 @@ func GetEntitiesByIntelligenceID(intelligenceID string) ([]dto.Entity, error) {
 }
 
 // GetIntelligencesByEntityID 根据实体ID获取关联的情报列表
-func GetIntelligencesByEntityID(entityID string) ([]dto.Intelligence, error) {
+func GetIntelligencesByEntityID(ctx context.Context, entityID string) ([]dto.Intelligence, error) {
 	var intelligences []dto.Intelligence
 
-	result := GetDB().Joins("JOIN entity_intelligence ei ON intelligence.id = ei.intelligence_id").
+	result := GetDB().WithContext(ctx).Joins("JOIN entity_intelligence ei ON intelligence.id = ei.intelligence_id").
 		Where("ei.entity_id = ? AND ei.is_deleted = false AND intelligence.is_deleted = false", entityID).
 		Find(&intelligences)
 
@@ -106,10 +113,10 @@ func GetIntelligencesByEntityID(entityID string) ([]dto.Intelligence, error) {
 }
 
 // GetAllActiveIntelligences 获取所有活跃的情报
-func GetAllActiveIntelligences() ([]*dto.Intelligence, error) {
+func GetAllActiveIntelligences(ctx context.Context) ([]*dto.Intelligence, error) {
 	var intelligences []*dto.Intelligence
 
-	result := GetDB().Where("is_deleted = false AND is_visible = true").
+	result := GetDB().WithContext(ctx).Where("is_deleted = false AND is_visible = true").
 		Order("is_valuable DESC, published_at DESC").
 		Find(&intelligences)
 
@@ -121,7 +128,7 @@ func GetAllActiveIntelligences() ([]*dto.Intelligence, error) {
 	return intelligences, nil
 }
 
-func UpdateIntelligenceShowedTokens(intelligenceID string, showedTokens []dto.ShowedToken) error {
+func UpdateIntelligenceShowedTokens(ctx context.Context, intelligenceID string, showedTokens []dto.ShowedToken) error {
 	// 序列化showed_tokens数据
 	jsonStr, err := dto.MarshalShowedTokens(showedTokens)
 	if err != nil {
@@ -129,8 +136,19 @@ func UpdateIntelligenceShowedTokens(intelligenceID string, showedTokens []dto.Sh
 		return err
 	}
 
+	if dryrun.Enabled() {
+		before, err := GetIntelligenceShowedTokens(ctx, intelligenceID)
+		if err != nil {
+			return err
+		}
+		after := showedTokenKeys(showedTokens)
+		skipWrite(ctx, "update", "intelligence", fmt.Sprintf("would set showed_tokens of intelligence %s to %d tokens, top3 %v",
+			intelligenceID, len(after), after[:min(3, len(after))]), showedTokenKeys(before), after)
+		return nil
+	}
+
 	// 只更新showed_tokens字段
-	result := GetDB().Model(&dto.Intelligence{}).
+	result := GetDB().WithContext(ctx).Model(&dto.Intelligence{}).
 		Where("id = ? AND is_deleted = false", intelligenceID).
 		Update("showed_tokens", jsonStr)
 
@@ -146,9 +164,24 @@ func UpdateIntelligenceShowedTokens(intelligenceID string, showedTokens []dto.Sh
 	return nil
 }
 
+// ClearIntelligenceShowedTokens 清空intelligence的showed_tokens，已删除的情报也会清空
+func ClearIntelligenceShowedTokens(ctx context.Context, intelligenceID string) error {
+	if skipWrite(ctx, "update", "intelligence", fmt.Sprintf("would clear showed_tokens of intelligence %s", intelligenceID), nil, nil) {
+		return nil
+	}
+	result := GetDB().WithContext(ctx).Model(&dto.Intelligence{}).
+		Where("id = ?", intelligenceID).
+		Update("showed_tokens", "[]")
+	if result.Error != nil {
+		lr.E().Errorf("Failed to clear intelligence showed tokens: %v", result.Error)
+		return result.Error
+	}
+	return nil
+}
+
 // GetIntelligenceShowedTokens 获取intelligence的showed_tokens数据
-func GetIntelligenceShowedTokens(intelligenceID string) ([]dto.ShowedToken, error) {
-	intelligence, err := GetIntelligenceByID(intelligenceID)
+func GetIntelligenceShowedTokens(ctx context.Context, intelligenceID string) ([]dto.ShowedToken, error) {
+	intelligence, err := GetIntelligenceByID(ctx, intelligenceID)
 	if err != nil {
 		lr.E().Errorf("Failed to get intelligence for showed tokens: %v", err)
 		return nil, err
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"back_ai_gun_data/pkg/metrics"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "metrics:start"
+
+var (
+	pgDuration = metrics.NewHistogramVec("pg_operation_duration_seconds", "PostgreSQL statement latency per operation and table.", nil, "operation", "table")
+	pgErrors   = metrics.NewCounterVec("pg_operation_errors_total", "Failed PostgreSQL statements per operation and table, record not found is not an error.", "operation", "table")
+)
+
+// registerMetrics 通过 GORM 回调记录每条语句的耗时和错误
+func registerMetrics(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		db.InstanceSet(metricsStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			value, ok := db.InstanceGet(metricsStartKey)
+			if !ok {
+				return
+			}
+			table := db.Statement.Table
+			if table == "" {
+				table = "none"
+			}
+			pgDuration.WithLabelValues(operation, table).Observe(time.Since(value.(time.Time)).Seconds())
+			if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+				pgErrors.WithLabelValues(operation, table).Inc()
+			}
+		}
+	}
+
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("metrics:before_create", before),
+		callback.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", before),
+		callback.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", before),
+		callback.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", before),
+		callback.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	)
+}
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"back_ai_gun_data/pkg/consts"
+	"context"
 	"fmt"
 
 	"gorm.io/driver/postgres"
@@ -22,6 +23,11 @@ func GetPGDB() *gorm.DB {
 	return pgDB
 }
 
+// Ping 检查PostgreSQL连接
+func Ping(ctx context.Context) error {
+	return GetDB().WithContext(ctx).Exec("SELECT 1").Error
+}
+
 func connectPostgresSQL() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		consts.PG_HOST, consts.PG_PORT, consts.PG_USER, consts.PG_PASSWORD, consts.PG_NAME, consts.PG_SSLMODE)
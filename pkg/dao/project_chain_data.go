@@ -1,8 +1,10 @@
 package dao
 
 import (
+	"back_ai_gun_data/pkg/dryrun"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,8 +14,29 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-func UpdateProjectChainData(data *dto.ProjectChainData) error {
-	result := GetDB().Save(data)
+// projectChainDataColumns 本服务给 project_chain_data 新加的列，启动时补齐
+var projectChainDataColumns = []string{"MarketUpdatedAt", "Source"}
+
+// MigrateProjectChainData 补齐 project_chain_data 缺少的列，只加列不改已有列
+func MigrateProjectChainData(ctx context.Context) error {
+	migrator := GetDB().WithContext(ctx).Migrator()
+	for _, column := range projectChainDataColumns {
+		if migrator.HasColumn(&dto.ProjectChainData{}, column) {
+			continue
+		}
+		if skipWrite(ctx, "alter", "project_chain_data", fmt.Sprintf("would add column %s", column), nil, nil) {
+			continue
+		}
+		if err := migrator.AddColumn(&dto.ProjectChainData{}, column); err != nil {
+			return fmt.Errorf("add project_chain_data column %s: %w", column, err)
+		}
+		lr.I().Infof("Added column %s to project_chain_data", column)
+	}
+	return nil
+}
+
+func UpdateProjectChainData(ctx context.Context, data *dto.ProjectChainData) error {
+	result := GetDB().WithContext(ctx).Save(data)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to update project chain data: %v", result.Error)
 		return result.Error
@@ -23,8 +46,8 @@ func UpdateProjectChainData(data *dto.ProjectChainData) error {
 	return nil
 }
 
-func UpdateProjectChainDataLogo(id string, logoURL string) error {
-	result := GetDB().Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(map[string]interface{}{
+func UpdateProjectChainDataLogo(ctx context.Context, id string, logoURL string) error {
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"logo":       logoURL,
 		"updated_at": time.Now(),
 	})
@@ -36,9 +59,9 @@ func UpdateProjectChainDataLogo(id string, logoURL string) error {
 	return nil
 }
 
-func GetProjectChainDataByChainIDAndContractAddress(chainID, contractAddress string) (*dto.ProjectChainData, error) {
+func GetProjectChainDataByChainIDAndContractAddress(ctx context.Context, chainID, contractAddress string) (*dto.ProjectChainData, error) {
 	var data dto.ProjectChainData
-	result := GetDB().Where("chain_id = ? AND contract_address = ? AND is_deleted = false", chainID, contractAddress).First(&data)
+	result := GetDB().WithContext(ctx).Where("chain_id = ? AND contract_address = ? AND is_deleted = false", chainID, contractAddress).First(&data)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			lr.I().Infof("No project chain data found for chain_id: %s, contract_address: %s", chainID, contractAddress)
@@ -51,13 +74,13 @@ func GetProjectChainDataByChainIDAndContractAddress(chainID, contractAddress str
 	return &data, nil
 }
 
-func GetProjectChainDataByChainIDAndAddresses(chainID string, addresses []string) (map[string]*dto.ProjectChainData, error) {
+func GetProjectChainDataByChainIDAndAddresses(ctx context.Context, chainID string, addresses []string) (map[string]*dto.ProjectChainData, error) {
 	if len(addresses) == 0 {
 		return make(map[string]*dto.ProjectChainData), nil
 	}
 
 	var dataList []dto.ProjectChainData
-	result := GetDB().Where("chain_id = ? AND contract_address IN ? AND is_deleted = false", chainID, addresses).Find(&dataList)
+	result := GetDB().WithContext(ctx).Where("chain_id = ? AND contract_address IN ? AND is_deleted = false", chainID, addresses).Find(&dataList)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to get project chain data by chain_id: %s, addresses: %v, error: %v", chainID, addresses, result.Error)
 		return nil, result.Error
@@ -74,14 +97,14 @@ func GetProjectChainDataByChainIDAndAddresses(chainID string, addresses []string
 }
 
 // GetProjectChainDataByNamesAndAddresses 根据币名（模糊匹配）和地址查询项目链数据
-func GetProjectChainDataByNamesAndAddresses(names []string, addresses []string) ([]*dto.ProjectChainData, error) {
+func GetProjectChainDataByNamesAndAddresses(ctx context.Context, names []string, addresses []string) ([]*dto.ProjectChainData, error) {
 	if len(names) == 0 && len(addresses) == 0 {
 		return []*dto.ProjectChainData{}, nil
 	}
 
 	var dataList []dto.ProjectChainData
 	dataList = make([]dto.ProjectChainData, 0, len(addresses))
-	query := GetDB().Where("is_deleted = false")
+	query := GetDB().WithContext(ctx).Where("is_deleted = false")
 
 	// 添加币名模糊匹配条件
 	if len(names) > 0 {
@@ -115,13 +138,13 @@ func GetProjectChainDataByNamesAndAddresses(names []string, addresses []string)
 }
 
 // GetUnfollowedProjectChainData 获取未关注的项目链数据（is_follow为false或不存在）
-func GetUnfollowedProjectChainData(names []string, addresses []string) ([]*dto.ProjectChainData, error) {
+func GetUnfollowedProjectChainData(ctx context.Context, names []string, addresses []string) ([]*dto.ProjectChainData, error) {
 	if len(names) == 0 && len(addresses) == 0 {
 		return []*dto.ProjectChainData{}, nil
 	}
 
 	var dataList []dto.ProjectChainData
-	query := GetDB().Where("is_deleted = false AND (is_follow = false OR is_follow IS NULL)")
+	query := GetDB().WithContext(ctx).Where("is_deleted = false AND (is_follow = false OR is_follow IS NULL)")
 
 	// 添加币名模糊匹配条件
 	if len(names) > 0 {
@@ -154,8 +177,8 @@ func GetUnfollowedProjectChainData(names []string, addresses []string) ([]*dto.P
 	return resultList, nil
 }
 
-func CreateProjectChainData(data *dto.ProjectChainData) error {
-	result := pgDB.Create(data)
+func CreateProjectChainData(ctx context.Context, data *dto.ProjectChainData) error {
+	result := GetDB().WithContext(ctx).Create(data)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to create project chain data: %v", result.Error)
 		return result.Error
@@ -164,27 +187,42 @@ func CreateProjectChainData(data *dto.ProjectChainData) error {
 	return nil
 }
 
-func BatchCreateProjectChainData(dataList []*dto.ProjectChainData) error {
-	result := GetDB().Clauses(clause.OnConflict{
+// BatchCreateProjectChainData 批量创建，(chain_id, contract_address) 已存在时跳过，返回实际插入的行数
+func BatchCreateProjectChainData(ctx context.Context, dataList []*dto.ProjectChainData) (int64, error) {
+	if len(dataList) == 0 {
+		return 0, nil
+	}
+	if dryrun.Enabled() {
+		keys := make([]string, 0, len(dataList))
+		for _, data := range dataList {
+			if data.ChainID != nil {
+				keys = append(keys, *data.ChainID+":"+data.ContractAddress)
+			}
+		}
+		skipWrite(ctx, "insert", "project_chain_data", fmt.Sprintf("would insert %d tokens, existing skipped", len(dataList)), nil, keys)
+		return 0, nil
+	}
+
+	result := GetDB().WithContext(ctx).Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "chain_id"},
 			{Name: "contract_address"}},
 		DoNothing: true,
 	}).CreateInBatches(dataList, 100)
 
 	if err := result.Error; err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return result.RowsAffected, nil
 }
 
-func BatchUpdateProjectChainDataLogo(updates []LogoUpdate) error {
+func BatchUpdateProjectChainDataLogo(ctx context.Context, updates []LogoUpdate) error {
 	if len(updates) == 0 {
 		return nil
 	}
 
 	// 使用事务确保批量更新的原子性
-	tx := GetDB().Begin()
+	tx := GetDB().WithContext(ctx).Begin()
 	if tx.Error != nil {
 		lr.E().Errorf("Failed to begin transaction for batch logo update: %v", tx.Error)
 		return tx.Error
@@ -221,9 +259,9 @@ func BatchUpdateProjectChainDataLogo(updates []LogoUpdate) error {
 	return nil
 }
 
-func GetProjectChainDataByID(id string) (*dto.ProjectChainData, error) {
+func GetProjectChainDataByID(ctx context.Context, id string) (*dto.ProjectChainData, error) {
 	var data dto.ProjectChainData
-	result := GetDB().Where("id = ? AND is_deleted = false", id).First(&data)
+	result := GetDB().WithContext(ctx).Where("id = ? AND is_deleted = false", id).First(&data)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			lr.I().Infof("No project chain data found for ID: %s", id)
@@ -236,9 +274,9 @@ func GetProjectChainDataByID(id string) (*dto.ProjectChainData, error) {
 	return &data, nil
 }
 
-func GetProjectChainDataByProjectID(projectID string) ([]dto.ProjectChainData, error) {
+func GetProjectChainDataByProjectID(ctx context.Context, projectID string) ([]dto.ProjectChainData, error) {
 	var dataList []dto.ProjectChainData
-	result := GetDB().Where("project_id = ? AND is_deleted = false", projectID).Find(&dataList)
+	result := GetDB().WithContext(ctx).Where("project_id = ? AND is_deleted = false", projectID).Find(&dataList)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to get project chain data by project ID: %v", result.Error)
 		return nil, result.Error
@@ -247,8 +285,8 @@ func GetProjectChainDataByProjectID(projectID string) ([]dto.ProjectChainData, e
 	return dataList, nil
 }
 
-func DeleteProjectChainData(id string) error {
-	result := GetDB().Model(&dto.ProjectChainData{}).Where("id = ?", id).Update("is_deleted", true)
+func DeleteProjectChainData(ctx context.Context, id string) error {
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Where("id = ?", id).Update("is_deleted", true)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to delete project chain data: %v", result.Error)
 		return result.Error
@@ -258,7 +296,7 @@ func DeleteProjectChainData(id string) error {
 	return nil
 }
 
-func UpdateProjectChainDataMarketInfo(id string, price24Hours, tradingVolume24Hours, marketCap24Hours *float64) error {
+func UpdateProjectChainDataMarketInfo(ctx context.Context, id string, price24Hours, tradingVolume24Hours, marketCap24Hours *float64) error {
 	updates := map[string]interface{}{
 		"price_usd":  price24Hours,
 		"volume_24h": tradingVolume24Hours,
@@ -266,7 +304,7 @@ func UpdateProjectChainDataMarketInfo(id string, price24Hours, tradingVolume24Ho
 		"updated_at": time.Now(),
 	}
 
-	result := GetDB().Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(updates)
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(updates)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to update project chain data market info: %v", result.Error)
 		return result.Error
@@ -275,13 +313,13 @@ func UpdateProjectChainDataMarketInfo(id string, price24Hours, tradingVolume24Ho
 	return nil
 }
 
-func BatchUpdateProjectChainDataMarketInfo(updates []MarketInfoUpdate) error {
+func BatchUpdateProjectChainDataMarketInfo(ctx context.Context, updates []MarketInfoUpdate) error {
 	var errors []string
 	successCount := 0
 
 	// 为每个更新使用单独的事务，避免一个失败影响整个批次
 	for _, update := range updates {
-		tx := GetDB().Begin()
+		tx := GetDB().WithContext(ctx).Begin()
 
 		updates := map[string]interface{}{
 			"price_usd":  update.Price24Hours,
@@ -322,6 +360,83 @@ func BatchUpdateProjectChainDataMarketInfo(updates []MarketInfoUpdate) error {
 	return nil
 }
 
+// marketUpdateBatchSize 每条 UPDATE 语句回写的行数，每行 6 个参数，不超过 PG 的参数上限
+const marketUpdateBatchSize = 500
+
+// BatchUpdateProjectChainDataMarketByAddress 按 (chain_id, contract_address) 批量回写行情
+// 只覆盖行情时间更早的记录，避免乱序写入把新行情覆盖成旧行情；返回实际更新的行数
+func BatchUpdateProjectChainDataMarketByAddress(ctx context.Context, updates []MarketAddressUpdate) (int64, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	if skipWrite(ctx, "update", "project_chain_data", fmt.Sprintf("would update market of %d tokens", len(updates)), nil, updates) {
+		return 0, nil
+	}
+
+	// 同一个币只保留最新的行情，UPDATE ... FROM 匹配到多行时只会随机取一行
+	latest := make(map[string]int, len(updates))
+	deduped := make([]MarketAddressUpdate, 0, len(updates))
+	for _, update := range updates {
+		key := update.ChainID + ":" + update.ContractAddress
+		if i, ok := latest[key]; ok {
+			if update.MarketUpdatedAt.After(deduped[i].MarketUpdatedAt) {
+				deduped[i] = update
+			}
+			continue
+		}
+		latest[key] = len(deduped)
+		deduped = append(deduped, update)
+	}
+
+	var affected int64
+	now := time.Now()
+	for start := 0; start < len(deduped); start += marketUpdateBatchSize {
+		end := start + marketUpdateBatchSize
+		if end > len(deduped) {
+			end = len(deduped)
+		}
+		chunk := deduped[start:end]
+
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*6+1)
+		args = append(args, now)
+		for _, update := range chunk {
+			values = append(values, "(?::uuid, ?::text, ?::float8, ?::float8, ?::float8, ?::timestamp(3))")
+			args = append(args, update.ChainID, update.ContractAddress, update.PriceUSD, update.MarketCap, update.Volume24h, update.MarketUpdatedAt)
+		}
+
+		// 价格源不提供交易量时保留原值
+		sql := `UPDATE project_chain_data AS p SET
+	price_usd = v.price_usd,
+	market_cap = v.market_cap,
+	volume_24h = COALESCE(v.volume_24h, p.volume_24h),
+	market_updated_at = v.market_updated_at,
+	updated_at = ?
+FROM (VALUES ` + strings.Join(values, ", ") + `) AS v(chain_id, contract_address, price_usd, market_cap, volume_24h, market_updated_at)
+WHERE p.chain_id = v.chain_id AND p.contract_address = v.contract_address AND p.is_deleted = false
+	AND (p.market_updated_at IS NULL OR p.market_updated_at < v.market_updated_at)`
+
+		result := GetDB().WithContext(ctx).Exec(sql, args...)
+		if result.Error != nil {
+			lr.E().Errorf("Failed to batch update market info of %d tokens: %v", len(chunk), result.Error)
+			return affected, result.Error
+		}
+		affected += result.RowsAffected
+	}
+
+	return affected, nil
+}
+
+// MarketAddressUpdate 按链和合约地址回写的行情
+type MarketAddressUpdate struct {
+	ChainID         string    `json:"chain_id"`
+	ContractAddress string    `json:"contract_address"`
+	PriceUSD        *float64  `json:"price_usd"`
+	MarketCap       *float64  `json:"market_cap"`
+	Volume24h       *float64  `json:"volume_24h"`
+	MarketUpdatedAt time.Time `json:"market_updated_at"`
+}
+
 type MarketInfoUpdate struct {
 	ID                   string   `json:"id"`
 	Price24Hours         *float64 `json:"price_24_hours"`
@@ -334,9 +449,9 @@ type LogoUpdate struct {
 	LogoURL string `json:"logo_url"`
 }
 
-func GetProjectChains(offset, limit int) ([]*dto.ProjectChainData, error) {
+func GetProjectChains(ctx context.Context, offset, limit int) ([]*dto.ProjectChainData, error) {
 	var projectChains []*dto.ProjectChainData
-	result := GetDB().Offset(offset).Limit(limit).Find(&projectChains)
+	result := GetDB().WithContext(ctx).Offset(offset).Limit(limit).Find(&projectChains)
 	if result.Error != nil {
 		lr.E().Error(result.Error)
 		return nil, result.Error
@@ -345,8 +460,8 @@ func GetProjectChains(offset, limit int) ([]*dto.ProjectChainData, error) {
 	return projectChains, nil
 }
 
-func UpdateProjectChainDescription(projectChains []*dto.ProjectChainData) error {
-	err := GetDB().Transaction(func(tx *gorm.DB) error {
+func UpdateProjectChainDescription(ctx context.Context, projectChains []*dto.ProjectChainData) error {
+	err := GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for _, projectChain := range projectChains {
 			result := tx.Model(&dto.ProjectChainData{}).Where("id=?", projectChain.ID).
 				Updates(map[string]any{
@@ -365,11 +480,11 @@ func UpdateProjectChainDescription(projectChains []*dto.ProjectChainData) error
 }
 
 // UpdateProjectChainDataFields 更新指定字段
-func UpdateProjectChainDataFields(id string, updates map[string]interface{}) error {
+func UpdateProjectChainDataFields(ctx context.Context, id string, updates map[string]interface{}) error {
 	// 自动添加updated_at
 	updates["updated_at"] = time.Now()
 
-	result := GetDB().Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(updates)
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Where("id = ?", id).Updates(updates)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to update project chain data fields: %v", result.Error)
 		return result.Error
@@ -379,13 +494,13 @@ func UpdateProjectChainDataFields(id string, updates map[string]interface{}) err
 }
 
 // BatchUpdateProjectChainDataFields 批量更新指定字段
-func BatchUpdateProjectChainDataFields(updates []BatchUpdate) error {
+func BatchUpdateProjectChainDataFields(ctx context.Context, updates []BatchUpdate) error {
 	if len(updates) == 0 {
 		return nil
 	}
 
 	// 使用事务确保批量更新的原子性
-	tx := GetDB().Begin()
+	tx := GetDB().WithContext(ctx).Begin()
 	if tx.Error != nil {
 		lr.E().Errorf("Failed to begin transaction for batch update: %v", tx.Error)
 		return tx.Error
@@ -429,8 +544,8 @@ type BatchUpdate struct {
 }
 
 // ClearProjectChainDataEntityID 清空project_chain_data的entity_id
-func ClearProjectChainDataEntityID() error {
-	result := GetDB().Model(&dto.ProjectChainData{}).Update("entity_id", nil)
+func ClearProjectChainDataEntityID(ctx context.Context) error {
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Update("entity_id", nil)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to clear project chain data entity_id: %v", result.Error)
 		return result.Error
@@ -439,8 +554,11 @@ func ClearProjectChainDataEntityID() error {
 }
 
 // UpdateProjectChainDataEntityID 更新project_chain_data的entity_id
-func UpdateProjectChainDataEntityID(id, entityID string) error {
-	result := GetDB().Model(&dto.ProjectChainData{}).Where("id = ?", id).Update("entity_id", entityID)
+func UpdateProjectChainDataEntityID(ctx context.Context, id, entityID string) error {
+	if skipWrite(ctx, "update", "project_chain_data", fmt.Sprintf("would bind project chain data %s to entity %s", id, entityID), nil, nil) {
+		return nil
+	}
+	result := GetDB().WithContext(ctx).Model(&dto.ProjectChainData{}).Where("id = ?", id).Update("entity_id", entityID)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to update project chain data entity_id: %v", result.Error)
 		return result.Error
@@ -449,9 +567,9 @@ func UpdateProjectChainDataEntityID(id, entityID string) error {
 }
 
 // GetProjectChainDataWithoutEntityID 获取没有entity_id的project_chain_data
-func GetProjectChainDataWithoutEntityID(offset, limit int) ([]*dto.ProjectChainData, error) {
+func GetProjectChainDataWithoutEntityID(ctx context.Context, offset, limit int) ([]*dto.ProjectChainData, error) {
 	var dataList []*dto.ProjectChainData
-	result := GetDB().Where("entity_id IS NULL AND is_deleted = false").Offset(offset).Limit(limit).Find(&dataList)
+	result := GetDB().WithContext(ctx).Where("entity_id IS NULL AND is_deleted = false").Offset(offset).Limit(limit).Find(&dataList)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to get project chain data without entity_id: %v", result.Error)
 		return nil, result.Error
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"get_coin_info_v2/pkg/lr"
 	"get_coin_info_v2/pkg/model/dto"
 	"get_coin_info_v2/utils"
@@ -27,7 +28,7 @@ func TestUpdateProjectChainData(t *testing.T) {
 		UpdatedAt:       time.Now(),
 	}
 
-	err := UpdateProjectChainData(data)
+	err := UpdateProjectChainData(context.Background(), data)
 	if err != nil {
 		t.Errorf("UpdateProjectChainData failed: %v", err)
 	}
@@ -40,7 +41,7 @@ func TestGetProjectChainDataByChainIDAndContractAddress(t *testing.T) {
 	chainID := "chain-1"
 	contractAddress := "0x1234567890abcdef"
 
-	data, err := GetProjectChainDataByChainIDAndContractAddress(chainID, contractAddress)
+	data, err := GetProjectChainDataByChainIDAndContractAddress(context.Background(), chainID, contractAddress)
 	if err != nil {
 		t.Errorf("GetProjectChainDataByChainIDAndContractAddress failed: %v", err)
 	}
@@ -80,7 +81,7 @@ func TestCreateProjectChainData(t *testing.T) {
 		UpdatedAt:       time.Now(),
 	}
 
-	err := CreateProjectChainData(data)
+	err := CreateProjectChainData(context.Background(), data)
 	if err != nil {
 		t.Errorf("CreateProjectChainData failed: %v", err)
 	}
@@ -92,7 +93,7 @@ func TestGetProjectChainDataWithoutEntityID(t *testing.T) {
 	offset := 1
 	limit := 10
 
-	res, err := GetProjectChainDataWithoutEntityID(offset, limit)
+	res, err := GetProjectChainDataWithoutEntityID(context.Background(), offset, limit)
 	assert.NoError(t, err)
 	t.Log(utils.ToJson(res))
 }
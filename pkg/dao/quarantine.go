@@ -0,0 +1,95 @@
+package dao
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto"
+	"back_ai_gun_data/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QuarantineFilter 隔离消息查询条件，为空的条件不过滤
+type QuarantineFilter struct {
+	Queue  string
+	Status string
+}
+
+// MigrateQuarantine 创建隔离消息表
+func MigrateQuarantine(ctx context.Context) error {
+	return GetDB().WithContext(ctx).AutoMigrate(&dto.QuarantinedMessage{})
+}
+
+// CreateQuarantinedMessage 保存隔离消息
+func CreateQuarantinedMessage(ctx context.Context, message *dto.QuarantinedMessage) error {
+	if message.ID == "" {
+		message.ID = utils.GenerateUUIDV7()
+	}
+	if message.Status == "" {
+		message.Status = dto.QuarantineStatusPending
+	}
+
+	result := GetDB().WithContext(ctx).Create(message)
+	if result.Error != nil {
+		lr.E().Errorf("Failed to create quarantined message: %v", result.Error)
+		return result.Error
+	}
+	return nil
+}
+
+// ListQuarantinedMessages 按时间倒序分页查询隔离消息
+func ListQuarantinedMessages(ctx context.Context, filter QuarantineFilter, offset, limit int) ([]dto.QuarantinedMessage, error) {
+	query := GetDB().WithContext(ctx).Model(&dto.QuarantinedMessage{})
+	if filter.Queue != "" {
+		query = query.Where("queue = ?", filter.Queue)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var messages []dto.QuarantinedMessage
+	result := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&messages)
+	if result.Error != nil {
+		lr.E().Errorf("Failed to list quarantined messages: %v", result.Error)
+		return nil, result.Error
+	}
+	return messages, nil
+}
+
+// GetQuarantinedMessage 根据ID获取隔离消息，不存在时返回 nil
+func GetQuarantinedMessage(ctx context.Context, id string) (*dto.QuarantinedMessage, error) {
+	var message dto.QuarantinedMessage
+	result := GetDB().WithContext(ctx).Where("id = ?", id).First(&message)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		lr.E().Errorf("Failed to get quarantined message %s: %v", id, result.Error)
+		return nil, result.Error
+	}
+	return &message, nil
+}
+
+// TransitionQuarantinedMessage 隔离消息状态从 from 变为 to，状态不是 from 时返回错误，避免重复投递
+func TransitionQuarantinedMessage(ctx context.Context, id, from, to string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": to, "resolved_at": &now, "updated_at": now}
+	if to == dto.QuarantineStatusPending {
+		updates["resolved_at"] = nil
+	}
+
+	result := GetDB().WithContext(ctx).Model(&dto.QuarantinedMessage{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		lr.E().Errorf("Failed to update quarantined message %s to %s: %v", id, to, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("quarantined message %s not found or not %s", id, from)
+	}
+	return nil
+}
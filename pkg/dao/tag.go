@@ -3,15 +3,17 @@ package dao
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 )
 
 // GetTagBySlug 根据slug获取tag
-func GetTagBySlug(slug string) (*dto.Tag, error) {
+func GetTagBySlug(ctx context.Context, slug string) (*dto.Tag, error) {
 	var tag dto.Tag
-	result := GetDB().Where("slug = ? AND is_deleted = false", slug).First(&tag)
+	result := GetDB().WithContext(ctx).Where("slug = ? AND is_deleted = false", slug).First(&tag)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -23,9 +25,9 @@ func GetTagBySlug(slug string) (*dto.Tag, error) {
 }
 
 // CreateTag 创建新tag（如果不存在）
-func CreateTag(tag *dto.Tag) error {
+func CreateTag(ctx context.Context, tag *dto.Tag) error {
 	// 先检查是否已存在
-	existingTag, err := GetTagBySlug(*tag.Slug)
+	existingTag, err := GetTagBySlug(ctx, *tag.Slug)
 	if err != nil {
 		return err
 	}
@@ -35,7 +37,11 @@ func CreateTag(tag *dto.Tag) error {
 		return nil
 	}
 
-	result := GetDB().Create(tag)
+	if skipWrite(ctx, "insert", "tag", fmt.Sprintf("would create tag %s", *tag.Slug), nil, tag) {
+		return nil
+	}
+
+	result := GetDB().WithContext(ctx).Create(tag)
 	if result.Error != nil {
 		lr.E().Errorf("Failed to create tag: %v", result.Error)
 		return result.Error
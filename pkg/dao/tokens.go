@@ -3,6 +3,7 @@ package dao
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
+	"context"
 
 	"gorm.io/gorm"
 )
@@ -46,9 +47,9 @@ func GetTokenByChainAndAddress(db *gorm.DB, chain, address string) (*dto.Token,
 }
 
 // 根据符号查询代币
-func GetTokensBySymbol(symbol string) ([]dto.Token, error) {
+func GetTokensBySymbol(ctx context.Context, symbol string) ([]dto.Token, error) {
 	var tokens []dto.Token
-	result := GetDB().Where("symbol = ?", symbol).Find(&tokens)
+	result := GetDB().WithContext(ctx).Where("symbol = ?", symbol).Find(&tokens)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"get_coin_info_v2/pkg/lr"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -11,7 +12,7 @@ func TestGetTokensBySymbol(t *testing.T) {
 	lr.Init()
 	Init()
 	symbol := "btc"
-	tokens, err := GetTokensBySymbol(symbol)
+	tokens, err := GetTokensBySymbol(context.Background(), symbol)
 	assert.NoError(t, err)
 	t.Logf("%+v", tokens)
 }
@@ -0,0 +1,238 @@
+package decimal
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// 除法保留的小数位数，足够覆盖 1e-12 级别的 meme 币价格之间的比值
+const divisionPrecision = 36
+
+// Decimal 精确十进制数，用于价格、市值、涨幅等字段
+// 与上游保持字符串格式传输，空字符串表示无值（区别于 0）
+type Decimal struct {
+	d     decimal.Decimal
+	valid bool
+}
+
+// Zero 值为0的Decimal
+var Zero = Decimal{d: decimal.Zero, valid: true}
+
+// NewFromString 解析字符串，支持科学计数法（eg 4.5e-05），空字符串返回无值的Decimal
+func NewFromString(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Decimal{}, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: %w", s, err)
+	}
+	return Decimal{d: d, valid: true}, nil
+}
+
+// FromString 解析字符串，解析失败返回无值的Decimal
+func FromString(s string) Decimal {
+	d, err := NewFromString(s)
+	if err != nil {
+		return Decimal{}
+	}
+	return d
+}
+
+// RequireFromString 解析字符串，解析失败时panic，仅用于常量和测试
+func RequireFromString(s string) Decimal {
+	d, err := NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewFromFloat 从float64创建，使用最短精确表示，1e-12 不会被截断
+func NewFromFloat(f float64) Decimal {
+	return Decimal{d: decimal.NewFromFloat(f), valid: true}
+}
+
+// NewFromFloatPtr 从*float64创建，nil返回无值的Decimal
+func NewFromFloatPtr(f *float64) Decimal {
+	if f == nil {
+		return Decimal{}
+	}
+	return NewFromFloat(*f)
+}
+
+// NewFromInt 从int64创建
+func NewFromInt(i int64) Decimal {
+	return Decimal{d: decimal.NewFromInt(i), valid: true}
+}
+
+// IsValid 是否有值
+func (d Decimal) IsValid() bool {
+	return d.valid
+}
+
+// IsZero 有值且为0
+func (d Decimal) IsZero() bool {
+	return d.valid && d.d.IsZero()
+}
+
+// IsPositive 有值且大于0
+func (d Decimal) IsPositive() bool {
+	return d.valid && d.d.IsPositive()
+}
+
+// Cmp 比较大小，无值视为0
+func (d Decimal) Cmp(other Decimal) int {
+	return d.d.Cmp(other.d)
+}
+
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+// Equal 值相等，无值与无值相等
+func (d Decimal) Equal(other Decimal) bool {
+	if !d.valid || !other.valid {
+		return d.valid == other.valid
+	}
+	return d.d.Equal(other.d)
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{d: d.d.Add(other.d), valid: d.valid || other.valid}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{d: d.d.Sub(other.d), valid: d.valid || other.valid}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{d: d.d.Mul(other.d), valid: d.valid && other.valid}
+}
+
+// Div 除法，除数为0或无值时返回无值的Decimal
+func (d Decimal) Div(other Decimal) Decimal {
+	if !d.valid || !other.valid || other.d.IsZero() {
+		return Decimal{}
+	}
+	return Decimal{d: d.d.DivRound(other.d, divisionPrecision), valid: true}
+}
+
+// Ratio 计算 d / base，base 必须大于0，eg 当前市值 / 预警市值
+func (d Decimal) Ratio(base Decimal) (Decimal, bool) {
+	if !d.valid || !base.IsPositive() {
+		return Decimal{}, false
+	}
+	return d.Div(base), true
+}
+
+// PercentChange 计算相对 base 的百分比变化 (d - base) / base * 100
+func (d Decimal) PercentChange(base Decimal) (Decimal, bool) {
+	ratio, ok := d.Ratio(base)
+	if !ok {
+		return Decimal{}, false
+	}
+	return ratio.Sub(NewFromInt(1)).Mul(NewFromInt(100)), true
+}
+
+// Max 返回较大值，无值的一方被忽略
+func Max(a, b Decimal) Decimal {
+	if !a.valid {
+		return b
+	}
+	if !b.valid {
+		return a
+	}
+	if a.GreaterThan(b) {
+		return a
+	}
+	return b
+}
+
+// Round 保留 places 位小数
+func (d Decimal) Round(places int32) Decimal {
+	if !d.valid {
+		return d
+	}
+	return Decimal{d: d.d.Round(places), valid: true}
+}
+
+// Float64 转为float64，仅用于写入 float8 列等有损场景
+func (d Decimal) Float64() float64 {
+	f, _ := d.d.Float64()
+	return f
+}
+
+// Float64Ptr 转为*float64，无值返回nil
+func (d Decimal) Float64Ptr() *float64 {
+	if !d.valid {
+		return nil
+	}
+	f := d.Float64()
+	return &f
+}
+
+// String 无科学计数法的完整表示，无值返回空字符串
+func (d Decimal) String() string {
+	if !d.valid {
+		return ""
+	}
+	return d.d.String()
+}
+
+// MarshalJSON 序列化为字符串，保持与上游的字符串格式一致且不丢精度
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON 支持字符串、数字、null 和空字符串
+// 无法解析的值（eg "NaN"、"-"）视为无值，不让单个字段导致整个响应解析失败
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	parsed, err := NewFromString(strings.Trim(string(b), `"`))
+	if err != nil {
+		if logger := lr.I(); logger != nil {
+			logger.Debugf("Treat unparseable decimal as no value: %v", err)
+		}
+		*d = Decimal{}
+		return nil
+	}
+	*d = parsed
+	return nil
+}
+
+// Value 实现 driver.Valuer，无值写入 NULL
+func (d Decimal) Value() (driver.Value, error) {
+	if !d.valid {
+		return nil, nil
+	}
+	return d.d.String(), nil
+}
+
+// Scan 实现 sql.Scanner
+func (d *Decimal) Scan(value interface{}) error {
+	if value == nil {
+		*d = Decimal{}
+		return nil
+	}
+	var nd decimal.Decimal
+	if err := nd.Scan(value); err != nil {
+		return err
+	}
+	*d = Decimal{d: nd, valid: true}
+	return nil
+}
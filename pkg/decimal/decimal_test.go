@@ -0,0 +1,98 @@
+package decimal
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		valid    bool
+	}{
+		{name: "普通小数", input: "45184.689723956646", expected: "45184.689723956646", valid: true},
+		{name: "科学计数法", input: "4.5295194983839864e-05", expected: "0.000045295194983839864", valid: true},
+		{name: "极小价格", input: "1.2e-12", expected: "0.0000000000012", valid: true},
+		{name: "空字符串", input: "", expected: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewFromString(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, d.IsValid())
+			assert.Equal(t, tt.expected, d.String())
+		})
+	}
+
+	_, err := NewFromString("abc")
+	assert.Error(t, err)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	type stats struct {
+		Price     Decimal `json:"price"`
+		MarketCap Decimal `json:"market_cap"`
+		Rate      Decimal `json:"rate"`
+	}
+
+	input := `{"price":"1.2e-12","market_cap":1234.5,"rate":""}`
+
+	var s stats
+	assert.NoError(t, json.Unmarshal([]byte(input), &s))
+	assert.Equal(t, "0.0000000000012", s.Price.String())
+	assert.Equal(t, "1234.5", s.MarketCap.String())
+	assert.False(t, s.Rate.IsValid())
+
+	out, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"price":"0.0000000000012","market_cap":"1234.5","rate":""}`, string(out))
+
+	// 缓存读取使用 jsoniter
+	var s2 stats
+	assert.NoError(t, jsoniter.Unmarshal(out, &s2))
+	assert.True(t, s.Price.Equal(s2.Price))
+	assert.True(t, s.MarketCap.Equal(s2.MarketCap))
+	assert.False(t, s2.Rate.IsValid())
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, input := range []string{`""`, `"NaN"`, `"-"`, `"abc"`} {
+		d := RequireFromString("1")
+		assert.NoError(t, json.Unmarshal([]byte(input), &d), input)
+		assert.False(t, d.IsValid(), input)
+	}
+}
+
+func TestRatioAndPercent(t *testing.T) {
+	warning := RequireFromString("1e-12")
+	current := RequireFromString("5e-12")
+
+	ratio, ok := current.Ratio(warning)
+	assert.True(t, ok)
+	assert.Equal(t, "5", ratio.String())
+
+	percent, ok := RequireFromString("2e-13").PercentChange(warning)
+	assert.True(t, ok)
+	assert.Equal(t, "-80", percent.String())
+
+	_, ok = current.Ratio(Zero)
+	assert.False(t, ok)
+	_, ok = current.Ratio(Decimal{})
+	assert.False(t, ok)
+
+	assert.Equal(t, "5", Max(ratio, RequireFromString("2")).String())
+	assert.Equal(t, "2", Max(Decimal{}, RequireFromString("2")).String())
+}
+
+func TestNewFromFloat(t *testing.T) {
+	f := 1.234e-12
+	assert.Equal(t, "0.000000000001234", NewFromFloatPtr(&f).String())
+	assert.False(t, NewFromFloatPtr(nil).IsValid())
+	assert.Nil(t, Decimal{}.Float64Ptr())
+}
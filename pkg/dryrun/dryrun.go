@@ -0,0 +1,124 @@
+package dryrun
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dry-run 模式：Redis 缓存写入、PG 更新和消息发送只记录将要做的修改，不实际执行
+// DRY_RUN=true 开启；DRY_RUN_OUTPUT 指定 JSONL 文件，为空时写入日志
+// 读取不受影响，用于对比排序或检测逻辑修改前后的结果
+var (
+	enabled atomic.Bool
+	Output  = os.Getenv("DRY_RUN_OUTPUT")
+)
+
+func init() {
+	switch strings.ToLower(os.Getenv("DRY_RUN")) {
+	case "1", "true", "yes":
+		enabled.Store(true)
+	}
+}
+
+// 修改的目标
+const (
+	SinkCache   = "cache"
+	SinkDB      = "db"
+	SinkPublish = "publish"
+)
+
+// Diff 将要做的修改
+type Diff struct {
+	Time    time.Time   `json:"time"`
+	Source  string      `json:"source,omitempty"` // 触发修改的消息，eg dogex-sub-dev/i1
+	Sink    string      `json:"sink"`
+	Op      string      `json:"op"`     // set / del / insert / update / publish
+	Target  string      `json:"target"` // 缓存键、表名或队列名
+	Summary string      `json:"summary"`
+	Before  interface{} `json:"before,omitempty"`
+	After   interface{} `json:"after,omitempty"`
+}
+
+// Enabled 是否处于 dry-run 模式
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled 开启或关闭 dry-run 模式，用于命令行工具和测试
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+type sourceKey struct{}
+
+// WithSource 在 ctx 中带上触发修改的消息，记录到 Diff.Source
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+var (
+	mutex sync.Mutex
+	file  *os.File
+)
+
+// Record 记录将要做的修改，写入失败时写入日志
+func Record(ctx context.Context, diff Diff) {
+	if diff.Time.IsZero() {
+		diff.Time = time.Now()
+	}
+	if diff.Source == "" {
+		diff.Source, _ = ctx.Value(sourceKey{}).(string)
+	}
+
+	if Output != "" {
+		err := write(diff)
+		if err == nil {
+			return
+		}
+		lr.E().Errorf("Failed to write dry-run diff to %s: %v", Output, err)
+	}
+	lr.I().WithFields(lr.F{
+		"source": diff.Source,
+		"sink":   diff.Sink,
+		"op":     diff.Op,
+		"target": diff.Target,
+		"before": diff.Before,
+		"after":  diff.After,
+	}).Infof("[dry-run] %s", diff.Summary)
+}
+
+func write(diff Diff) error {
+	line, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if file == nil {
+		if file, err = os.OpenFile(Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+			file = nil
+			return err
+		}
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Close 关闭输出文件
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
@@ -0,0 +1,43 @@
+package dryrun
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordToFile(t *testing.T) {
+	lr.Init()
+	Output = filepath.Join(t.TempDir(), "diff.jsonl")
+	t.Cleanup(func() {
+		Close()
+		Output = ""
+	})
+
+	ctx := WithSource(context.Background(), "dogex-sub-dev/i1")
+	Record(ctx, Diff{Sink: SinkCache, Op: "set", Target: "k", Summary: "would set", Before: []string{"a"}, After: []string{"b", "a"}})
+	Record(context.Background(), Diff{Sink: SinkPublish, Op: "publish", Target: "q", Summary: "would publish"})
+
+	data, err := os.ReadFile(Output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	var diff Diff
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &diff))
+	assert.Equal(t, "dogex-sub-dev/i1", diff.Source)
+	assert.Equal(t, SinkCache, diff.Sink)
+	assert.Equal(t, []interface{}{"b", "a"}, diff.After)
+	assert.False(t, diff.Time.IsZero())
+
+	var publish Diff
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &publish))
+	assert.Empty(t, publish.Source)
+	assert.Equal(t, "q", publish.Target)
+}
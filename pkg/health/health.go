@@ -0,0 +1,202 @@
+package health
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Check 依赖检查，返回错误表示不可用
+type Check func(ctx context.Context) error
+
+// Result 单个依赖的检查结果
+type Result struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	Failures  int       `json:"failures"` // 连续失败次数
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+var (
+	// 检查间隔
+	checkInterval = time.Duration(getEnvInt("HEALTH_CHECK_INTERVAL_SECONDS", 5)) * time.Second
+	// 单次检查超时
+	checkTimeout = time.Duration(getEnvInt("HEALTH_CHECK_TIMEOUT_SECONDS", 3)) * time.Second
+	// 连续失败达到该次数才判定不可用，避免偶发超时导致暂停消费
+	failureThreshold = getEnvInt("HEALTH_FAILURE_THRESHOLD", 2)
+)
+
+type monitor struct {
+	mutex   sync.RWMutex
+	checks  map[string]Check
+	order   []string
+	results map[string]*Result
+	healthy bool
+	ready   chan struct{} // 可用时关闭，不可用时替换为新的通道
+}
+
+var defaultMonitor = newMonitor()
+
+func newMonitor() *monitor {
+	ready := make(chan struct{})
+	close(ready)
+	return &monitor{
+		checks:  make(map[string]Check),
+		results: make(map[string]*Result),
+		healthy: true,
+		ready:   ready,
+	}
+}
+
+// Register 注册依赖检查，同名检查会被替换
+func Register(name string, check Check) {
+	defaultMonitor.register(name, check)
+}
+
+// Start 定期执行所有检查，ctx 取消时停止
+func Start(ctx context.Context) {
+	defaultMonitor.checkAll(ctx)
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				defaultMonitor.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+// Healthy 所有依赖是否可用，未执行过检查时视为可用
+func Healthy() bool {
+	defaultMonitor.mutex.RLock()
+	defer defaultMonitor.mutex.RUnlock()
+	return defaultMonitor.healthy
+}
+
+// Results 最近一次的检查结果，按注册顺序
+func Results() []Result {
+	return defaultMonitor.snapshot()
+}
+
+// WaitHealthy 等待所有依赖恢复可用，ctx 取消时返回错误
+func WaitHealthy(ctx context.Context) error {
+	defaultMonitor.mutex.RLock()
+	ready := defaultMonitor.ready
+	defaultMonitor.mutex.RUnlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *monitor) register(name string, check Check) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, exists := m.checks[name]; !exists {
+		m.order = append(m.order, name)
+	}
+	m.checks[name] = check
+}
+
+func (m *monitor) checkAll(ctx context.Context) {
+	m.mutex.RLock()
+	names := append([]string(nil), m.order...)
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, m.checks[name])
+	}
+	m.mutex.RUnlock()
+
+	for i, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := runCheck(checkCtx, checks[i])
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		m.record(name, err)
+	}
+}
+
+// runCheck 执行检查，panic 视为失败
+func runCheck(ctx context.Context, check Check) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return check(ctx)
+}
+
+func (m *monitor) record(name string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result, ok := m.results[name]
+	if !ok {
+		result = &Result{Name: name, Healthy: true}
+		m.results[name] = result
+	}
+	result.CheckedAt = time.Now()
+	if err == nil {
+		if !result.Healthy {
+			lr.I().Infof("Health check %s recovered", name)
+		}
+		result.Healthy = true
+		result.Error = ""
+		result.Failures = 0
+	} else {
+		result.Failures++
+		result.Error = err.Error()
+		if result.Healthy && result.Failures >= failureThreshold {
+			lr.E().Errorf("Health check %s failed %d times: %v", name, result.Failures, err)
+			result.Healthy = false
+		}
+	}
+
+	healthy := true
+	for _, r := range m.results {
+		healthy = healthy && r.Healthy
+	}
+	switch {
+	case healthy && !m.healthy:
+		close(m.ready)
+	case !healthy && m.healthy:
+		m.ready = make(chan struct{})
+	}
+	m.healthy = healthy
+}
+
+func (m *monitor) snapshot() []Result {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	results := make([]Result, 0, len(m.order))
+	for _, name := range m.order {
+		if result, ok := m.results[name]; ok {
+			results = append(results, *result)
+		} else {
+			results = append(results, Result{Name: name, Healthy: true})
+		}
+	}
+	return results
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
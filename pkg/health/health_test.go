@@ -0,0 +1,62 @@
+package health
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorThresholdAndRecovery(t *testing.T) {
+	lr.Init()
+	defaultMonitor = newMonitor()
+	ctx := context.Background()
+
+	var failing error
+	Register("redis", func(context.Context) error { return failing })
+	Register("postgres", func(context.Context) error { panic("boom") })
+	Register("postgres", func(context.Context) error { return nil })
+
+	defaultMonitor.checkAll(ctx)
+	assert.True(t, Healthy())
+	assert.NoError(t, WaitHealthy(ctx))
+
+	// 连续失败达到阈值才判定不可用
+	failing = errors.New("connection refused")
+	for i := 0; i < failureThreshold-1; i++ {
+		defaultMonitor.checkAll(ctx)
+		assert.True(t, Healthy())
+	}
+	defaultMonitor.checkAll(ctx)
+	assert.False(t, Healthy())
+
+	results := Results()
+	assert.Equal(t, "redis", results[0].Name)
+	assert.False(t, results[0].Healthy)
+	assert.Equal(t, "connection refused", results[0].Error)
+	assert.True(t, results[1].Healthy)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, WaitHealthy(waitCtx), context.DeadlineExceeded)
+
+	waited := make(chan error, 1)
+	go func() { waited <- WaitHealthy(ctx) }()
+	failing = nil
+	defaultMonitor.checkAll(ctx)
+	assert.True(t, Healthy())
+	select {
+	case err := <-waited:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitHealthy not released")
+	}
+}
+
+func TestRunCheckRecoversPanic(t *testing.T) {
+	err := runCheck(context.Background(), func(context.Context) error { panic("boom") })
+	assert.EqualError(t, err, "panic: boom")
+}
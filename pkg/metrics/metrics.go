@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace 所有指标名的前缀
+const Namespace = "dogex"
+
+// DefaultBuckets 耗时类直方图的默认分桶（秒）
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// 指标注册到默认 registry，同时输出 Go 运行时和进程指标；同名指标重复注册时 panic
+
+// NewCounter 不区分标签的计数
+func NewCounter(name, help string) prometheus.Counter {
+	return promauto.NewCounter(prometheus.CounterOpts{Namespace: Namespace, Name: name, Help: help})
+}
+
+// NewCounterVec 按标签区分的计数
+func NewCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return promauto.NewCounterVec(prometheus.CounterOpts{Namespace: Namespace, Name: name, Help: help}, labels)
+}
+
+// NewGaugeVec 按标签区分的当前值，对象不再存在时用 DeleteLabelValues 移除
+func NewGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(prometheus.GaugeOpts{Namespace: Namespace, Name: name, Help: help}, labels)
+}
+
+// NewHistogram 不区分标签的分桶统计，buckets 为空时使用 DefaultBuckets
+func NewHistogram(name, help string, buckets []float64) prometheus.Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return promauto.NewHistogram(prometheus.HistogramOpts{Namespace: Namespace, Name: name, Help: help, Buckets: buckets})
+}
+
+// NewHistogramVec 按标签区分的分桶统计，buckets 为空时使用 DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return promauto.NewHistogramVec(prometheus.HistogramOpts{Namespace: Namespace, Name: name, Help: help, Buckets: buckets}, labels)
+}
+
+// MustRegister 注册自定义采集器，用于采集时由其他状态推导的指标
+func MustRegister(collector prometheus.Collector) {
+	prometheus.MustRegister(collector)
+}
+
+// Handler /metrics 接口
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
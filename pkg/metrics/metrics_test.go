@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	counter := NewCounterVec("test_messages_total", "Messages.", "queue")
+	histogram := NewHistogramVec("test_duration_seconds", "Duration.", nil, "queue")
+
+	counter.WithLabelValues("a").Add(2)
+	histogram.WithLabelValues("a").Observe(0.1)
+	assert.Equal(t, float64(2), testutil.ToFloat64(counter.WithLabelValues("a")))
+
+	// 同名指标重复注册时 panic
+	assert.Panics(t, func() { NewCounterVec("test_messages_total", "Duplicate.") })
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `dogex_test_messages_total{queue="a"} 2`)
+	// 未指定分桶时使用 DefaultBuckets
+	assert.Contains(t, body, `dogex_test_duration_seconds_bucket{queue="a",le="60"} 1`)
+	assert.True(t, strings.Contains(body, "go_goroutines"))
+}
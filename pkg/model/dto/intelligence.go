@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"back_ai_gun_data/pkg/decimal"
 	"encoding/json"
 	"time"
 
@@ -89,10 +90,10 @@ func (ei *EntityIntelligence) BeforeUpdate(tx *gorm.DB) error {
 }
 
 type ShowedToken struct {
-	Slug             string `json:"slug"`
-	ContractAddress  string `json:"contract_address"`
-	WarningPriceUSD  string `json:"warning_price_usd"`
-	WarningMarketCap string `json:"warning_market_cap"`
+	Slug             string          `json:"slug"`
+	ContractAddress  string          `json:"contract_address"`
+	WarningPriceUSD  decimal.Decimal `json:"warning_price_usd"`
+	WarningMarketCap decimal.Decimal `json:"warning_market_cap"`
 }
 
 func MarshalShowedTokens(tokens []ShowedToken) (string, error) {
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 隔离消息状态
+const (
+	QuarantineStatusPending   = "quarantined" // 待处理
+	QuarantineStatusRequeued  = "requeued"    // 已重新投递
+	QuarantineStatusDiscarded = "discarded"   // 已丢弃
+)
+
+// QuarantinedMessage 隔离消息表，保存处理失败的原始消息
+type QuarantinedMessage struct {
+	ID              string     `gorm:"primaryKey;column:id;type:uuid" json:"id"`
+	CreatedAt       time.Time  `gorm:"column:created_at;type:timestamp(3);default:CURRENT_TIMESTAMP(3)" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at;type:timestamp(3);default:CURRENT_TIMESTAMP(3)" json:"updated_at"`
+	MessageID       string     `gorm:"column:message_id;type:text;index" json:"message_id"`
+	Queue           string     `gorm:"column:queue;type:text;index" json:"queue"` // 原队列
+	Handler         string     `gorm:"column:handler;type:text" json:"handler"`
+	Body            []byte     `gorm:"column:body;type:bytea" json:"-"`
+	ContentType     string     `gorm:"column:content_type;type:text" json:"content_type"`
+	ContentEncoding string     `gorm:"column:content_encoding;type:text" json:"content_encoding"`
+	Headers         *string    `gorm:"column:headers;type:jsonb" json:"-"`
+	Error           string     `gorm:"column:error;type:text" json:"error"`
+	Stack           *string    `gorm:"column:stack;type:text" json:"stack"`
+	Attempts        int        `gorm:"column:attempts;type:integer;default:0" json:"attempts"`
+	Status          string     `gorm:"column:status;type:text;index;default:'quarantined'" json:"status"`
+	ResolvedAt      *time.Time `gorm:"column:resolved_at;type:timestamp(3)" json:"resolved_at"`
+}
+
+func (QuarantinedMessage) TableName() string {
+	return "message_quarantine"
+}
+
+// BeforeCreate 创建前钩子
+func (m *QuarantinedMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// BeforeUpdate 更新前钩子
+func (m *QuarantinedMessage) BeforeUpdate(tx *gorm.DB) error {
+	m.UpdatedAt = time.Now()
+	return nil
+}
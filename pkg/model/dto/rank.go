@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"back_ai_gun_data/pkg/decimal"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -16,20 +17,20 @@ type RankReq struct {
 }
 
 type NewTokenReq struct {
-	Address     string `json:"contractAddress"`
-	Chain       string `json:"chain"`
-	ChainID     int    `json:"chain_id"`
-	Decimals    int    `json:"decimals"`
-	Logo        string `json:"logo"`
-	MarketCap   string `json:"market_cap"`
-	Name        string `json:"name"`
-	Network     string `json:"network"`
-	PriceUSD    string `json:"price_usd"`
-	Symbol      string `json:"symbol"`
-	TotalSupply string `json:"total_supply"`
-	Volume24h   string `json:"volume_24h"`
-	IsInternal  bool   `json:"is_internal"`
-	Liquidity   string `json:"liquidity"`
+	Address     string          `json:"contractAddress"`
+	Chain       string          `json:"chain"`
+	ChainID     int             `json:"chain_id"`
+	Decimals    int             `json:"decimals"`
+	Logo        string          `json:"logo"`
+	MarketCap   decimal.Decimal `json:"market_cap"`
+	Name        string          `json:"name"`
+	Network     string          `json:"network"`
+	PriceUSD    decimal.Decimal `json:"price_usd"`
+	Symbol      string          `json:"symbol"`
+	TotalSupply decimal.Decimal `json:"total_supply"`
+	Volume24h   decimal.Decimal `json:"volume_24h"`
+	IsInternal  bool            `json:"is_internal"`
+	Liquidity   decimal.Decimal `json:"liquidity"`
 }
 
 type OldTokenReq struct {
@@ -43,6 +44,8 @@ type OldTokenReq struct {
 	Logo            string          `json:"logo"`             // 图标URL，转冷时到s3
 	Stats           CoinMarketStats `json:"stats"`            // 市场信息
 	Chain           ChainInfo       `json:"chain"`            // 链信息，不更新
+	IsRugged        bool            `json:"is_rugged"`        // 是否已判定为跑路盘
+	IsInternal      bool            `json:"is_internal"`      // 是否仍在发射平台内盘交易
 	CreatedAt       CustomTime      `json:"created_at"`
 	UpdatedAt       CustomTime      `json:"updated_at"`
 }
@@ -91,25 +94,27 @@ type IntelligenceTokenRankResp struct {
 	UpdatedAt       string          `json:"updated_at"`
 
 	// 外部API字段 - 支持多种JSON字段名
-	Network          string `json:"network"`
-	ChainID          int    `json:"chain_id"`
-	PriceUSD         string `json:"price_usd"`
-	TotalSupply      string `json:"total_supply"`
-	Volume24h        string `json:"volume_24h"`
-	IsInternal       bool   `json:"is_internal"`
-	Liquidity        string `json:"liquidity"`
-	CurrentMarketCap string `json:"current_market_cap"`
+	Network          string          `json:"network"`
+	ChainID          int             `json:"chain_id"`
+	PriceUSD         decimal.Decimal `json:"price_usd"`
+	TotalSupply      decimal.Decimal `json:"total_supply"`
+	Volume24h        decimal.Decimal `json:"volume_24h"`
+	IsInternal       bool            `json:"is_internal"`
+	Liquidity        decimal.Decimal `json:"liquidity"`
+	CurrentMarketCap decimal.Decimal `json:"current_market_cap"`
 
 	// 兼容字段 - 用于外部API的camelCase格式
 	ContractAddressAlt string `json:"contractAddress"`
 }
 
 type CoinMarketStats struct {
-	WarningPriceUSD     string `json:"warning_price_usd"`
-	WarningMarketCap    string `json:"warning_market_cap"`
-	CurrentPriceUSD     string `json:"current_price_usd"`
-	CurrentMarketCap    string `json:"current_market_cap"`
-	HighestIncreaseRate string `json:"highest_increase_rate"`
+	WarningPriceUSD     decimal.Decimal `json:"warning_price_usd"`
+	WarningMarketCap    decimal.Decimal `json:"warning_market_cap"`
+	CurrentPriceUSD     decimal.Decimal `json:"current_price_usd"`
+	CurrentMarketCap    decimal.Decimal `json:"current_market_cap"`
+	HighestIncreaseRate decimal.Decimal `json:"highest_increase_rate"`
+	Liquidity           decimal.Decimal `json:"liquidity"`
+	Volume24h           decimal.Decimal `json:"volume_24h"`
 }
 
 type ChainInfo struct {
@@ -1,6 +1,7 @@
 package dto_cache
 
 import (
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/model/dto"
 	"encoding/json"
 	"fmt"
@@ -12,18 +13,24 @@ import (
 
 // IntelligenceToken 情报-币缓存模型
 type IntelligenceToken struct {
-	ID              string          `json:"id"`               // project chain data id
-	EntityID        string          `json:"entity_id"`        // 实体ID
-	Name            string          `json:"name"`             // 币名称
-	Symbol          string          `json:"symbol"`           // 币符号
-	Standard        *string         `json:"standard"`         // 代币实现标准，eg erc20
-	Decimals        int             `json:"decimals"`         // 精度
-	ContractAddress string          `json:"contract_address"` // 合约地址
-	Logo            string          `json:"logo"`             // 图标URL，转冷时到s3
-	Stats           CoinMarketStats `json:"stats"`            // 市场信息
-	Chain           ChainInfo       `json:"chain"`            // 链信息，不更新
-	CreatedAt       CustomTime      `json:"created_at"`
-	UpdatedAt       CustomTime      `json:"updated_at"`
+	ID                  string          `json:"id"`                    // project chain data id
+	EntityID            string          `json:"entity_id"`             // 实体ID
+	Name                string          `json:"name"`                  // 币名称
+	Symbol              string          `json:"symbol"`                // 币符号
+	Standard            *string         `json:"standard"`              // 代币实现标准，eg erc20
+	Decimals            int             `json:"decimals"`              // 精度
+	ContractAddress     string          `json:"contract_address"`      // 合约地址
+	Logo                string          `json:"logo"`                  // 图标URL，转冷时到s3
+	Stats               CoinMarketStats `json:"stats"`                 // 市场信息
+	Chain               ChainInfo       `json:"chain"`                 // 链信息，不更新
+	IsRugged            bool            `json:"is_rugged"`             // 是否已判定为跑路盘，判定后不再恢复
+	RuggedAt            CustomTime      `json:"rugged_at"`             // 判定为跑路盘的时间
+	RugReason           string          `json:"rug_reason"`            // 判定原因，eg liquidity_pull、zero_volume、frozen
+	IsInternal          bool            `json:"is_internal"`           // 是否仍在发射平台内盘（bonding curve）交易，false 表示已上DEX
+	GraduatedAt         CustomTime      `json:"graduated_at"`          // 从内盘毕业的时间，本服务观察到 is_internal 变为 false 的时间
+	GraduationMarketCap decimal.Decimal `json:"graduation_market_cap"` // 毕业时的市值
+	CreatedAt           CustomTime      `json:"created_at"`
+	UpdatedAt           CustomTime      `json:"updated_at"`
 }
 
 type CustomTime struct {
@@ -88,11 +95,18 @@ func (ct *CustomTime) MarshalJSON() ([]byte, error) {
 
 // CoinMarketStats 币市场统计信息
 type CoinMarketStats struct {
-	WarningPriceUSD     string `json:"warning_price_usd"`     // 预警价格，不变动
-	WarningMarketCap    string `json:"warning_market_cap"`    // 预警市值，不变动
-	CurrentPriceUSD     string `json:"current_price_usd"`     // 当前价格，从gmgn获取
-	CurrentMarketCap    string `json:"current_market_cap"`    // 当前市值，从gmgn获取
-	HighestIncreaseRate string `json:"highest_increase_rate"` // 预警涨幅，历史最大值 当前市值除以预警市值
+	WarningPriceUSD     decimal.Decimal `json:"warning_price_usd"`     // 预警价格，不变动
+	WarningMarketCap    decimal.Decimal `json:"warning_market_cap"`    // 预警市值，不变动
+	WarningAt           CustomTime      `json:"warning_at"`            // 预警价格对应的时间，正常为情报发布时间
+	WarningIsFallback   bool            `json:"warning_is_fallback"`   // 查不到发布时刻的历史价格，预警价格取自记录时的当前价格
+	CurrentPriceUSD     decimal.Decimal `json:"current_price_usd"`     // 当前价格，从价格源获取
+	CurrentMarketCap    decimal.Decimal `json:"current_market_cap"`    // 当前市值，从价格源获取
+	HighestIncreaseRate decimal.Decimal `json:"highest_increase_rate"` // 预警涨幅，历史最大值 当前市值除以预警市值
+	Source              string          `json:"source"`                // 当前行情的数据来源，eg gmgn、coin_gecko
+	QuotedAt            CustomTime      `json:"quoted_at"`             // 当前行情的时间
+	Liquidity           decimal.Decimal `json:"liquidity"`             // 当前流动性，价格源未提供时保留上次的值
+	Volume24h           decimal.Decimal `json:"volume_24h"`            // 24小时交易量，价格源未提供时保留上次的值
+	UnchangedRounds     int             `json:"unchanged_rounds"`      // 价格连续未变动的刷新次数
 }
 
 // ChainInfo 链信息
@@ -204,6 +218,8 @@ func (c *IntelligenceToken) ToOldTokenReq() dto.OldTokenReq {
 			CurrentPriceUSD:     c.Stats.CurrentPriceUSD,
 			CurrentMarketCap:    c.Stats.CurrentMarketCap,
 			HighestIncreaseRate: c.Stats.HighestIncreaseRate,
+			Liquidity:           c.Stats.Liquidity,
+			Volume24h:           c.Stats.Volume24h,
 		},
 		Chain: dto.ChainInfo{
 			ID:   c.Chain.ID,
@@ -211,7 +227,48 @@ func (c *IntelligenceToken) ToOldTokenReq() dto.OldTokenReq {
 			Slug: c.Chain.Slug,
 			Logo: c.Chain.Logo,
 		},
-		CreatedAt: dto.CustomTime{Time: c.CreatedAt.Time},
-		UpdatedAt: dto.CustomTime{Time: c.UpdatedAt.Time},
+		IsRugged:   c.IsRugged,
+		IsInternal: c.IsInternal,
+		CreatedAt:  dto.CustomTime{Time: c.CreatedAt.Time},
+		UpdatedAt:  dto.CustomTime{Time: c.UpdatedAt.Time},
+	}
+}
+
+// MergeLocalState 合并仅在本地维护的状态
+// 排序服务只回传请求中的字段，行情来源、跑路盘和毕业标记等需要从旧缓存中恢复
+func (c *IntelligenceToken) MergeLocalState(old *IntelligenceToken) {
+	if c == nil || old == nil {
+		return
+	}
+
+	c.Stats.Source = old.Stats.Source
+	c.Stats.QuotedAt = old.Stats.QuotedAt
+	c.Stats.UnchangedRounds = old.Stats.UnchangedRounds
+	if !old.Stats.WarningAt.IsZero() {
+		c.Stats.WarningPriceUSD = old.Stats.WarningPriceUSD
+		c.Stats.WarningMarketCap = old.Stats.WarningMarketCap
+		c.Stats.WarningAt = old.Stats.WarningAt
+		c.Stats.WarningIsFallback = old.Stats.WarningIsFallback
+	}
+	if !c.Stats.Liquidity.IsValid() {
+		c.Stats.Liquidity = old.Stats.Liquidity
+	}
+	if !c.Stats.Volume24h.IsValid() {
+		c.Stats.Volume24h = old.Stats.Volume24h
+	}
+
+	// 毕业是单向的，已毕业的币不再回到内盘
+	if !old.IsInternal {
+		c.IsInternal = false
+	}
+	if !old.GraduatedAt.IsZero() {
+		c.GraduatedAt = old.GraduatedAt
+		c.GraduationMarketCap = old.GraduationMarketCap
+	}
+
+	if old.IsRugged {
+		c.IsRugged = true
+		c.RuggedAt = old.RuggedAt
+		c.RugReason = old.RugReason
 	}
 }
@@ -6,6 +6,7 @@ type BaseMessage struct {
 	ID        string `json:"id"`        // 情报 ID
 	Timestamp int64  `json:"timestamp"` // 时间戳
 	Version   string `json:"version"`   // 版本号
+	Reprocess bool   `json:"reprocess"` // 忽略已完成的处理记录，强制重新处理
 }
 
 // IntelligenceMessage 情报排序队列消息结构
@@ -39,6 +40,18 @@ type IntelligenceData struct {
 	PublishedAt  string               `json:"published_at"`
 }
 
+// UnmarshalJSON 缺少 is_visible 时按可见处理，与库中 is_visible 默认值一致
+// 1 版本和部分上游消息不带该字段，按零值解码会被当作不可见
+func (d *IntelligenceData) UnmarshalJSON(b []byte) error {
+	type plain IntelligenceData
+	data := plain{IsVisible: true}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	*d = IntelligenceData(data)
+	return nil
+}
+
 // ExtraDatas 额外数据
 type ExtraDatas struct {
 	URLs         []interface{} `json:"urls"`
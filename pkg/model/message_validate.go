@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 消息当前版本号，旧版本消息消费时先升级到当前版本
+const (
+	IntelligenceMessageVersion = "2"
+)
+
+// ValidationError 单个字段的校验错误
+type ValidationError struct {
+	Field   string `json:"field"`   // 字段路径，eg data.entities[0].name
+	Message string `json:"message"` // 错误原因
+}
+
+// ValidationErrors 消息校验错误列表
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	items := make([]string, 0, len(e))
+	for _, item := range e {
+		items = append(items, item.Field+": "+item.Message)
+	}
+	return "invalid message: " + strings.Join(items, "; ")
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err 没有校验错误时返回 nil，避免返回非空的 error 接口
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate 校验情报消息必填字段和格式
+func (m *IntelligenceMessage) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(m.ID) == "" {
+		errs.add("id", "is required")
+	}
+	if m.Data.ID != "" && m.Data.ID != m.ID {
+		errs.add("data.id", "does not match id %q", m.ID)
+	}
+	if m.Data.PublishedAt != "" && ParsePublishedAt(m.Data.PublishedAt).IsZero() {
+		errs.add("data.published_at", "invalid time format %q", m.Data.PublishedAt)
+	}
+	for i, entity := range m.Data.Entities {
+		if strings.TrimSpace(entity.Name) == "" {
+			errs.add(fmt.Sprintf("data.entities[%d].name", i), "is required")
+		}
+	}
+	return errs.err()
+}
+
+// Validate 校验ETL实体消息必填字段
+func (m *ETLEntityMessage) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(m.ID) == "" {
+		errs.add("id", "is required")
+	}
+	return errs.err()
+}
+
+// UpcastIntelligenceV1 将无版本号或 1 版本的情报消息升级到 2 版本
+// 1 版本的 data.published_at 为数字时间戳，且部分消息只在 data.id 中携带情报 ID
+func UpcastIntelligenceV1(doc map[string]interface{}) (string, error) {
+	data, _ := doc["data"].(map[string]interface{})
+	if data != nil {
+		switch v := data["published_at"].(type) {
+		case fmt.Stringer: // json.Number
+			data["published_at"] = v.String()
+		case float64:
+			data["published_at"] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if id, _ := doc["id"].(string); id == "" {
+			if dataID, ok := data["id"].(string); ok {
+				doc["id"] = dataID
+			}
+		}
+	}
+	return IntelligenceMessageVersion, nil
+}
+
+// ParsePublishedAt 解析消息中的发布时间，支持秒/毫秒时间戳和常见时间格式
+func ParsePublishedAt(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > 1e12 {
+			return time.UnixMilli(n)
+		}
+		return time.Unix(n, 0)
+	}
+
+	formats := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.000",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+	}
+	for _, format := range formats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePublishedAt(t *testing.T) {
+	expected := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, ParsePublishedAt("1748779200").Equal(expected))
+	assert.True(t, ParsePublishedAt("1748779200000").Equal(expected))
+	assert.True(t, ParsePublishedAt("2025-06-01T12:00:00Z").Equal(expected))
+	assert.True(t, ParsePublishedAt("2025-06-01 12:00:00").Equal(expected))
+	assert.True(t, ParsePublishedAt("").IsZero())
+	assert.True(t, ParsePublishedAt("invalid").IsZero())
+}
+
+func TestIntelligenceMessageValidate(t *testing.T) {
+	valid := &IntelligenceMessage{
+		BaseMessage: BaseMessage{ID: "i1"},
+		Data: IntelligenceData{
+			ID:          "i1",
+			PublishedAt: "2025-06-01T12:00:00Z",
+			Entities:    []IntelligenceEntity{{Name: "PEPE"}},
+		},
+	}
+	assert.NoError(t, valid.Validate())
+
+	invalid := &IntelligenceMessage{
+		Data: IntelligenceData{
+			ID:          "i2",
+			PublishedAt: "yesterday",
+			Entities:    []IntelligenceEntity{{Name: "PEPE"}, {Symbol: "DOGE"}},
+		},
+	}
+	err := invalid.Validate()
+	var errs ValidationErrors
+	assert.ErrorAs(t, err, &errs)
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal(t, []string{"id", "data.id", "data.published_at", "data.entities[1].name"}, fields)
+}
+
+func TestUpcastIntelligenceV1(t *testing.T) {
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(`{"data":{"id":"i1","published_at":1748779200000}}`))
+	decoder.UseNumber()
+	assert.NoError(t, decoder.Decode(&doc))
+
+	version, err := UpcastIntelligenceV1(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, IntelligenceMessageVersion, version)
+	assert.Equal(t, "i1", doc["id"])
+	assert.Equal(t, "1748779200000", doc["data"].(map[string]interface{})["published_at"])
+}
+
+func TestIntelligenceDataDefaultVisible(t *testing.T) {
+	var msg IntelligenceMessage
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"i1","data":{"id":"i1","score":80}}`), &msg))
+	assert.True(t, msg.Data.IsVisible)
+	assert.Equal(t, float64(80), msg.Data.Score)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"i1","data":{"id":"i1","is_visible":false}}`), &msg))
+	assert.False(t, msg.Data.IsVisible)
+}
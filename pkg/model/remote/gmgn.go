@@ -5,25 +5,26 @@ import (
 	"strconv"
 	"strings"
 
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/model/dto"
 )
 
 // GmGnToken GMGN 代币信息
 type GmGnToken struct {
-	Address     string `json:"address"`
-	Chain       string `json:"chain"`
-	ChainID     int    `json:"chain_id"`
-	Decimals    int    `json:"decimals"`
-	Logo        string `json:"logo"`
-	MarketCap   string `json:"market_cap"`
-	Name        string `json:"name"`
-	Network     string `json:"network"`
-	PriceUSD    string `json:"price_usd"`
-	Symbol      string `json:"symbol"`
-	TotalSupply string `json:"total_supply"`
-	Volume24h   string `json:"volume_24h"`
-	IsInternal  bool   `json:"is_internal"`
-	Liquidity   string `json:"liquidity"`
+	Address     string          `json:"address"`
+	Chain       string          `json:"chain"`
+	ChainID     int             `json:"chain_id"`
+	Decimals    int             `json:"decimals"`
+	Logo        string          `json:"logo"`
+	MarketCap   decimal.Decimal `json:"market_cap"`
+	Name        string          `json:"name"`
+	Network     string          `json:"network"`
+	PriceUSD    decimal.Decimal `json:"price_usd"`
+	Symbol      string          `json:"symbol"`
+	TotalSupply decimal.Decimal `json:"total_supply"`
+	Volume24h   decimal.Decimal `json:"volume_24h"`
+	IsInternal  bool            `json:"is_internal"`
+	Liquidity   decimal.Decimal `json:"liquidity"`
 }
 
 // IsSupportedChain 检查是否为支持的链
@@ -259,29 +260,10 @@ func (t *TokenSecurityResp) assessPermissionRisk() string {
 }
 
 func (t *GmGnToken) ToProjectChainData(chainID string) *dto.ProjectChainData {
-	// 解析市值
-	var marketCap *float64
-	if t.MarketCap != "" {
-		if parsed, err := strconv.ParseFloat(t.MarketCap, 64); err == nil {
-			marketCap = &parsed
-		}
-	}
-
-	// 解析价格
-	var price *float64
-	if t.PriceUSD != "" {
-		if parsed, err := strconv.ParseFloat(t.PriceUSD, 64); err == nil {
-			price = &parsed
-		}
-	}
-
-	// 解析24小时交易量
-	var volume24h *float64
-	if t.Volume24h != "" {
-		if parsed, err := strconv.ParseFloat(t.Volume24h, 64); err == nil {
-			volume24h = &parsed
-		}
-	}
+	// 市值、价格、24小时交易量，无值时为nil
+	marketCap := t.MarketCap.Float64Ptr()
+	price := t.PriceUSD.Float64Ptr()
+	volume24h := t.Volume24h.Float64Ptr()
 
 	// 设置标准为ERC20（根据传入参数）
 	//standard := "ERC20"
@@ -0,0 +1,28 @@
+package remote
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenQueryResponseWithBadField(t *testing.T) {
+	// 单个字段无法解析时只有该字段无值，其余字段和其他币正常解析
+	body := `{"code":0,"msg":"ok","data":{"sol":[
+		{"address":"AAA","network":"sol","price_usd":"-","market_cap":"1000","volume_24h":"NaN"},
+		{"address":"BBB","network":"sol","price_usd":"0.5","market_cap":2000,"liquidity":""}
+	]}}`
+
+	var resp TokenQueryResponse
+	assert.NoError(t, jsoniter.Unmarshal([]byte(body), &resp))
+	tokens := resp.Data["sol"]
+	if assert.Len(t, tokens, 2) {
+		assert.False(t, tokens[0].PriceUSD.IsValid())
+		assert.False(t, tokens[0].Volume24h.IsValid())
+		assert.Equal(t, "1000", tokens[0].MarketCap.String())
+		assert.Equal(t, "0.5", tokens[1].PriceUSD.String())
+		assert.Equal(t, "2000", tokens[1].MarketCap.String())
+		assert.False(t, tokens[1].Liquidity.IsValid())
+	}
+}
@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TokenRef 价格查询的币种标识
+type TokenRef struct {
+	Chain    string          `json:"chain"`     // 链slug，eg solana、bsc
+	Address  string          `json:"address"`   // 合约地址
+	RefPrice decimal.Decimal `json:"ref_price"` // 参考价格（上次价格），用于异常值剔除，可为空
+}
+
+// GetUniqueKey 获取唯一标识符，格式：chain:contract_address
+func (r TokenRef) GetUniqueKey() string {
+	return PriceKey(r.Chain, r.Address)
+}
+
+// TokenQuote 价格源返回的行情
+type TokenQuote struct {
+	Chain      string          `json:"chain"`
+	Address    string          `json:"address"`
+	PriceUSD   decimal.Decimal `json:"price_usd"`
+	MarketCap  decimal.Decimal `json:"market_cap"`
+	Liquidity  decimal.Decimal `json:"liquidity"`
+	Volume24h  decimal.Decimal `json:"volume_24h"`
+	IsInternal *bool           `json:"is_internal"` // 是否仍在发射平台内盘（bonding curve），价格源不提供时为nil
+	Source     string          `json:"source"`      // 数据来源，eg gmgn、coin_gecko
+	Timestamp  time.Time       `json:"timestamp"`   // 行情时间
+}
+
+// GetUniqueKey 获取唯一标识符，格式：chain:contract_address
+func (q TokenQuote) GetUniqueKey() string {
+	return PriceKey(q.Chain, q.Address)
+}
+
+// PriceKey 价格查询的唯一键，链和地址均不区分大小写，忽略0x前缀
+func PriceKey(chain, address string) string {
+	addr := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	return fmt.Sprintf("%s:%s", strings.ToLower(chain), addr)
+}
+
+// CoinGeckoTokenPrice CoinGecko simple/token_price 单个币的响应
+type CoinGeckoTokenPrice struct {
+	USD           decimal.Decimal `json:"usd"`
+	USDMarketCap  decimal.Decimal `json:"usd_market_cap"`
+	USD24hVol     decimal.Decimal `json:"usd_24h_vol"`
+	LastUpdatedAt int64           `json:"last_updated_at"`
+}
+
+// CMCInfoResponse CoinMarketCap /v2/cryptocurrency/info 响应
+type CMCInfoResponse struct {
+	Status CMCStatus          `json:"status"`
+	Data   map[string]CMCInfo `json:"data"`
+}
+
+// CMCInfo CoinMarketCap 币种基础信息
+type CMCInfo struct {
+	ID              int                  `json:"id"`
+	Name            string               `json:"name"`
+	Symbol          string               `json:"symbol"`
+	ContractAddress []CMCContractAddress `json:"contract_address"`
+}
+
+// CMCContractAddress CoinMarketCap 合约地址信息
+type CMCContractAddress struct {
+	ContractAddress string `json:"contract_address"`
+	Platform        struct {
+		Name string `json:"name"`
+	} `json:"platform"`
+}
+
+// CMCQuotesResponse CoinMarketCap /v2/cryptocurrency/quotes/latest 响应
+type CMCQuotesResponse struct {
+	Status CMCStatus           `json:"status"`
+	Data   map[string]CMCQuote `json:"data"`
+}
+
+// CMCQuote CoinMarketCap 行情
+type CMCQuote struct {
+	ID    int `json:"id"`
+	Quote struct {
+		USD struct {
+			Price       decimal.Decimal `json:"price"`
+			Volume24h   decimal.Decimal `json:"volume_24h"`
+			MarketCap   decimal.Decimal `json:"market_cap"`
+			LastUpdated string          `json:"last_updated"`
+		} `json:"USD"`
+	} `json:"quote"`
+}
+
+// CMCStatus CoinMarketCap 响应状态
+type CMCStatus struct {
+	ErrorCode    int    `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// OHLCV 历史K线，Timestamp 为K线开始时间
+type OHLCV struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Open      decimal.Decimal `json:"open"`
+	High      decimal.Decimal `json:"high"`
+	Low       decimal.Decimal `json:"low"`
+	Close     decimal.Decimal `json:"close"`
+	Volume    decimal.Decimal `json:"volume"`
+	MarketCap decimal.Decimal `json:"market_cap"` // K线结束时的市值，价格源不提供时为无值
+	Source    string          `json:"source"`
+}
+
+// CoinGeckoMarketChart CoinGecko market_chart/range 响应，每个点为 [毫秒时间戳, 数值]
+type CoinGeckoMarketChart struct {
+	Prices       [][2]decimal.Decimal `json:"prices"`
+	MarketCaps   [][2]decimal.Decimal `json:"market_caps"`
+	TotalVolumes [][2]decimal.Decimal `json:"total_volumes"`
+}
+
+// GeckoTerminalPools GeckoTerminal 代币的交易池列表，按流动性和交易量排序
+type GeckoTerminalPools struct {
+	Data []struct {
+		Attributes struct {
+			Address string `json:"address"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// GeckoTerminalOHLCV GeckoTerminal 交易池K线响应，每根为 [秒级开始时间, open, high, low, close, volume]，按时间倒序
+type GeckoTerminalOHLCV struct {
+	Data struct {
+		Attributes struct {
+			OHLCVList [][6]decimal.Decimal `json:"ohlcv_list"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
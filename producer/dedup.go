@@ -0,0 +1,83 @@
+package producer
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewTokenDedupKeyPrefix 新币通知去重，值为首次分配的 S3Key
+const NewTokenDedupKeyPrefix = "dogex:producer:new_token:"
+
+// 新币通知去重窗口，窗口内同一币只通知一次
+var newTokenDedupTTL = time.Duration(getEnvInt("NEW_TOKEN_DEDUP_TTL_SECONDS", 24*60*60)) * time.Second
+
+// NewTokensSendResult 单次发送新币通知的结果
+type NewTokensSendResult struct {
+	Sent       int // 实际发送的币数量
+	Suppressed int // 去重窗口内已通知过、本次跳过的币数量
+}
+
+func newTokenDedupKey(token remote.GmGnToken) string {
+	return NewTokenDedupKeyPrefix + remote.PriceKey(token.Network, token.Address)
+}
+
+// newS3Key 生成唯一的 S3 路径，按日期分层 + UUIDv7 保证唯一性
+func newS3Key() string {
+	return fmt.Sprintf("image/%s-%s", time.Now().Format("2006-01-02"), utils.GenerateUUIDV7())
+}
+
+// AssignTokenS3Key 为新币分配 S3Key
+// 去重窗口内首次出现时生成新的 S3Key 并返回 isNew=true；再次出现时返回之前分配的 S3Key
+// Redis 不可用时按首次出现处理，宁可重复通知也不丢通知；dry-run 时只检查不占用
+func AssignTokenS3Key(ctx context.Context, token remote.GmGnToken) (s3Key string, isNew bool, err error) {
+	s3Key = newS3Key()
+	key := newTokenDedupKey(token)
+
+	var acquired bool
+	if dryrun.Enabled() {
+		var exists int64
+		exists, err = cache.Exists(ctx, key)
+		acquired = exists == 0
+	} else {
+		acquired, err = cache.MainRedis().SetNX(ctx, key, s3Key, newTokenDedupTTL).Result()
+	}
+	if err != nil {
+		return s3Key, true, err
+	}
+	if acquired {
+		return s3Key, true, nil
+	}
+
+	existing, err := cache.Get(ctx, key)
+	if err != nil {
+		// 键恰好过期，按首次出现处理
+		if errors.Is(err, redis.Nil) {
+			return s3Key, true, nil
+		}
+		return s3Key, true, err
+	}
+	return existing, false, nil
+}
+
+// releaseTokenDedup 发送失败时释放去重键，下次检测重新通知
+func releaseTokenDedup(ctx context.Context, tokens []remote.GmGnToken) {
+	keys := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		keys = append(keys, newTokenDedupKey(token))
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := cache.Del(ctx, keys...); err != nil {
+		lr.E().Errorf("Failed to release new token dedup keys: %v", err)
+	}
+}
@@ -1,20 +1,72 @@
 package producer
 
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"time"
+)
+
 // NewTokensMessage 新代币消息结构体
 type NewTokensMessage struct {
-	Address     string `json:"address"`
-	Chain       string `json:"chain"`
-	ChainID     int    `json:"chain_id"`
-	Decimals    int    `json:"decimals"`
-	Logo        string `json:"logo"`
-	MarketCap   string `json:"market_cap"`
-	Name        string `json:"name"`
-	Network     string `json:"network"`
-	PriceUSD    string `json:"price_usd"`
-	Symbol      string `json:"symbol"`
-	TotalSupply string `json:"total_supply"`
-	Volume24h   string `json:"volume_24h"`
-	IsInternal  bool   `json:"is_internal"`
-	Liquidity   string `json:"liquidity"`
-	S3Key       string `json:"s3_key"`
+	Address     string          `json:"address"`
+	Chain       string          `json:"chain"`
+	ChainID     int             `json:"chain_id"`
+	Decimals    int             `json:"decimals"`
+	Logo        string          `json:"logo"`
+	MarketCap   decimal.Decimal `json:"market_cap"`
+	Name        string          `json:"name"`
+	Network     string          `json:"network"`
+	PriceUSD    decimal.Decimal `json:"price_usd"`
+	Symbol      string          `json:"symbol"`
+	TotalSupply decimal.Decimal `json:"total_supply"`
+	Volume24h   decimal.Decimal `json:"volume_24h"`
+	IsInternal  bool            `json:"is_internal"`
+	Liquidity   decimal.Decimal `json:"liquidity"`
+	S3Key       string          `json:"s3_key"`
+}
+
+// TokenAlertMessage 币价预警消息结构体
+type TokenAlertMessage struct {
+	ID                  string          `json:"id"`                    // 预警ID，用于下游去重
+	IntelligenceID      string          `json:"intelligence_id"`       // 情报ID
+	Rule                string          `json:"rule"`                  // 命中的规则名称
+	Direction           string          `json:"direction"`             // pump: 上涨，dump: 下跌
+	Threshold           decimal.Decimal `json:"threshold"`             // 触发阈值（当前市值/预警市值）
+	Ratio               decimal.Decimal `json:"ratio"`                 // 当前比值
+	Name                string          `json:"name"`                  // 币名称
+	Symbol              string          `json:"symbol"`                // 币符号
+	ContractAddress     string          `json:"contract_address"`      // 合约地址
+	Chain               string          `json:"chain"`                 // 链slug
+	Logo                string          `json:"logo"`                  // 图标
+	WarningPriceUSD     decimal.Decimal `json:"warning_price_usd"`     // 预警价格
+	WarningMarketCap    decimal.Decimal `json:"warning_market_cap"`    // 预警市值
+	CurrentPriceUSD     decimal.Decimal `json:"current_price_usd"`     // 当前价格
+	CurrentMarketCap    decimal.Decimal `json:"current_market_cap"`    // 当前市值
+	PublishedAt         time.Time       `json:"published_at"`          // 情报发布时间
+	SincePublishSeconds int64           `json:"since_publish_seconds"` // 距情报发布的秒数
+	TriggeredAt         time.Time       `json:"triggered_at"`          // 触发时间
+}
+
+// 币事件类型
+const (
+	TokenEventRugged    = "rugged"
+	TokenEventGraduated = "graduated"
+)
+
+// TokenEventMessage 币状态变化事件
+// 毕业事件中 CurrentMarketCap 为毕业时的市值
+type TokenEventMessage struct {
+	ID                string          `json:"id"`                 // 事件ID，用于下游去重
+	EventType         string          `json:"event_type"`         // 事件类型，eg rugged
+	IntelligenceID    string          `json:"intelligence_id"`    // 情报ID
+	Reason            string          `json:"reason"`             // 事件原因
+	Name              string          `json:"name"`               // 币名称
+	Symbol            string          `json:"symbol"`             // 币符号
+	ContractAddress   string          `json:"contract_address"`   // 合约地址
+	Chain             string          `json:"chain"`              // 链slug
+	PreviousLiquidity decimal.Decimal `json:"previous_liquidity"` // 上次刷新的流动性
+	CurrentLiquidity  decimal.Decimal `json:"current_liquidity"`  // 当前流动性
+	Volume24h         decimal.Decimal `json:"volume_24h"`         // 24小时交易量
+	CurrentPriceUSD   decimal.Decimal `json:"current_price_usd"`  // 当前价格
+	CurrentMarketCap  decimal.Decimal `json:"current_market_cap"` // 当前市值
+	OccurredAt        time.Time       `json:"occurred_at"`        // 事件发生时间
 }
@@ -0,0 +1,201 @@
+package producer
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/lr"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// OutboxKey 待重发消息，有序集合，score 为下次重试时间或领取租约的到期时间（秒）
+	OutboxKey = "dogex:producer:outbox"
+	// OutboxDeadKey 超过最大重试次数的消息，列表
+	OutboxDeadKey = "dogex:producer:outbox:dead"
+
+	outboxRelayInterval = 2 * time.Second
+	outboxRelayBatch    = 50
+	outboxMaxBackoff    = 5 * time.Minute
+	outboxSaveTimeout   = 3 * time.Second
+	// outboxLease 领取后的租约，发送确认前进程退出时，租约到期后由其他实例重新领取
+	outboxLease = time.Minute
+)
+
+// claimOutboxScript 领取到期的消息，把 score 推迟到租约到期时间，消息仍留在outbox中
+// 已被其他实例领取（score 已推迟）或已删除时返回 0
+var claimOutboxScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+
+var outboxMaxAttempts = getEnvInt("OUTBOX_MAX_ATTEMPTS", 20)
+
+// OutboxEntry 发送失败待重试的消息
+type OutboxEntry struct {
+	ID              string `json:"id"`               // 消息ID，重发时保持不变，下游据此去重
+	Queue           string `json:"queue"`            // 目标队列
+	Body            []byte `json:"body"`             // 消息体
+	ContentEncoding string `json:"content_encoding"` // 消息体编码，eg gzip
+	Attempts        int    `json:"attempts"`         // 已重试次数
+	LastError       string `json:"last_error"`       // 最近一次失败原因
+	CreatedAt       int64  `json:"created_at"`       // 首次发送时间（秒）
+}
+
+// saveToOutbox 写入outbox，使用独立的超时，调用方 ctx 已取消（如退出时）也能写入
+func saveToOutbox(entry OutboxEntry) error {
+	return scheduleOutboxEntry(entry, time.Now())
+}
+
+func scheduleOutboxEntry(entry OutboxEntry, at time.Time) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSaveTimeout)
+	defer cancel()
+	return cache.MainRedis().ZAdd(ctx, OutboxKey, redis.Z{Score: float64(at.Unix()), Member: data}).Err()
+}
+
+// outboxBackoff 指数退避，1s、2s、4s…，最长5分钟
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 16 {
+		return outboxMaxBackoff
+	}
+	backoff := time.Second << attempts
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
+
+// StartOutboxRelay 启动outbox重发任务，ctx 取消后退出
+func StartOutboxRelay(ctx context.Context) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				lr.E().Errorf("Panic in outbox relay: %v", r)
+			}
+		}()
+
+		ticker := time.NewTicker(outboxRelayInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				relayOutbox(ctx)
+			}
+		}
+	}()
+}
+
+// relayOutbox 重发已到重试时间的消息
+func relayOutbox(ctx context.Context) {
+	members, err := cache.MainRedis().ZRangeByScore(ctx, OutboxKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   formatScore(time.Now()),
+		Count: outboxRelayBatch,
+	}).Result()
+	if err != nil {
+		lr.E().Errorf("Failed to read outbox: %v", err)
+		return
+	}
+
+	sent := 0
+	for _, member := range members {
+		// 领取成功的实例负责发送，多实例时不会重复处理；确认发送成功后才删除
+		now := time.Now()
+		claimed, err := claimOutboxScript.Run(ctx, cache.MainRedis(), []string{OutboxKey},
+			member, now.Unix(), now.Add(outboxLease).Unix()).Int()
+		if err != nil {
+			lr.E().Errorf("Failed to claim outbox entry: %v", err)
+			continue
+		}
+		if claimed == 0 {
+			continue
+		}
+
+		var entry OutboxEntry
+		if err := json.Unmarshal([]byte(member), &entry); err != nil {
+			lr.E().Errorf("Failed to unmarshal outbox entry, dropping: %v", err)
+			removeOutboxEntry(member)
+			continue
+		}
+
+		if err := publishMessage(ctx, entry.Queue, entry.ID, entry.Body, entry.ContentEncoding); err != nil {
+			publishOutcomes.WithLabelValues(entry.Queue, outcomeRelayFailed).Inc()
+			retryOutboxEntry(member, entry, err)
+			continue
+		}
+		publishOutcomes.WithLabelValues(entry.Queue, outcomeRelayed).Inc()
+		removeOutboxEntry(member)
+		sent++
+	}
+
+	if sent > 0 {
+		lr.I().Infof("Relayed %d outbox messages", sent)
+	}
+}
+
+// removeOutboxEntry 删除已发送的消息，删除失败时租约到期后会重发，下游按消息ID去重
+func removeOutboxEntry(member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSaveTimeout)
+	defer cancel()
+	if err := cache.MainRedis().ZRem(ctx, OutboxKey, member).Err(); err != nil {
+		lr.E().Errorf("Failed to remove outbox entry: %v", err)
+	}
+}
+
+// retryOutboxEntry 按退避时间重新放回outbox，超过最大次数移入死信列表
+// 替换领取的原消息在同一个事务中完成，失败时原消息租约到期后重新领取
+func retryOutboxEntry(member string, entry OutboxEntry, cause error) {
+	entry.Attempts++
+	entry.LastError = cause.Error()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		lr.E().Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSaveTimeout)
+	defer cancel()
+
+	if outboxMaxAttempts > 0 && entry.Attempts >= outboxMaxAttempts {
+		lr.E().Errorf("Outbox message %s to %s exceeded %d attempts, moving to dead list: %v", entry.ID, entry.Queue, entry.Attempts, cause)
+		_, err := cache.MainRedis().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, OutboxKey, member)
+			pipe.RPush(ctx, OutboxDeadKey, data)
+			return nil
+		})
+		if err != nil {
+			lr.E().Errorf("Failed to move outbox message %s to dead list: %v", entry.ID, err)
+		}
+		return
+	}
+
+	at := time.Now().Add(outboxBackoff(entry.Attempts))
+	_, err = cache.MainRedis().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, OutboxKey, member)
+		pipe.ZAdd(ctx, OutboxKey, redis.Z{Score: float64(at.Unix()), Member: data})
+		return nil
+	})
+	if err != nil {
+		lr.E().Errorf("Failed to reschedule outbox message %s: %v", entry.ID, err)
+	}
+}
+
+func formatScore(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
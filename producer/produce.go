@@ -3,20 +3,12 @@ package producer
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/remote"
-	"back_ai_gun_data/utils"
 	"context"
-	"encoding/json"
 	"fmt"
-	"time"
-
-	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 // convertGmGnTokenToMessage 将 GmGnToken 转换为 NewTokensMessage
-func convertGmGnTokenToMessage(token remote.GmGnToken) NewTokensMessage {
-	// 生成唯一的 S3 路径，按日期分层 + UUIDv7 保证唯一性
-	s3Key := fmt.Sprintf("image/%s-%s", time.Now().Format("2006-01-02"), utils.GenerateUUIDV7())
-
+func convertGmGnTokenToMessage(token remote.GmGnToken, s3Key string) NewTokensMessage {
 	return NewTokensMessage{
 		Address:     token.Address,
 		Chain:       token.Chain,
@@ -36,52 +28,76 @@ func convertGmGnTokenToMessage(token remote.GmGnToken) NewTokensMessage {
 	}
 }
 
-func SendNewTokensMessage(ctx context.Context, newTokens []remote.GmGnToken) error {
-	if err := ensureConnection(); err != nil {
-		lr.E().Error(err)
-		return err
-	}
+// SendNewTokensMessage 发送新币通知，去重窗口内已通知过的币跳过
+// 按条数和字节数拆分为多条消息，某一批发送失败时释放该批的去重键，其余批次继续发送
+func SendNewTokensMessage(ctx context.Context, newTokens []remote.GmGnToken) (NewTokensSendResult, error) {
+	var result NewTokensSendResult
 
 	// 转换为消息结构体
 	var messages []NewTokensMessage
+	var sentTokens []remote.GmGnToken
 	for _, token := range newTokens {
-		message := convertGmGnTokenToMessage(token)
-		messages = append(messages, message)
+		s3Key, isNew, err := AssignTokenS3Key(ctx, token)
+		if err != nil {
+			lr.E().Errorf("Failed to check new token dedup for %s: %v", token.Address, err)
+		}
+		if !isNew {
+			result.Suppressed++
+			continue
+		}
+		messages = append(messages, convertGmGnTokenToMessage(token, s3Key))
+		sentTokens = append(sentTokens, token)
 	}
 
-	// 构造消息体
-	messageBody := map[string]interface{}{
-		"entities": messages,
+	batches, err := splitBatches(messages, newTokensBatchMaxCount, newTokensBatchMaxBytes)
+	if err != nil {
+		releaseTokenDedup(ctx, sentTokens)
+		return result, err
 	}
 
-	// 序列化消息
-	body, err := json.Marshal(messageBody)
-	if err != nil {
+	var firstErr error
+	offset := 0
+	for _, batch := range batches {
+		batchTokens := sentTokens[offset : offset+len(batch)]
+		offset += len(batch)
+
+		// 构造消息体
+		messageBody := map[string]interface{}{
+			"entities": batch,
+		}
+		opts := PublishOptions{Summary: fmt.Sprintf("would publish %d new tokens", len(batch))}
+		if err := Publish(ctx, NewTokensQueue, messageBody, opts); err != nil {
+			lr.E().Error(err)
+			releaseTokenDedup(ctx, batchTokens)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		result.Sent += len(batch)
+	}
+
+	lr.I().Infof("New tokens message to %s: sent %d in %d batches, suppressed %d", NewTokensQueue, result.Sent, len(batches), result.Suppressed)
+	return result, firstErr
+}
+
+// SendTokenAlertMessage 发送币价预警消息
+func SendTokenAlertMessage(ctx context.Context, alert TokenAlertMessage) error {
+	if err := Publish(ctx, TokenAlertQueue, alert, PublishOptions{MessageID: alert.ID}); err != nil {
 		lr.E().Error(err)
 		return err
 	}
 
-	// 发送消息
-	err = channel.PublishWithContext(ctx,
-		"",               // exchange
-		"ai_token_queue", // routing key
-		false,            // mandatory
-		false,            // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent, // 消息持久化
-			Timestamp:    time.Now(),
-		},
-	)
+	return nil
+}
 
-	if err != nil {
+// SendTokenEventMessage 发送币状态变化事件
+func SendTokenEventMessage(ctx context.Context, event TokenEventMessage) error {
+	if err := Publish(ctx, TokenEventQueue, event, PublishOptions{MessageID: event.ID}); err != nil {
 		lr.E().Error(err)
 		return err
 	}
-	//fmt.Println(utils.ToJson(messageBody))
 
-	lr.I().Infof("Successfully sent new tokens message to ai_token_queue, tokens count: %v", newTokens)
 	return nil
 }
 
@@ -93,7 +109,7 @@ func SendNewTokensMessageAsync(ctx context.Context, newTokens []remote.GmGnToken
 			}
 		}()
 
-		if err := SendNewTokensMessage(ctx, newTokens); err != nil {
+		if _, err := SendNewTokensMessage(ctx, newTokens); err != nil {
 			lr.E().Errorf("Failed to send new tokens message asynchronously: %v", err)
 		}
 	}()
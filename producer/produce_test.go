@@ -1,7 +1,9 @@
 package producer
 
 import (
+	"back_ai_gun_data/pkg/bus"
 	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
 	"context"
 	"testing"
 	"time"
@@ -15,23 +17,23 @@ func TestMessageQueueService(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := Connect()
+	err := bus.Default().Ping(ctx)
 	if err != nil {
-		t.Logf("Connection test skipped (RabbitMQ not available): %v", err)
+		t.Logf("Connection test skipped (message bus not available): %v", err)
 		return
 	}
 
 	// 测试消息发送
-	testData := []map[string]interface{}{
-		{"name": "TestToken1", "address": "0x123"},
-		{"name": "TestToken2", "address": "0x456"},
+	testData := []remote.GmGnToken{
+		{Name: "TestToken1", Address: "0x123", Network: "bsc"},
+		{Name: "TestToken2", Address: "0x456", Network: "bsc"},
 	}
 
-	err = SendNewTokensMessage(ctx, testData)
+	_, err = SendNewTokensMessage(ctx, testData)
 	if err != nil {
 		t.Errorf("Failed to send message: %v", err)
 	}
 
 	// 清理
-	Close()
+	bus.Close()
 }
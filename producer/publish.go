@@ -0,0 +1,158 @@
+package producer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/metrics"
+	"back_ai_gun_data/utils"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+var (
+	// 消息体超过该字节数时gzip压缩，小于等于0不压缩
+	publishGzipThreshold = getEnvInt("PUBLISH_GZIP_THRESHOLD_BYTES", 128*1024)
+
+	// 新币通知单条消息的币数量和字节数上限
+	newTokensBatchMaxCount = getEnvInt("NEW_TOKENS_BATCH_MAX_COUNT", 50)
+	newTokensBatchMaxBytes = getEnvInt("NEW_TOKENS_BATCH_MAX_BYTES", 256*1024)
+)
+
+const contentEncodingGzip = "gzip"
+
+// 发送结果
+const (
+	outcomePublished   = "published"
+	outcomeOutbox      = "outbox"       // 发送失败，已写入outbox
+	outcomeFailed      = "failed"       // 发送和写入outbox都失败
+	outcomeDryRun      = "dry_run"      // dry-run 只记录
+	outcomeRelayed     = "relayed"      // outbox 重发成功
+	outcomeRelayFailed = "relay_failed" // outbox 重发失败，等待下次重试
+)
+
+var (
+	publishOutcomes = metrics.NewCounterVec("producer_publish_total", "Publish outcomes per queue.", "queue", "outcome")
+	publishDuration = metrics.NewHistogramVec("producer_publish_duration_seconds", "Time to publish and get the transport confirm per queue.", nil, "queue")
+)
+
+// PublishOptions 发送选项
+type PublishOptions struct {
+	MessageID string // 消息ID，为空时生成UUIDv7，下游按 message_id 去重
+	// GzipThreshold 消息体超过该字节数时gzip压缩；0 使用默认值 PUBLISH_GZIP_THRESHOLD_BYTES，负数不压缩
+	GzipThreshold int
+	// Summary dry-run 时记录的说明，为空时使用默认说明
+	Summary string
+}
+
+// Publish 序列化并发送持久化消息到指定队列，等待传输层确认
+// 发送失败时写入outbox由relay重试，写入outbox成功即视为发送成功；dry-run 时只记录消息内容
+func Publish(ctx context.Context, queueName string, payload interface{}, opts PublishOptions) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	messageID := opts.MessageID
+	if messageID == "" {
+		messageID = utils.GenerateUUIDV7()
+	}
+
+	if dryrun.Enabled() {
+		summary := opts.Summary
+		if summary == "" {
+			summary = fmt.Sprintf("would publish message %s", messageID)
+		}
+		dryrun.Record(ctx, dryrun.Diff{Sink: dryrun.SinkPublish, Op: "publish", Target: queueName, Summary: summary, After: json.RawMessage(body)})
+		publishOutcomes.WithLabelValues(queueName, outcomeDryRun).Inc()
+		return nil
+	}
+
+	threshold := opts.GzipThreshold
+	if threshold == 0 {
+		threshold = publishGzipThreshold
+	}
+	encoding := ""
+	if threshold > 0 && len(body) > threshold {
+		compressed, err := gzipBytes(body)
+		if err != nil {
+			return err
+		}
+		body, encoding = compressed, contentEncodingGzip
+	}
+
+	if err := publishMessage(ctx, queueName, messageID, body, encoding); err != nil {
+		lr.E().Errorf("Failed to publish message %s to %s, saving to outbox: %v", messageID, queueName, err)
+		entry := OutboxEntry{ID: messageID, Queue: queueName, Body: body, ContentEncoding: encoding, CreatedAt: time.Now().Unix()}
+		if outboxErr := saveToOutbox(entry); outboxErr != nil {
+			publishOutcomes.WithLabelValues(queueName, outcomeFailed).Inc()
+			return fmt.Errorf("publish failed: %w, save to outbox failed: %v", err, outboxErr)
+		}
+		publishOutcomes.WithLabelValues(queueName, outcomeOutbox).Inc()
+		return nil
+	}
+
+	publishOutcomes.WithLabelValues(queueName, outcomePublished).Inc()
+	return nil
+}
+
+// publishMessage 通过消息总线发送，等待传输层确认
+func publishMessage(ctx context.Context, queueName, messageID string, body []byte, encoding string) error {
+	defer func(start time.Time) {
+		publishDuration.WithLabelValues(queueName).Observe(time.Since(start).Seconds())
+	}(time.Now())
+	return bus.Default().Publish(ctx, queueName, bus.Message{
+		ID:              messageID,
+		Body:            body,
+		ContentType:     "application/json",
+		ContentEncoding: encoding,
+	})
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// splitBatches 按条数和序列化后的字节数拆分，保持原有顺序
+// 单条超过字节上限时单独成批；maxCount、maxBytes 小于等于0表示不限制
+func splitBatches[T any](items []T, maxCount, maxBytes int) ([][]T, error) {
+	var batches [][]T
+	var current []T
+	currentBytes := 0
+
+	for _, item := range items {
+		size := 0
+		if maxBytes > 0 {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			size = len(data) + 1 // 分隔逗号
+		}
+
+		full := maxCount > 0 && len(current) >= maxCount
+		overflow := maxBytes > 0 && len(current) > 0 && currentBytes+size > maxBytes
+		if full || overflow {
+			batches = append(batches, current)
+			current, currentBytes = nil, 0
+		}
+		current = append(current, item)
+		currentBytes += size
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
@@ -0,0 +1,95 @@
+package producer
+
+import (
+	"back_ai_gun_data/pkg/bus"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitBatches(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+
+	t.Run("按条数拆分", func(t *testing.T) {
+		batches, err := splitBatches(items, 2, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, batches)
+	})
+
+	t.Run("按字节数拆分", func(t *testing.T) {
+		// 每个元素序列化为 "x" 共3字节，加分隔符4字节
+		batches, err := splitBatches(items, 0, 9)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, batches)
+	})
+
+	t.Run("单条超过字节上限时单独成批", func(t *testing.T) {
+		batches, err := splitBatches([]string{"a", strings.Repeat("x", 20), "b"}, 0, 9)
+		assert.NoError(t, err)
+		assert.Len(t, batches, 3)
+	})
+
+	t.Run("不限制", func(t *testing.T) {
+		batches, err := splitBatches(items, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{items}, batches)
+	})
+
+	t.Run("空列表", func(t *testing.T) {
+		batches, err := splitBatches([]string{}, 2, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, batches)
+	})
+}
+
+func TestGzipBytes(t *testing.T) {
+	data := []byte(strings.Repeat(`{"name":"token"}`, 100))
+
+	compressed, err := gzipBytes(data)
+	assert.NoError(t, err)
+	assert.Less(t, len(compressed), len(data))
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	assert.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+}
+
+func TestPublishDryRun(t *testing.T) {
+	lr.Init()
+	b := bus.NewMemoryBus()
+	bus.SetDefault(b)
+	dryrun.SetEnabled(true)
+	dryrun.Output = filepath.Join(t.TempDir(), "diff.jsonl")
+	t.Cleanup(func() {
+		dryrun.SetEnabled(false)
+		dryrun.Close()
+		dryrun.Output = ""
+	})
+
+	err := Publish(context.Background(), "test-dry-run", map[string]int{"n": 1}, PublishOptions{MessageID: "m1", Summary: "would publish 1 new tokens"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, b.Len("test-dry-run"))
+
+	data, err := os.ReadFile(dryrun.Output)
+	assert.NoError(t, err)
+	var diff dryrun.Diff
+	assert.NoError(t, json.Unmarshal(data, &diff))
+	assert.Equal(t, dryrun.SinkPublish, diff.Sink)
+	assert.Equal(t, "test-dry-run", diff.Target)
+	assert.Equal(t, "would publish 1 new tokens", diff.Summary)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, diff.After)
+	assert.Equal(t, float64(1), testutil.ToFloat64(publishOutcomes.WithLabelValues("test-dry-run", outcomeDryRun)))
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"context"
+	"fmt"
+	"time"
+)
+
+// 情报准入结果
+const (
+	AdmissionPurge    = "purge"    // 已删除：清理缓存和 showed_tokens，不再检测
+	AdmissionLight    = "light"    // 不可见或低分：只做一轮检测
+	AdmissionNormal   = "normal"   // 默认检测频率
+	AdmissionPriority = "priority" // 有价值的高分情报：提高检测频率
+)
+
+// 情报消息优先级，上游发送时设置，消费者按优先级分档处理
+const (
+	IntelligenceMaxPriority    = 10
+	IntelligencePriorityHigh   = 9
+	IntelligencePriorityNormal = 5
+	IntelligencePriorityLow    = 1
+)
+
+// AdmissionPolicy 情报准入策略，决定情报进入处理流程的方式
+type AdmissionPolicy struct {
+	MinScore              float64       // 评分低于该值只做一轮检测
+	PriorityScore         float64       // 有价值且评分不低于该值时提高检测频率
+	PriorityInterval      time.Duration // 高优先级情报的检测间隔
+	PriorityMaxDetections int           // 高优先级情报的最大检测次数
+}
+
+// AdmissionDecision 单条情报的准入结果
+type AdmissionDecision struct {
+	Action        string
+	Reason        string
+	MaxDetections int           // 最大检测次数
+	Interval      time.Duration // 检测间隔
+}
+
+func defaultAdmissionPolicy() AdmissionPolicy {
+	return AdmissionPolicy{
+		MinScore:              decimal.FromString(consts.ADMISSION_MIN_SCORE).Float64(),
+		PriorityScore:         decimal.FromString(consts.ADMISSION_PRIORITY_SCORE).Float64(),
+		PriorityInterval:      consts.ADMISSION_PRIORITY_INTERVAL,
+		PriorityMaxDetections: consts.ADMISSION_PRIORITY_MAX_DETECTIONS,
+	}
+}
+
+// normalAdmission 默认检测频率，未经过准入判断的调用方使用
+func normalAdmission(reason string) AdmissionDecision {
+	return AdmissionDecision{Action: AdmissionNormal, Reason: reason, MaxDetections: maxDetections, Interval: detectionInterval}
+}
+
+// Decide 按删除、可见性、评分依次判断
+func (p AdmissionPolicy) Decide(data *model.IntelligenceData) AdmissionDecision {
+	switch {
+	case data.IsDeleted:
+		return AdmissionDecision{Action: AdmissionPurge, Reason: "deleted"}
+	case !data.IsVisible:
+		return AdmissionDecision{Action: AdmissionLight, Reason: "invisible", MaxDetections: 1, Interval: detectionInterval}
+	case data.Score < p.MinScore:
+		return AdmissionDecision{
+			Action:        AdmissionLight,
+			Reason:        fmt.Sprintf("score %g below %g", data.Score, p.MinScore),
+			MaxDetections: 1,
+			Interval:      detectionInterval,
+		}
+	case data.IsValuable && data.Score >= p.PriorityScore && p.PriorityMaxDetections > 0 && p.PriorityInterval > 0:
+		return AdmissionDecision{
+			Action:        AdmissionPriority,
+			Reason:        fmt.Sprintf("valuable with score %g not below %g", data.Score, p.PriorityScore),
+			MaxDetections: p.PriorityMaxDetections,
+			Interval:      p.PriorityInterval,
+		}
+	}
+	return normalAdmission(fmt.Sprintf("score %g", data.Score))
+}
+
+// IntelligencePriority 按准入结果计算情报消息的优先级，有价值的高分情报最高
+func IntelligencePriority(data *model.IntelligenceMessage) uint8 {
+	switch defaultAdmissionPolicy().Decide(&data.Data).Action {
+	case AdmissionPriority:
+		return IntelligencePriorityHigh
+	case AdmissionNormal:
+		return IntelligencePriorityNormal
+	}
+	return IntelligencePriorityLow
+}
+
+// AdmitIntelligence 判断情报的处理方式并记录原因
+func AdmitIntelligence(data *model.IntelligenceMessage) AdmissionDecision {
+	decision := defaultAdmissionPolicy().Decide(&data.Data)
+	lr.I().WithFields(lr.F{
+		"intelligence_id": data.ID,
+		"action":          decision.Action,
+		"reason":          decision.Reason,
+		"max_detections":  decision.MaxDetections,
+		"interval":        decision.Interval.String(),
+	}).Info("Intelligence admission decision")
+	return decision
+}
+
+// PurgeIntelligence 清理已删除情报的币缓存和 showed_tokens
+func PurgeIntelligence(ctx context.Context, intelligenceID string) error {
+	if err := PurgeTokenCache(ctx, intelligenceID); err != nil {
+		return err
+	}
+	if err := dao.ClearIntelligenceShowedTokens(ctx, intelligenceID); err != nil {
+		lr.E().Errorf("Failed to clear showed tokens of intelligence %s: %v", intelligenceID, err)
+		return err
+	}
+	lr.I().Infof("Purged deleted intelligence %s", intelligenceID)
+	return nil
+}
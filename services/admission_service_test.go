@@ -0,0 +1,65 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmissionPolicyDecide(t *testing.T) {
+	policy := AdmissionPolicy{MinScore: 30, PriorityScore: 80, PriorityInterval: 10 * time.Second, PriorityMaxDetections: 30}
+
+	tests := []struct {
+		name   string
+		data   model.IntelligenceData
+		action string
+	}{
+		{"deleted", model.IntelligenceData{IsDeleted: true, IsVisible: true, IsValuable: true, Score: 90}, AdmissionPurge},
+		{"invisible", model.IntelligenceData{IsVisible: false, IsValuable: true, Score: 90}, AdmissionLight},
+		{"low score", model.IntelligenceData{IsVisible: true, Score: 10}, AdmissionLight},
+		{"high score not valuable", model.IntelligenceData{IsVisible: true, Score: 90}, AdmissionNormal},
+		{"valuable", model.IntelligenceData{IsVisible: true, IsValuable: true, Score: 80}, AdmissionPriority},
+		{"normal", model.IntelligenceData{IsVisible: true, IsValuable: true, Score: 50}, AdmissionNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Decide(&tt.data)
+			assert.Equal(t, tt.action, decision.Action)
+			assert.NotEmpty(t, decision.Reason)
+		})
+	}
+
+	light := policy.Decide(&model.IntelligenceData{IsVisible: false})
+	assert.Equal(t, 1, light.MaxDetections)
+
+	priority := policy.Decide(&model.IntelligenceData{IsVisible: true, IsValuable: true, Score: 95})
+	assert.Equal(t, 30, priority.MaxDetections)
+	assert.Equal(t, 10*time.Second, priority.Interval)
+
+	normal := policy.Decide(&model.IntelligenceData{IsVisible: true, Score: 50})
+	assert.Equal(t, maxDetections, normal.MaxDetections)
+	assert.Equal(t, detectionInterval, normal.Interval)
+}
+
+func TestAdmissionPolicyDecideWithoutVisibility(t *testing.T) {
+	policy := AdmissionPolicy{MinScore: 30, PriorityScore: 80, PriorityInterval: 10 * time.Second, PriorityMaxDetections: 30}
+
+	// 消息不带 is_visible 时按可见处理，不降级为只检测一轮
+	var msg model.IntelligenceMessage
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"i1","data":{"id":"i1","is_valuable":true,"score":90}}`), &msg))
+	assert.Equal(t, AdmissionPriority, policy.Decide(&msg.Data).Action)
+}
+
+func TestIntelligencePriority(t *testing.T) {
+	high := &model.IntelligenceMessage{Data: model.IntelligenceData{IsVisible: true, IsValuable: true, Score: 100}}
+	assert.Equal(t, uint8(IntelligencePriorityHigh), IntelligencePriority(high))
+
+	normal := &model.IntelligenceMessage{Data: model.IntelligenceData{IsVisible: true, Score: 50}}
+	assert.Equal(t, uint8(IntelligencePriorityNormal), IntelligencePriority(normal))
+
+	invisible := &model.IntelligenceMessage{Data: model.IntelligenceData{IsValuable: true, Score: 100}}
+	assert.Equal(t, uint8(IntelligencePriorityLow), IntelligencePriority(invisible))
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/producer"
+	"back_ai_gun_data/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlertCacheKeyPrefix = "dogex:intelligence:alert:"
+
+	AlertDirectionPump = "pump"
+	AlertDirectionDump = "dump"
+)
+
+// AlertRule 预警规则
+// 按顺序匹配，每个币使用第一条命中链和市值分层的规则
+type AlertRule struct {
+	Name            string            `json:"name"`
+	Chains          []string          `json:"chains"`           // 适用的链slug，为空表示所有链
+	MinMarketCap    decimal.Decimal   `json:"min_market_cap"`   // 当前市值下限（含），为空不限
+	MaxMarketCap    decimal.Decimal   `json:"max_market_cap"`   // 当前市值上限（不含），为空不限
+	UpRatios        []decimal.Decimal `json:"up_ratios"`        // 上涨阈值，当前市值/预警市值 >= 阈值时触发，eg 5 表示5倍
+	DownRatios      []decimal.Decimal `json:"down_ratios"`      // 下跌阈值，当前市值/预警市值 <= 阈值时触发，eg 0.2 表示跌80%
+	CooldownSeconds int               `json:"cooldown_seconds"` // 同一币同一阈值的冷却时间
+}
+
+// AlertTrigger 单个币的预警触发结果
+type AlertTrigger struct {
+	Rule      *AlertRule
+	Direction string
+	Threshold decimal.Decimal
+	Ratio     decimal.Decimal
+}
+
+var (
+	alertRules     []AlertRule
+	alertRulesOnce sync.Once
+)
+
+// defaultAlertRules 默认规则：2/5/10/20/50/100倍上涨，跌50%、80%，冷却6小时
+func defaultAlertRules() []AlertRule {
+	ratios := func(values ...string) []decimal.Decimal {
+		result := make([]decimal.Decimal, 0, len(values))
+		for _, v := range values {
+			result = append(result, decimal.RequireFromString(v))
+		}
+		return result
+	}
+
+	return []AlertRule{
+		{
+			Name:            "default",
+			UpRatios:        ratios("2", "5", "10", "20", "50", "100"),
+			DownRatios:      ratios("0.5", "0.2"),
+			CooldownSeconds: int((6 * time.Hour).Seconds()),
+		},
+	}
+}
+
+func getAlertRules() []AlertRule {
+	alertRulesOnce.Do(func() {
+		alertRules = defaultAlertRules()
+		if consts.ALERT_RULES == "" {
+			return
+		}
+
+		var rules []AlertRule
+		if err := json.Unmarshal([]byte(consts.ALERT_RULES), &rules); err != nil {
+			lr.E().Errorf("Failed to parse ALERT_RULES, using default rules: %v", err)
+			return
+		}
+		alertRules = rules
+	})
+	return alertRules
+}
+
+// matches 判断规则是否适用于该币
+func (r *AlertRule) matches(token *dto_cache.IntelligenceToken) bool {
+	if len(r.Chains) > 0 {
+		matched := false
+		for _, chain := range r.Chains {
+			if strings.EqualFold(chain, token.Chain.Slug) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	marketCap := token.Stats.CurrentMarketCap
+	if r.MinMarketCap.IsValid() && marketCap.LessThan(r.MinMarketCap) {
+		return false
+	}
+	if r.MaxMarketCap.IsValid() && !marketCap.LessThan(r.MaxMarketCap) {
+		return false
+	}
+	return true
+}
+
+// evaluate 计算触发的阈值，上涨取已越过的最大阈值，下跌取已越过的最小阈值
+func (r *AlertRule) evaluate(ratio decimal.Decimal) (string, decimal.Decimal, bool) {
+	ups := append([]decimal.Decimal(nil), r.UpRatios...)
+	sort.Slice(ups, func(i, j int) bool { return ups[i].GreaterThan(ups[j]) })
+	for _, threshold := range ups {
+		if !ratio.LessThan(threshold) {
+			return AlertDirectionPump, threshold, true
+		}
+	}
+
+	downs := append([]decimal.Decimal(nil), r.DownRatios...)
+	sort.Slice(downs, func(i, j int) bool { return downs[i].LessThan(downs[j]) })
+	for _, threshold := range downs {
+		if !ratio.GreaterThan(threshold) {
+			return AlertDirectionDump, threshold, true
+		}
+	}
+
+	return "", decimal.Decimal{}, false
+}
+
+// EvaluateTokenAlert 按规则评估单个币，未触发返回nil
+func EvaluateTokenAlert(rules []AlertRule, token *dto_cache.IntelligenceToken) *AlertTrigger {
+	ratio, ok := token.Stats.CurrentMarketCap.Ratio(token.Stats.WarningMarketCap)
+	if !ok || !token.Stats.CurrentMarketCap.IsPositive() {
+		return nil
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(token) {
+			continue
+		}
+		direction, threshold, triggered := rule.evaluate(ratio)
+		if !triggered {
+			return nil
+		}
+		return &AlertTrigger{Rule: rule, Direction: direction, Threshold: threshold, Ratio: ratio}
+	}
+
+	return nil
+}
+
+// EvaluateAlerts 评估刷新后的币，按冷却时间去重后发送预警消息
+func EvaluateAlerts(ctx context.Context, intelligenceID string, tokens []dto_cache.IntelligenceToken) {
+	rules := getAlertRules()
+	if len(rules) == 0 {
+		return
+	}
+
+	var publishedAt time.Time
+	publishedAtLoaded := false
+	sentCount := 0
+
+	for i := range tokens {
+		token := &tokens[i]
+		// 跑路盘不再预警
+		if token.IsRugged {
+			continue
+		}
+		trigger := EvaluateTokenAlert(rules, token)
+		if trigger == nil {
+			continue
+		}
+
+		// 同一情报、同一币、同一阈值在冷却时间内只发送一次
+		dedupKey := fmt.Sprintf("%s%s:%s:%s:%s", AlertCacheKeyPrefix, intelligenceID, token.GetUniqueKey(), trigger.Direction, trigger.Threshold.String())
+		cooldown := time.Duration(trigger.Rule.CooldownSeconds) * time.Second
+		if cooldown <= 0 {
+			cooldown = CacheExpiration
+		}
+		acquired, err := acquireAlertDedup(ctx, dedupKey, cooldown)
+		if err != nil {
+			lr.E().Errorf("Failed to set alert dedup key %s: %v", dedupKey, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if !publishedAtLoaded {
+			publishedAt = getIntelligencePublishedAt(ctx, intelligenceID)
+			publishedAtLoaded = true
+		}
+
+		alert := buildTokenAlertMessage(intelligenceID, token, trigger, publishedAt)
+		if err := producer.SendTokenAlertMessage(ctx, alert); err != nil {
+			lr.E().Errorf("Failed to send token alert for %s: %v", token.GetUniqueKey(), err)
+			// 发送失败释放去重键，下次刷新重试
+			if err := cache.Del(ctx, dedupKey); err != nil {
+				lr.E().Error(err)
+			}
+			continue
+		}
+		sentCount++
+	}
+
+	if sentCount > 0 {
+		lr.I().Infof("Sent %d token alerts for intelligence %s", sentCount, intelligenceID)
+	}
+}
+
+// acquireAlertDedup 占用预警去重键，dry-run 时只检查是否在冷却中
+func acquireAlertDedup(ctx context.Context, key string, cooldown time.Duration) (bool, error) {
+	if dryrun.Enabled() {
+		exists, err := cache.Exists(ctx, key)
+		return exists == 0, err
+	}
+	return cache.MainRedis().SetNX(ctx, key, time.Now().Unix(), cooldown).Result()
+}
+
+func getIntelligencePublishedAt(ctx context.Context, intelligenceID string) time.Time {
+	intelligence, err := dao.GetIntelligenceByID(ctx, intelligenceID)
+	if err != nil || intelligence == nil {
+		return time.Time{}
+	}
+	return intelligence.PublishedAt
+}
+
+func buildTokenAlertMessage(intelligenceID string, token *dto_cache.IntelligenceToken, trigger *AlertTrigger, publishedAt time.Time) producer.TokenAlertMessage {
+	now := time.Now()
+	var sincePublish int64
+	if !publishedAt.IsZero() {
+		sincePublish = int64(now.Sub(publishedAt).Seconds())
+	}
+
+	return producer.TokenAlertMessage{
+		ID:                  utils.GenerateUUIDV7(),
+		IntelligenceID:      intelligenceID,
+		Rule:                trigger.Rule.Name,
+		Direction:           trigger.Direction,
+		Threshold:           trigger.Threshold,
+		Ratio:               trigger.Ratio.Round(6),
+		Name:                token.Name,
+		Symbol:              token.Symbol,
+		ContractAddress:     token.ContractAddress,
+		Chain:               token.Chain.Slug,
+		Logo:                token.Logo,
+		WarningPriceUSD:     token.Stats.WarningPriceUSD,
+		WarningMarketCap:    token.Stats.WarningMarketCap,
+		CurrentPriceUSD:     token.Stats.CurrentPriceUSD,
+		CurrentMarketCap:    token.Stats.CurrentMarketCap,
+		PublishedAt:         publishedAt,
+		SincePublishSeconds: sincePublish,
+		TriggeredAt:         now,
+	}
+}
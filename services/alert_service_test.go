@@ -0,0 +1,68 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAlertTestToken(chain, warningMarketCap, currentMarketCap string) *dto_cache.IntelligenceToken {
+	return &dto_cache.IntelligenceToken{
+		Name:            "Test",
+		ContractAddress: "0xabc",
+		Chain:           dto_cache.ChainInfo{Slug: chain},
+		Stats: dto_cache.CoinMarketStats{
+			WarningMarketCap: decimal.FromString(warningMarketCap),
+			CurrentMarketCap: decimal.FromString(currentMarketCap),
+		},
+	}
+}
+
+func TestEvaluateTokenAlert(t *testing.T) {
+	var rules []AlertRule
+	err := json.Unmarshal([]byte(`[
+		{"name": "sui", "chains": ["sui"], "up_ratios": ["3"], "down_ratios": [], "cooldown_seconds": 60},
+		{"name": "large_cap", "min_market_cap": "10000000", "up_ratios": ["1.5"], "down_ratios": ["0.7"]},
+		{"name": "default", "up_ratios": ["2", "5", "10"], "down_ratios": ["0.5", "0.2"]}
+	]`), &rules)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     *dto_cache.IntelligenceToken
+		rule      string
+		direction string
+		threshold string
+	}{
+		{name: "上涨取已越过的最大阈值", token: newAlertTestToken("solana", "100000", "650000"), rule: "default", direction: AlertDirectionPump, threshold: "5"},
+		{name: "下跌取已越过的最小阈值", token: newAlertTestToken("solana", "100000", "15000"), rule: "default", direction: AlertDirectionDump, threshold: "0.2"},
+		{name: "按链匹配规则", token: newAlertTestToken("SUI", "100", "400"), rule: "sui", direction: AlertDirectionPump, threshold: "3"},
+		{name: "按市值分层匹配规则", token: newAlertTestToken("bsc", "10000000", "16000000"), rule: "large_cap", direction: AlertDirectionPump, threshold: "1.5"},
+		{name: "极小市值", token: newAlertTestToken("solana", "1e-6", "1.2e-5"), rule: "default", direction: AlertDirectionPump, threshold: "10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger := EvaluateTokenAlert(rules, tt.token)
+			if assert.NotNil(t, trigger) {
+				assert.Equal(t, tt.rule, trigger.Rule.Name)
+				assert.Equal(t, tt.direction, trigger.Direction)
+				assert.Equal(t, tt.threshold, trigger.Threshold.String())
+			}
+		})
+	}
+
+	t.Run("未越过阈值", func(t *testing.T) {
+		assert.Nil(t, EvaluateTokenAlert(rules, newAlertTestToken("solana", "100000", "150000")))
+	})
+	t.Run("首条命中的规则未触发时不再匹配后续规则", func(t *testing.T) {
+		assert.Nil(t, EvaluateTokenAlert(rules, newAlertTestToken("sui", "100", "10")))
+	})
+	t.Run("缺少预警市值", func(t *testing.T) {
+		assert.Nil(t, EvaluateTokenAlert(rules, newAlertTestToken("solana", "", "150000")))
+		assert.Nil(t, EvaluateTokenAlert(rules, newAlertTestToken("solana", "0", "150000")))
+	})
+}
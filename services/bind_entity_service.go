@@ -5,6 +5,7 @@ import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
 	"back_ai_gun_data/utils"
+	"context"
 )
 
 const (
@@ -14,9 +15,9 @@ const (
 	EntityTagTypeAlias = "alias"
 )
 
-func ProcessTagAssociation(entityID, projectName string) error {
+func ProcessTagAssociation(ctx context.Context, entityID, projectName string) error {
 	// 查找tag
-	tag, err := dao.GetTagBySlug(projectName)
+	tag, err := dao.GetTagBySlug(ctx, projectName)
 	if err != nil {
 		lr.E().Errorf("Failed to get tag by slug: %v", err)
 		return err
@@ -33,7 +34,7 @@ func ProcessTagAssociation(entityID, projectName string) error {
 			IsDeleted: false,
 		}
 
-		if err := dao.CreateTag(newTag); err != nil {
+		if err := dao.CreateTag(ctx, newTag); err != nil {
 			lr.E().Errorf("Failed to create tag: %v", err)
 			return err
 		}
@@ -52,7 +53,7 @@ func ProcessTagAssociation(entityID, projectName string) error {
 		IsDeleted: false,
 	}
 
-	if err := dao.CreateEntityTag(entityTag); err != nil {
+	if err := dao.CreateEntityTag(ctx, entityTag); err != nil {
 		lr.E().Errorf("Failed to create entity tag: %v", err)
 		return err
 	}
@@ -62,9 +63,9 @@ func ProcessTagAssociation(entityID, projectName string) error {
 }
 
 // BindAllEntities 绑定所有实体 - 扁平化实现
-func BindAllEntities() error {
+func BindAllEntities(ctx context.Context) error {
 	// 第一步：清空project_chain_data的entity_id
-	//if err := dao.ClearProjectChainDataEntityID(); err != nil {
+	//if err := dao.ClearProjectChainDataEntityID(ctx); err != nil {
 	//	lr.E().Errorf("清空project_chain_data的entity_id失败: %v", err)
 	//	return err
 	//}
@@ -77,8 +78,12 @@ func BindAllEntities() error {
 	totalBound := 0
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		// 获取一批没有entity_id的project_chain_data
-		projectChains, err := dao.GetProjectChainDataWithoutEntityID(offset, batchSize)
+		projectChains, err := dao.GetProjectChainDataWithoutEntityID(ctx, offset, batchSize)
 		if err != nil {
 			lr.E().Errorf("获取project_chain_data失败: %v", err)
 			return err
@@ -100,7 +105,7 @@ func BindAllEntities() error {
 			normalizedName := utils.NormalizeName(*projectChain.Name)
 
 			// 查找entity（type是project）
-			entity, err := dao.GetEntityBySlugAndType(normalizedName, EntityTypeProject)
+			entity, err := dao.GetEntityBySlugAndType(ctx, normalizedName, EntityTypeProject)
 			if err != nil {
 				lr.E().Errorf("查找entity失败，ID: %s, Name: %s, Error: %v", projectChain.ID, *projectChain.Name, err)
 				continue
@@ -111,13 +116,13 @@ func BindAllEntities() error {
 			}
 
 			// 更新project_chain_data的entity_id
-			if err := dao.UpdateProjectChainDataEntityID(projectChain.ID, entity.ID); err != nil {
+			if err := dao.UpdateProjectChainDataEntityID(ctx, projectChain.ID, entity.ID); err != nil {
 				lr.E().Errorf("更新entity_id失败，ID: %s, Error: %v", projectChain.ID, err)
 				continue
 			}
 
 			// 处理tag关联
-			if err := ProcessTagAssociation(entity.ID, *projectChain.Name); err != nil {
+			if err := ProcessTagAssociation(ctx, entity.ID, *projectChain.Name); err != nil {
 				lr.I().Infof("处理tag关联失败，但不影响主流程: %v", err)
 			}
 
@@ -3,6 +3,7 @@ package services
 import (
 	"back_ai_gun_data/pkg/dao"
 	"back_ai_gun_data/pkg/lr"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,6 +13,6 @@ func TestBindAllEntities(t *testing.T) {
 	lr.Init()
 	dao.Init()
 
-	err := BindAllEntities()
+	err := BindAllEntities(context.Background())
 	assert.NoError(t, err)
 }
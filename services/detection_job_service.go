@@ -0,0 +1,69 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DetectionJob 正在进行的情报多轮检测
+type DetectionJob struct {
+	IntelligenceID string    `json:"intelligence_id"`
+	Action         string    `json:"action"`
+	Rounds         int       `json:"rounds"` // 已完成的检测轮数
+	MaxRounds      int       `json:"max_rounds"`
+	Interval       string    `json:"interval"`
+	StartedAt      time.Time `json:"started_at"`
+	NextRunAt      time.Time `json:"next_run_at"`
+}
+
+var (
+	detectionJobs      = make(map[string]*DetectionJob)
+	detectionJobsMutex sync.RWMutex
+)
+
+// startDetectionJob 登记情报的多轮检测，返回的函数在检测结束时调用
+func startDetectionJob(intelligenceID string, decision AdmissionDecision, rounds int) (advance func(rounds int), finish func()) {
+	now := time.Now()
+	job := &DetectionJob{
+		IntelligenceID: intelligenceID,
+		Action:         decision.Action,
+		Rounds:         rounds,
+		MaxRounds:      decision.MaxDetections,
+		Interval:       decision.Interval.String(),
+		StartedAt:      now,
+		NextRunAt:      now,
+	}
+	detectionJobsMutex.Lock()
+	detectionJobs[intelligenceID] = job
+	detectionJobsMutex.Unlock()
+
+	advance = func(rounds int) {
+		detectionJobsMutex.Lock()
+		defer detectionJobsMutex.Unlock()
+		job.Rounds = rounds
+		job.NextRunAt = time.Now().Add(decision.Interval)
+	}
+	finish = func() {
+		detectionJobsMutex.Lock()
+		defer detectionJobsMutex.Unlock()
+		// 同一情报被重新处理时只移除自己登记的任务
+		if detectionJobs[intelligenceID] == job {
+			delete(detectionJobs, intelligenceID)
+		}
+	}
+	return advance, finish
+}
+
+// ListDetectionJobs 正在进行的多轮检测，按开始时间排序
+func ListDetectionJobs() []DetectionJob {
+	detectionJobsMutex.RLock()
+	jobs := make([]DetectionJob, 0, len(detectionJobs))
+	for _, job := range detectionJobs {
+		jobs = append(jobs, *job)
+	}
+	detectionJobsMutex.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.Before(jobs[j].StartedAt) })
+	return jobs
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/producer"
+	"back_ai_gun_data/utils"
+	"context"
+	"time"
+)
+
+// applyLaunchpadState 根据行情更新内盘状态，内盘币变为外盘时记录毕业时间和市值
+// 返回本次是否毕业；价格源不提供内盘状态时不做任何变动
+func applyLaunchpadState(token *dto_cache.IntelligenceToken, quote remote.TokenQuote) bool {
+	if quote.IsInternal == nil {
+		return false
+	}
+
+	if *quote.IsInternal {
+		// 毕业是单向的，已记录毕业的币不再回到内盘
+		if token.GraduatedAt.IsZero() {
+			token.IsInternal = true
+		}
+		return false
+	}
+
+	wasInternal := token.IsInternal
+	token.IsInternal = false
+	if !wasInternal || !token.GraduatedAt.IsZero() {
+		return false
+	}
+
+	token.GraduatedAt = dto_cache.CustomTime{Time: time.Now()}
+	token.GraduationMarketCap = quote.MarketCap
+	return true
+}
+
+// publishGraduationEvents 发送毕业事件
+func publishGraduationEvents(ctx context.Context, intelligenceID string, tokens []dto_cache.IntelligenceToken, graduated map[string]struct{}) {
+	for i := range tokens {
+		token := &tokens[i]
+		if _, ok := graduated[token.GetUniqueKey()]; !ok {
+			continue
+		}
+
+		event := producer.TokenEventMessage{
+			ID:               utils.GenerateUUIDV7(),
+			EventType:        producer.TokenEventGraduated,
+			IntelligenceID:   intelligenceID,
+			Name:             token.Name,
+			Symbol:           token.Symbol,
+			ContractAddress:  token.ContractAddress,
+			Chain:            token.Chain.Slug,
+			CurrentLiquidity: token.Stats.Liquidity,
+			Volume24h:        token.Stats.Volume24h,
+			CurrentPriceUSD:  token.Stats.CurrentPriceUSD,
+			CurrentMarketCap: token.GraduationMarketCap,
+			OccurredAt:       token.GraduatedAt.Time,
+		}
+		if err := producer.SendTokenEventMessage(ctx, event); err != nil {
+			lr.E().Errorf("Failed to send graduation event for %s: %v", token.GetUniqueKey(), err)
+			continue
+		}
+		lr.I().Infof("Token %s of intelligence %s graduated at market cap %s", token.GetUniqueKey(), intelligenceID, token.GraduationMarketCap.String())
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLaunchpadQuote(isInternal *bool, marketCap string) remote.TokenQuote {
+	return remote.TokenQuote{IsInternal: isInternal, MarketCap: decimal.RequireFromString(marketCap)}
+}
+
+func TestApplyLaunchpadState(t *testing.T) {
+	internal, external := true, false
+
+	t.Run("内盘变为外盘时记录毕业", func(t *testing.T) {
+		token := &dto_cache.IntelligenceToken{IsInternal: true}
+		assert.True(t, applyLaunchpadState(token, newLaunchpadQuote(&external, "69000")))
+		assert.False(t, token.IsInternal)
+		assert.False(t, token.GraduatedAt.IsZero())
+		assert.Equal(t, "69000", token.GraduationMarketCap.String())
+
+		// 再次刷新不重复毕业
+		assert.False(t, applyLaunchpadState(token, newLaunchpadQuote(&external, "80000")))
+		assert.Equal(t, "69000", token.GraduationMarketCap.String())
+	})
+
+	t.Run("已毕业的币不回到内盘", func(t *testing.T) {
+		token := &dto_cache.IntelligenceToken{GraduatedAt: dto_cache.CustomTime{Time: time.Now()}}
+		assert.False(t, applyLaunchpadState(token, newLaunchpadQuote(&internal, "1000")))
+		assert.False(t, token.IsInternal)
+	})
+
+	t.Run("一直在外盘的币不算毕业", func(t *testing.T) {
+		token := &dto_cache.IntelligenceToken{}
+		assert.False(t, applyLaunchpadState(token, newLaunchpadQuote(&external, "1000")))
+		assert.True(t, token.GraduatedAt.IsZero())
+	})
+
+	t.Run("价格源不提供内盘状态", func(t *testing.T) {
+		token := &dto_cache.IntelligenceToken{IsInternal: true}
+		assert.False(t, applyLaunchpadState(token, newLaunchpadQuote(nil, "1000")))
+		assert.True(t, token.IsInternal)
+	})
+}
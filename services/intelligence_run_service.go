@@ -0,0 +1,340 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"back_ai_gun_data/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IntelligenceRunKeyPrefix 情报处理记录，按 情报ID:版本:内容摘要 去重
+const IntelligenceRunKeyPrefix = "dogex:intelligence:run:"
+
+// IntelligenceLockKeyPrefix 情报检测锁，按情报ID互斥，避免消费者和手动检测同时写入币缓存
+const IntelligenceLockKeyPrefix = "dogex:intelligence:lock:"
+
+// 情报处理状态
+const (
+	IntelligenceRunStarted = "started"
+	IntelligenceRunDone    = "done"
+	IntelligenceRunFailed  = "failed"
+)
+
+var (
+	// ErrIntelligenceRunDone 相同内容的情报已处理完成
+	ErrIntelligenceRunDone = errors.New("intelligence already processed")
+	// ErrIntelligenceRunInProgress 相同内容的情报正在其他消费者中处理，或该情报正在检测
+	ErrIntelligenceRunInProgress = errors.New("intelligence is being processed")
+	// ErrIntelligenceRunSuperseded 处理记录已被其他消费者接管
+	ErrIntelligenceRunSuperseded = errors.New("intelligence run superseded")
+	// ErrIntelligenceLocked 情报正在被消费者或手动检测处理
+	ErrIntelligenceLocked = errors.New("intelligence is being detected")
+)
+
+// 记录状态更新的超时时间，处理被取消后也要写回状态
+const intelligenceRunUpdateTimeout = 5 * time.Second
+
+// acquireRunScript 获取处理权，KEYS[2] 为情报检测锁
+// 已完成返回 done；处理中且心跳未过期，或检测锁被其他处理持有时返回 busy；否则接管记录并持有检测锁，返回处理次数和已完成的检测轮数
+var acquireRunScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+if ARGV[4] == '1' then
+	redis.call('DEL', KEYS[1])
+end
+local state = redis.call('HGET', KEYS[1], 'state')
+if state == 'done' then
+	return {'done', 0, 0}
+end
+if state == 'started' then
+	local heartbeat = tonumber(redis.call('HGET', KEYS[1], 'heartbeat') or '0')
+	if now - heartbeat < lease then
+		return {'busy', 0, 0}
+	end
+end
+local owner = redis.call('GET', KEYS[2])
+if owner and owner ~= KEYS[1] then
+	return {'busy', 0, 0}
+end
+redis.call('SET', KEYS[2], KEYS[1], 'PX', lease)
+local attempt = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+redis.call('HSET', KEYS[1], 'state', 'started', 'heartbeat', now, 'error', '')
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+local rounds = tonumber(redis.call('HGET', KEYS[1], 'rounds') or '0')
+return {'acquired', attempt, rounds}
+`)
+
+// updateRunScript 当前处理次数仍持有记录时更新字段，被接管后返回 0
+var updateRunScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'attempts') ~= ARGV[1] then
+	return 0
+end
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// lockScript 检测锁的持有者为 ARGV[1] 时续期（ARGV[2] 为租约毫秒）或释放（ARGV[2] 为 0），否则返回 0
+var lockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '0' then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// IntelligenceRun 单条情报的处理记录
+// 为 nil 时表示不做幂等控制，方法均可安全调用
+type IntelligenceRun struct {
+	Key     string
+	Attempt int64 // 第几次处理，用于识别记录是否被接管
+	Rounds  int   // 已完成的检测轮数，中断后从这里继续
+
+	lock          *IntelligenceLock
+	stopHeartbeat context.CancelFunc
+	heartbeatDone chan struct{}
+}
+
+// IntelligenceLock 情报检测锁，持有期间定时续期
+type IntelligenceLock struct {
+	Key   string
+	owner string
+
+	stopRenew context.CancelFunc
+	renewDone chan struct{}
+}
+
+// intelligenceRunKey 处理记录的键，同一情报内容变化后视为新的处理
+func intelligenceRunKey(data *model.IntelligenceMessage) (string, error) {
+	content, err := json.Marshal(data.Data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%s%s:%s:%s", IntelligenceRunKeyPrefix, data.ID, data.Version, hex.EncodeToString(sum[:8])), nil
+}
+
+// AcquireIntelligenceRun 获取情报的处理权
+// 已处理完成返回 ErrIntelligenceRunDone，正在处理或情报检测锁被占用返回 ErrIntelligenceRunInProgress
+// 消息带 reprocess 标记时忽略已有记录重新处理；dry-run 时不记录，每次都重新处理
+func AcquireIntelligenceRun(ctx context.Context, data *model.IntelligenceMessage) (*IntelligenceRun, error) {
+	if dryrun.Enabled() {
+		return nil, nil
+	}
+	key, err := intelligenceRunKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	reprocess := "0"
+	if data.Reprocess {
+		reprocess = "1"
+	}
+	lockKey := IntelligenceLockKeyPrefix + data.ID
+	result, err := acquireRunScript.Run(ctx, cache.MainRedis(), []string{key, lockKey},
+		time.Now().UnixMilli(), consts.INTELLIGENCE_RUN_LEASE.Milliseconds(), consts.INTELLIGENCE_RUN_TTL.Milliseconds(), reprocess).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 3 {
+		return nil, fmt.Errorf("unexpected acquire result: %v", result)
+	}
+
+	switch result[0] {
+	case "done":
+		return nil, ErrIntelligenceRunDone
+	case "busy":
+		return nil, ErrIntelligenceRunInProgress
+	}
+	attempt, _ := result[1].(int64)
+	rounds, _ := result[2].(int64)
+	run := &IntelligenceRun{Key: key, Attempt: attempt, Rounds: int(rounds)}
+	run.lock = &IntelligenceLock{Key: lockKey, owner: key}
+	run.lock.startRenew(ctx)
+	run.startHeartbeat(ctx)
+	return run, nil
+}
+
+// AcquireIntelligenceLock 获取情报检测锁，用于不经过消费者的手动检测
+// 消费者正在处理该情报或其他手动检测未结束时返回 ErrIntelligenceLocked
+func AcquireIntelligenceLock(ctx context.Context, intelligenceID string) (*IntelligenceLock, error) {
+	lock := &IntelligenceLock{Key: IntelligenceLockKeyPrefix + intelligenceID, owner: utils.GenerateUUIDV7()}
+	acquired, err := cache.MainRedis().SetNX(ctx, lock.Key, lock.owner, consts.INTELLIGENCE_RUN_LEASE).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrIntelligenceLocked
+	}
+	lock.startRenew(ctx)
+	return lock, nil
+}
+
+// startRenew 与处理记录的心跳同周期续期检测锁
+func (l *IntelligenceLock) startRenew(ctx context.Context) {
+	renewCtx, cancel := context.WithCancel(ctx)
+	l.stopRenew = cancel
+	l.renewDone = make(chan struct{})
+
+	go func() {
+		defer close(l.renewDone)
+		defer func() {
+			if rec := recover(); rec != nil {
+				lr.E().Errorf("Panic in intelligence lock renewal %s: %v", l.Key, rec)
+			}
+		}()
+
+		ticker := time.NewTicker(intelligenceRunHeartbeatInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				held, err := l.run(renewCtx, consts.INTELLIGENCE_RUN_LEASE.Milliseconds())
+				if err == nil && !held {
+					lr.E().Errorf("Intelligence lock %s lost, stop renewal", l.Key)
+					return
+				}
+				if err != nil && renewCtx.Err() == nil {
+					lr.E().Errorf("Failed to renew intelligence lock %s: %v", l.Key, err)
+				}
+			}
+		}
+	}()
+}
+
+// Release 停止续期并释放检测锁，为 nil 时可安全调用
+func (l *IntelligenceLock) Release(ctx context.Context) {
+	if l == nil {
+		return
+	}
+	if l.stopRenew != nil {
+		l.stopRenew()
+		<-l.renewDone
+	}
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), intelligenceRunUpdateTimeout)
+	defer cancel()
+	if _, err := l.run(releaseCtx, 0); err != nil {
+		lr.E().Errorf("Failed to release intelligence lock %s: %v", l.Key, err)
+	}
+}
+
+func (l *IntelligenceLock) run(ctx context.Context, leaseMillis int64) (bool, error) {
+	held, err := lockScript.Run(ctx, cache.MainRedis(), []string{l.Key}, l.owner, leaseMillis).Int()
+	return held == 1, err
+}
+
+// startHeartbeat 在获取处理权到 Finish 之间定时续期心跳
+// 第一轮检测完成前的行情刷新等步骤也可能超过租约，不能只在检查点续期
+func (r *IntelligenceRun) startHeartbeat(ctx context.Context) {
+	heartbeatCtx, cancel := context.WithCancel(ctx)
+	r.stopHeartbeat = cancel
+	r.heartbeatDone = make(chan struct{})
+
+	go func() {
+		defer close(r.heartbeatDone)
+		defer func() {
+			if rec := recover(); rec != nil {
+				lr.E().Errorf("Panic in intelligence run heartbeat %s: %v", r.Key, rec)
+			}
+		}()
+
+		ticker := time.NewTicker(intelligenceRunHeartbeatInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+				err := r.update(heartbeatCtx, "heartbeat", strconv.FormatInt(time.Now().UnixMilli(), 10))
+				if errors.Is(err, ErrIntelligenceRunSuperseded) {
+					lr.E().Errorf("Intelligence run %s superseded, stop heartbeat", r.Key)
+					return
+				}
+				if err != nil && heartbeatCtx.Err() == nil {
+					lr.E().Errorf("Failed to renew intelligence run heartbeat %s: %v", r.Key, err)
+				}
+			}
+		}
+	}()
+}
+
+// intelligenceRunHeartbeatInterval 心跳间隔为租约的三分之一，续期失败一两次也不会被接管
+func intelligenceRunHeartbeatInterval() time.Duration {
+	interval := consts.INTELLIGENCE_RUN_LEASE / 3
+	if interval < time.Second {
+		return time.Second
+	}
+	return interval
+}
+
+// Checkpoint 记录已完成的检测轮数并续期心跳
+func (r *IntelligenceRun) Checkpoint(ctx context.Context, rounds int) error {
+	if r == nil {
+		return nil
+	}
+	r.Rounds = rounds
+	return r.update(ctx, "rounds", strconv.Itoa(rounds), "heartbeat", strconv.FormatInt(time.Now().UnixMilli(), 10))
+}
+
+// Finish 按处理结果标记完成或失败，失败的记录可被重新投递的消息接管
+func (r *IntelligenceRun) Finish(ctx context.Context, cause error) {
+	if r == nil {
+		return
+	}
+	// 处理已结束，停止续期心跳
+	if r.stopHeartbeat != nil {
+		r.stopHeartbeat()
+		<-r.heartbeatDone
+	}
+	// 失败时也释放检测锁，重新投递的消息凭处理记录的心跳判断是否接管
+	defer r.lock.Release(ctx)
+	// 处理被取消时也要写回状态，否则只能等心跳过期才能重新处理
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), intelligenceRunUpdateTimeout)
+	defer cancel()
+
+	var err error
+	if cause == nil {
+		err = r.update(updateCtx, "state", IntelligenceRunDone)
+	} else {
+		err = r.update(updateCtx, "state", IntelligenceRunFailed, "error", cause.Error())
+	}
+	if err != nil && !errors.Is(err, ErrIntelligenceRunSuperseded) {
+		lr.E().Errorf("Failed to finish intelligence run %s: %v", r.Key, err)
+	}
+}
+
+func (r *IntelligenceRun) update(ctx context.Context, fields ...string) error {
+	args := make([]interface{}, 0, len(fields)+2)
+	args = append(args, strconv.FormatInt(r.Attempt, 10), consts.INTELLIGENCE_RUN_TTL.Milliseconds())
+	for _, field := range fields {
+		args = append(args, field)
+	}
+	updated, err := updateRunScript.Run(ctx, cache.MainRedis(), []string{r.Key}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrIntelligenceRunSuperseded
+	}
+	return nil
+}
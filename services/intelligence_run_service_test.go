@@ -0,0 +1,123 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntelligenceRunKey(t *testing.T) {
+	data := &model.IntelligenceMessage{
+		BaseMessage: model.BaseMessage{ID: "i1", Version: "2"},
+		Data:        model.IntelligenceData{ID: "i1", Title: "PEPE"},
+	}
+	key, err := intelligenceRunKey(data)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, IntelligenceRunKeyPrefix+"i1:2:"))
+
+	// 强制重新处理标记不影响记录键
+	data.Reprocess = true
+	same, _ := intelligenceRunKey(data)
+	assert.Equal(t, key, same)
+
+	// 内容变化后视为新的处理
+	data.Data.Title = "DOGE"
+	changed, _ := intelligenceRunKey(data)
+	assert.NotEqual(t, key, changed)
+}
+
+func TestIntelligenceRunHeartbeatStops(t *testing.T) {
+	assert.Equal(t, consts.INTELLIGENCE_RUN_LEASE/3, intelligenceRunHeartbeatInterval())
+
+	// 消息处理取消后心跳随之退出
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &IntelligenceRun{Key: "k", Attempt: 1}
+	run.startHeartbeat(ctx)
+	cancel()
+	select {
+	case <-run.heartbeatDone:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat did not stop")
+	}
+}
+
+func TestProcessIntelligenceDataRetriesWhileLeaseHeld(t *testing.T) {
+	lr.Init()
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", mr.Addr())
+	cache.Init()
+
+	ctx := context.Background()
+	data := &model.IntelligenceMessage{
+		BaseMessage: model.BaseMessage{ID: "i1", Version: "2"},
+		Data:        model.IntelligenceData{ID: "i1", Title: "PEPE", IsVisible: true},
+	}
+	key, err := intelligenceRunKey(data)
+	assert.NoError(t, err)
+
+	// 原消费者崩溃：心跳已错过两次续期，但租约还没过期
+	heartbeat := time.Now().Add(-2 * intelligenceRunHeartbeatInterval())
+	assert.NoError(t, cache.MainRedis().HSet(ctx, key, "state", IntelligenceRunStarted, "attempts", 1,
+		"heartbeat", strconv.FormatInt(heartbeat.UnixMilli(), 10)).Err())
+
+	// 重复投递的消息不能被确认，返回错误由 consumer 稍后重新发送
+	err = ProcessIntelligenceData(ctx, data)
+	assert.ErrorIs(t, err, ErrIntelligenceRunInProgress)
+
+	// 租约过期后重新发送的消息接管处理
+	expired := time.Now().Add(-consts.INTELLIGENCE_RUN_LEASE - time.Second)
+	assert.NoError(t, cache.MainRedis().HSet(ctx, key, "heartbeat", strconv.FormatInt(expired.UnixMilli(), 10)).Err())
+	run, err := AcquireIntelligenceRun(ctx, data)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), run.Attempt)
+	run.Finish(ctx, nil)
+}
+
+func TestIntelligenceLock(t *testing.T) {
+	lr.Init()
+	mr := miniredis.RunT(t)
+	t.Setenv("REDIS_ADDR", mr.Addr())
+	cache.Init()
+
+	ctx := context.Background()
+	data := &model.IntelligenceMessage{
+		BaseMessage: model.BaseMessage{ID: "i1", Version: "2"},
+		Data:        model.IntelligenceData{ID: "i1", Title: "PEPE", IsVisible: true},
+	}
+
+	// 消费者处理期间不能手动检测
+	run, err := AcquireIntelligenceRun(ctx, data)
+	assert.NoError(t, err)
+	_, err = AcquireIntelligenceLock(ctx, "i1")
+	assert.ErrorIs(t, err, ErrIntelligenceLocked)
+
+	// 其他情报不受影响
+	other, err := AcquireIntelligenceLock(ctx, "i2")
+	assert.NoError(t, err)
+	other.Release(ctx)
+
+	// 处理结束后释放
+	run.Finish(ctx, nil)
+	lock, err := AcquireIntelligenceLock(ctx, "i1")
+	assert.NoError(t, err)
+
+	// 手动检测期间，同一情报的新版本消息也要等待
+	data.Version = "3"
+	_, err = AcquireIntelligenceRun(ctx, data)
+	assert.ErrorIs(t, err, ErrIntelligenceRunInProgress)
+
+	lock.Release(ctx)
+	assert.False(t, mr.Exists(IntelligenceLockKeyPrefix+"i1"))
+	run, err = AcquireIntelligenceRun(ctx, data)
+	assert.NoError(t, err)
+	run.Finish(ctx, nil)
+}
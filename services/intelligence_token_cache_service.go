@@ -8,6 +8,7 @@ import (
 	"back_ai_gun_data/pkg/model/dto_cache"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -19,8 +20,7 @@ const (
 	CacheExpiration = 4 * 24 * time.Hour
 )
 
-func getIntelligenceCoinCache(intelligenceID string) ([]dto_cache.IntelligenceToken, error) {
-	ctx := context.Background()
+func getIntelligenceCoinCache(ctx context.Context, intelligenceID string) ([]dto_cache.IntelligenceToken, error) {
 	cacheKey := IntelligenceCoinCacheKeyPrefix + intelligenceID
 
 	cacheData, err := cache.Get(ctx, cacheKey)
@@ -37,27 +37,29 @@ func getIntelligenceCoinCache(intelligenceID string) ([]dto_cache.IntelligenceTo
 	return data, nil
 }
 
-func GetIntelligenceCoins(intelligenceID string) ([]dto_cache.IntelligenceToken, error) {
-	data, err := getIntelligenceCoinCache(intelligenceID)
+func GetIntelligenceCoins(ctx context.Context, intelligenceID string) ([]dto_cache.IntelligenceToken, error) {
+	data, err := getIntelligenceCoinCache(ctx, intelligenceID)
 	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
-func SyncShowedTokensToIntelligence(intelligenceID string) error {
-	cacheTokens, err := GetIntelligenceCoins(intelligenceID)
+func SyncShowedTokensToIntelligence(ctx context.Context, intelligenceID string) error {
+	cacheTokens, err := GetIntelligenceCoins(ctx, intelligenceID)
 	if err != nil {
 		lr.E().Errorf("Failed to get intelligence coins for sync: %v", err)
 		return err
 	}
 
-	showedTokens := make([]dto.ShowedToken, 0, len(cacheTokens))
-	for _, ct := range cacheTokens {
+	// 跑路盘不展示
+	publishable := PublishableTokens(cacheTokens)
+	showedTokens := make([]dto.ShowedToken, 0, len(publishable))
+	for _, ct := range publishable {
 		showedTokens = append(showedTokens, ct.ToShowedToken())
 	}
 
-	if err := dao.UpdateIntelligenceShowedTokens(intelligenceID, showedTokens); err != nil {
+	if err := dao.UpdateIntelligenceShowedTokens(ctx, intelligenceID, showedTokens); err != nil {
 		lr.E().Errorf("Failed to update intelligence showed tokens: %v", err)
 		return err
 	}
@@ -78,7 +80,29 @@ func ProcessIntelligenceData(ctx context.Context, data *model.IntelligenceMessag
 	// 3. 更新数据库
 	// 4. 缓存结果
 
-	err := ProcessMessageData(ctx, data)
+	decision := AdmitIntelligence(data)
+	if decision.Action == AdmissionPurge {
+		return PurgeIntelligence(ctx, data.ID)
+	}
+
+	// 同一情报重复投递时跳过已完成的处理，中断的处理从检查点继续
+	run, err := AcquireIntelligenceRun(ctx, data)
+	switch {
+	case errors.Is(err, ErrIntelligenceRunDone):
+		lr.I().Infof("Intelligence %s version %s already processed, skip", data.ID, data.Version)
+		return nil
+	case errors.Is(err, ErrIntelligenceRunInProgress):
+		// 不能直接确认：处理中的消费者崩溃后，broker 可能在租约过期前就重新投递，确认后这条消息就丢了
+		// 返回错误由 consumer 在租约过期后重新发送，届时接管或确认已完成
+		lr.I().Infof("Intelligence %s version %s is being processed by another consumer, retry later", data.ID, data.Version)
+		return err
+	case err != nil:
+		// Redis 不可用时不做幂等控制，宁可重复处理也不丢消息
+		lr.E().Errorf("Failed to acquire intelligence run %s: %v", data.ID, err)
+	}
+
+	err = ProcessMessageData(ctx, data, decision, run)
+	run.Finish(ctx, err)
 	if err != nil {
 		lr.E().Error(err)
 		return err
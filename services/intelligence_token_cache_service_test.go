@@ -4,6 +4,7 @@ import (
 	"back_ai_gun_data/pkg/cache"
 	"back_ai_gun_data/pkg/dao"
 	"back_ai_gun_data/pkg/lr"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,6 +16,6 @@ func TestSyncShowedTokensToIntelligence(t *testing.T) {
 	cache.Init()
 	intelligenceID := "019902fa-3cc7-71af-ad42-2c57caa4c25c"
 
-	err := SyncShowedTokensToIntelligence(intelligenceID)
+	err := SyncShowedTokensToIntelligence(context.Background(), intelligenceID)
 	assert.NoError(t, err)
 }
@@ -1,13 +1,13 @@
 package services
 
 import (
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
 	"back_ai_gun_data/services/remote_service"
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -22,100 +22,91 @@ func UpdateMarketData(ctx context.Context, intelligenceID string) error {
 		return nil
 	}
 
-	// 批量获取GMGN数据
-	updatedCount := 0
-
-	// 收集所有需要查询的币种信息
-	var queryParams []struct {
-		index int
-		token dto_cache.IntelligenceToken
-	}
-
-	for i, token := range cacheData {
-		if token.Name != "" {
-			queryParams = append(queryParams, struct {
-				index int
-				token dto_cache.IntelligenceToken
-			}{i, token})
+	// 收集所有需要查询的币种（链 + 合约地址）
+	refs := make([]remote.TokenRef, 0, len(cacheData))
+	for _, token := range cacheData {
+		if token.ContractAddress == "" || token.Chain.Slug == "" {
+			continue
 		}
+		refs = append(refs, remote.TokenRef{
+			Chain:    token.Chain.Slug,
+			Address:  token.ContractAddress,
+			RefPrice: token.Stats.CurrentPriceUSD,
+		})
 	}
 
-	if len(queryParams) == 0 {
+	if len(refs) == 0 {
 		lr.E().Errorf("No valid cacheTokens to query for intelligence %s", intelligenceID)
 		return nil
 	}
 
-	// 收集所有币的名称
-	var coinNames []string
-	for _, param := range queryParams {
-		coinNames = append(coinNames, param.token.Name)
+	// 批量查询行情，价格源内部按链回退
+	quotes, err := remote_service.GetPriceProvider().GetPrices(ctx, refs)
+	if err != nil {
+		lr.E().Errorf("Failed to batch query prices: %v", err)
+		return fmt.Errorf("failed to batch query prices: %w", err)
 	}
 
-	// 批量查询所有币
-	namesStr := strings.Join(coinNames, ",")
-	limit := len(coinNames) * 2
-	if limit < 10 {
-		limit = 10 // 最少10个
-	}
-	// 确保 limit 不超过 API 限制 100
-	if limit > 100 {
-		limit = 100
-	}
+	// 更新每个币的市场信息，并比较刷新前后的行情检测跑路盘
+	detector := defaultRugDetector()
+	ruggedPrev := make(map[string]dto_cache.CoinMarketStats)
+	recovered := 0
+	graduated := make(map[string]struct{})
+	updatedCount := 0
+	for i := range cacheData {
+		quote, ok := quotes[remote.PriceKey(cacheData[i].Chain.Slug, cacheData[i].ContractAddress)]
+		if !ok {
+			continue
+		}
+		prev := cacheData[i].Stats
+		applyQuoteToToken(&cacheData[i], quote)
+		enqueueTokenMarketData(&cacheData[i])
+		updatedCount++
 
-	// 直接调用上游接口，不指定链
-	remoteTokens, err := remote_service.QueryTokensByNameWithLimit(ctx, namesStr, "", limit)
-	if err != nil {
-		lr.E().Errorf("Failed to batch query GMGN: %v", err)
-		return fmt.Errorf("failed to batch query GMGN: %w", err)
-	}
-
-	// 更新每个币的市场信息
-	for _, param := range queryParams {
-		cacheTokenIns := param.token
-		index := param.index
-
-		// 使用提取的匹配方法
-		matchedToken := cacheTokenIns.FindMatchingToken(remoteTokens)
-
-		// 更新市场信息
-		if matchedToken != nil {
-			cacheData[index].Stats.CurrentPriceUSD = matchedToken.PriceUSD
-			cacheData[index].Stats.CurrentMarketCap = matchedToken.MarketCap
-			cacheData[index].UpdatedAt.Time = time.Now()
-
-			// 计算预警涨幅：当前市值 ÷ 预警市值
-			if matchedToken.MarketCap != "0" && cacheTokenIns.Stats.WarningMarketCap != "0" {
-				currentMarketCap, err1 := strconv.ParseFloat(matchedToken.MarketCap, 64)
-				warningMarketCap, err2 := strconv.ParseFloat(cacheTokenIns.Stats.WarningMarketCap, 64)
-
-				if err1 == nil && err2 == nil && warningMarketCap > 0 {
-					currentIncreaseRate := currentMarketCap / warningMarketCap
-
-					// 获取历史最高涨幅
-					highestIncreaseRate, err3 := strconv.ParseFloat(cacheTokenIns.Stats.HighestIncreaseRate, 64)
-					if err3 != nil {
-						highestIncreaseRate = 0
-					}
-
-					// 更新最高涨幅（取较大值）
-					if currentIncreaseRate > highestIncreaseRate {
-						cacheData[index].Stats.HighestIncreaseRate = fmt.Sprintf("%.6f", currentIncreaseRate)
-					}
-				}
-			}
-
-			updatedCount++
-		} else {
-			//lr.E().Errorf("No GMGN data found for token: %s", cacheTokenIns.Name)
+		if applyLaunchpadState(&cacheData[i], quote) {
+			graduated[cacheData[i].GetUniqueKey()] = struct{}{}
+		}
+
+		if clearFrozenRug(&cacheData[i]) {
+			recovered++
+			lr.I().Infof("Token %s of intelligence %s price moved again, clear frozen rug mark", cacheData[i].GetUniqueKey(), intelligenceID)
 		}
+		if markRugged(&cacheData[i], detector.Detect(prev, cacheData[i].Stats)) {
+			ruggedPrev[cacheData[i].GetUniqueKey()] = prev
+		}
+	}
+	if len(ruggedPrev) > 0 || recovered > 0 {
+		DemoteRuggedTokens(cacheData)
 	}
 
+	lr.I().Infof("Updated market data for %d/%d tokens of intelligence %s", updatedCount, len(cacheData), intelligenceID)
+
+	// 重试使用当前价格兜底的预警快照
+	ensureWarningSnapshots(ctx, intelligenceID, time.Time{}, cacheData)
+
 	// 将更新后的数据写回缓存
 	if err := writeTokenCache(ctx, intelligenceID, cacheData); err != nil {
 		lr.E().Errorf("Failed to write intelligence token cache: %v", err)
 		return fmt.Errorf("failed to write intelligence token cache: %w", err)
 	}
 
+	// 评估涨跌幅预警
+	EvaluateAlerts(ctx, intelligenceID, cacheData)
+
+	if len(graduated) > 0 {
+		publishGraduationEvents(ctx, intelligenceID, cacheData, graduated)
+	}
+
+	// 有新的跑路盘时通知下游，跑路盘有变化时同步showed_tokens
+	if len(ruggedPrev) > 0 {
+		publishRugEvents(ctx, intelligenceID, cacheData, ruggedPrev)
+	}
+	if len(ruggedPrev) > 0 || recovered > 0 {
+		if err := SyncShowedTokensToIntelligence(ctx, intelligenceID); err != nil {
+			lr.E().Errorf("Failed to sync showed tokens to intelligence: %v", err)
+		}
+	}
+
 	// 同步showed_tokens到intelligence表
 	//if err := SyncShowedTokensToIntelligence(intelligenceID); err != nil {
 	//	lr.E().Errorf("Failed to sync showed tokens to intelligence: %v", err)
@@ -125,6 +116,35 @@ func UpdateMarketData(ctx context.Context, intelligenceID string) error {
 	return nil
 }
 
+// applyQuoteToToken 将行情写入缓存币，并更新历史最高涨幅
+func applyQuoteToToken(token *dto_cache.IntelligenceToken, quote remote.TokenQuote) {
+	if token.Stats.CurrentPriceUSD.IsValid() && token.Stats.CurrentPriceUSD.Equal(quote.PriceUSD) {
+		token.Stats.UnchangedRounds++
+	} else {
+		token.Stats.UnchangedRounds = 0
+	}
+
+	token.Stats.CurrentPriceUSD = quote.PriceUSD
+	token.Stats.CurrentMarketCap = quote.MarketCap
+	token.Stats.Source = quote.Source
+	token.Stats.QuotedAt = dto_cache.CustomTime{Time: quote.Timestamp}
+	// CoinGecko / CMC 不提供流动性，保留上次的值
+	if quote.Liquidity.IsValid() {
+		token.Stats.Liquidity = quote.Liquidity
+	}
+	if quote.Volume24h.IsValid() {
+		token.Stats.Volume24h = quote.Volume24h
+	}
+	token.UpdatedAt.Time = time.Now()
+
+	// 计算预警涨幅：当前市值 ÷ 预警市值，更新最高涨幅（取较大值）
+	currentIncreaseRate, ok := quote.MarketCap.Ratio(token.Stats.WarningMarketCap)
+	if !ok || !quote.MarketCap.IsPositive() {
+		return
+	}
+	token.Stats.HighestIncreaseRate = decimal.Max(token.Stats.HighestIncreaseRate, currentIncreaseRate)
+}
+
 func TriggerMarketDataUpdate(ctx context.Context, intelligenceID string) (err error) {
 	if err := UpdateMarketData(ctx, intelligenceID); err != nil {
 		lr.E().Errorf("Failed to update market data for intelligence %s: %v", intelligenceID, err)
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/utils"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MarketDataUpdate 待回写 project_chain_data 的行情
+type MarketDataUpdate struct {
+	ChainID         string // 链ID，为空时按 ChainSlug 查询
+	ChainSlug       string
+	ContractAddress string
+	PriceUSD        decimal.Decimal
+	MarketCap       decimal.Decimal
+	Volume24h       decimal.Decimal
+	QuotedAt        time.Time
+}
+
+// MarketDataSink 行情回写缓冲
+// 同一 (链, 合约地址) 只保留行情时间最新的一条，定时分批写入数据库
+type MarketDataSink struct {
+	mu        sync.Mutex
+	pending   map[string]MarketDataUpdate
+	batchSize int
+}
+
+func NewMarketDataSink(batchSize int) *MarketDataSink {
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	return &MarketDataSink{
+		pending:   make(map[string]MarketDataUpdate),
+		batchSize: batchSize,
+	}
+}
+
+var marketDataSink = NewMarketDataSink(consts.MARKET_SINK_BATCH_SIZE)
+
+// marketDataKey 合并key，只有EVM地址忽略大小写，solana等base58地址区分大小写
+func marketDataKey(chainSlug, contractAddress string) string {
+	if utils.IsEVMAddress(contractAddress) {
+		contractAddress = strings.ToLower(contractAddress)
+	}
+	return strings.ToLower(chainSlug) + ":" + contractAddress
+}
+
+// Add 加入待回写队列，已有更新的行情时忽略
+func (s *MarketDataSink) Add(update MarketDataUpdate) {
+	if update.ContractAddress == "" || (update.ChainID == "" && update.ChainSlug == "") || !update.PriceUSD.IsPositive() {
+		return
+	}
+	if update.QuotedAt.IsZero() {
+		update.QuotedAt = time.Now()
+	}
+
+	key := marketDataKey(update.ChainSlug, update.ContractAddress)
+	if update.ChainSlug == "" {
+		key = marketDataKey(update.ChainID, update.ContractAddress)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.pending[key]; ok {
+		if existing.QuotedAt.After(update.QuotedAt) {
+			return
+		}
+		if update.ChainID == "" {
+			update.ChainID = existing.ChainID
+		}
+	}
+	s.pending[key] = update
+}
+
+// Len 待回写的条数
+func (s *MarketDataSink) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// take 取出最多 n 条待回写的行情
+func (s *MarketDataSink) take(n int) []MarketDataUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updates := make([]MarketDataUpdate, 0, n)
+	for key, update := range s.pending {
+		if len(updates) >= n {
+			break
+		}
+		updates = append(updates, update)
+		delete(s.pending, key)
+	}
+	return updates
+}
+
+// requeue 写入失败后放回队列，期间有更新的行情时以新行情为准
+func (s *MarketDataSink) requeue(updates []MarketDataUpdate) {
+	for _, update := range updates {
+		s.Add(update)
+	}
+}
+
+// Flush 分批写入全部待回写的行情
+func (s *MarketDataSink) Flush(ctx context.Context) error {
+	var total int64
+	for {
+		batch := s.take(s.batchSize)
+		if len(batch) == 0 {
+			break
+		}
+
+		affected, err := s.write(ctx, batch)
+		if err != nil {
+			s.requeue(batch)
+			return err
+		}
+		total += affected
+	}
+
+	if total > 0 {
+		lr.I().Infof("Flushed market data for %d project chain data records", total)
+	}
+	return nil
+}
+
+func (s *MarketDataSink) write(ctx context.Context, batch []MarketDataUpdate) (int64, error) {
+	// 补全缺少链ID的记录（外部API返回的币只有链slug）
+	var slugs []string
+	for _, update := range batch {
+		if update.ChainID == "" {
+			slugs = append(slugs, update.ChainSlug)
+		}
+	}
+	chains, err := dao.GetChainsBySlugs(ctx, slugs)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([]dao.MarketAddressUpdate, 0, len(batch))
+	for _, update := range batch {
+		chainID := update.ChainID
+		if chainID == "" {
+			chain, ok := chains[strings.ToLower(update.ChainSlug)]
+			if !ok {
+				continue
+			}
+			chainID = chain.ID
+		}
+		rows = append(rows, dao.MarketAddressUpdate{
+			ChainID:         chainID,
+			ContractAddress: update.ContractAddress,
+			PriceUSD:        update.PriceUSD.Float64Ptr(),
+			MarketCap:       update.MarketCap.Float64Ptr(),
+			Volume24h:       update.Volume24h.Float64Ptr(),
+			MarketUpdatedAt: update.QuotedAt,
+		})
+	}
+
+	return dao.BatchUpdateProjectChainDataMarketByAddress(ctx, rows)
+}
+
+// StartMarketDataSink 启动定时回写，ctx 取消后退出，退出前由 FlushMarketDataSink 写入剩余数据
+func StartMarketDataSink(ctx context.Context) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				lr.E().Errorf("Panic in market data sink: %v", r)
+			}
+		}()
+
+		ticker := time.NewTicker(consts.MARKET_SINK_FLUSH_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := marketDataSink.Flush(ctx); err != nil {
+					lr.E().Errorf("Failed to flush market data: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// FlushMarketDataSink 立即写入全部待回写的行情，用于退出前
+func FlushMarketDataSink(ctx context.Context) error {
+	return marketDataSink.Flush(ctx)
+}
+
+// enqueueTokenMarketData 将缓存币的当前行情加入回写队列
+func enqueueTokenMarketData(token *dto_cache.IntelligenceToken) {
+	marketDataSink.Add(MarketDataUpdate{
+		ChainID:         token.Chain.ID,
+		ChainSlug:       token.Chain.Slug,
+		ContractAddress: token.ContractAddress,
+		PriceUSD:        token.Stats.CurrentPriceUSD,
+		MarketCap:       token.Stats.CurrentMarketCap,
+		Volume24h:       token.Stats.Volume24h,
+		QuotedAt:        token.Stats.QuotedAt.Time,
+	})
+}
+
+// enqueueGmGnMarketData 将检测轮次中搜索到的币行情加入回写队列
+func enqueueGmGnMarketData(tokens []remote.GmGnToken) {
+	now := time.Now()
+	for _, token := range tokens {
+		marketDataSink.Add(MarketDataUpdate{
+			ChainSlug:       token.Network,
+			ContractAddress: token.Address,
+			PriceUSD:        token.PriceUSD,
+			MarketCap:       token.MarketCap,
+			Volume24h:       token.Volume24h,
+			QuotedAt:        now,
+		})
+	}
+}
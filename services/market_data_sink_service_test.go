@@ -0,0 +1,57 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarketDataSinkCoalesce(t *testing.T) {
+	sink := NewMarketDataSink(10)
+	now := time.Now()
+
+	sink.Add(MarketDataUpdate{ChainID: "chain-1", ChainSlug: "solana", ContractAddress: "AAA", PriceUSD: decimal.RequireFromString("1"), QuotedAt: now})
+	// 更早的行情不覆盖
+	sink.Add(MarketDataUpdate{ChainSlug: "solana", ContractAddress: "AAA", PriceUSD: decimal.RequireFromString("0.5"), QuotedAt: now.Add(-time.Second)})
+	sink.Add(MarketDataUpdate{ChainSlug: "Solana", ContractAddress: "AAA", PriceUSD: decimal.RequireFromString("2"), QuotedAt: now.Add(time.Second)})
+	// solana 地址区分大小写，不合并
+	sink.Add(MarketDataUpdate{ChainSlug: "solana", ContractAddress: "aaa", PriceUSD: decimal.RequireFromString("4"), QuotedAt: now})
+	// EVM 地址忽略大小写合并
+	sink.Add(MarketDataUpdate{ChainSlug: "bsc", ContractAddress: "0x00000000000000000000000000000000000000Bb", PriceUSD: decimal.RequireFromString("3"), QuotedAt: now})
+	sink.Add(MarketDataUpdate{ChainSlug: "bsc", ContractAddress: "0x00000000000000000000000000000000000000bb", PriceUSD: decimal.RequireFromString("5"), QuotedAt: now.Add(time.Second)})
+	// 无效数据忽略
+	sink.Add(MarketDataUpdate{ChainSlug: "bsc", ContractAddress: "", PriceUSD: decimal.RequireFromString("3")})
+	sink.Add(MarketDataUpdate{ChainSlug: "bsc", ContractAddress: "0xccc", PriceUSD: decimal.Zero})
+
+	assert.Equal(t, 3, sink.Len())
+
+	updates := sink.take(10)
+	assert.Len(t, updates, 3)
+	assert.Equal(t, 0, sink.Len())
+
+	for _, update := range updates {
+		switch update.ContractAddress {
+		case "AAA":
+			assert.Equal(t, "2", update.PriceUSD.String())
+			// 合并时保留已知的链ID
+			assert.Equal(t, "chain-1", update.ChainID)
+		case "aaa":
+			assert.Equal(t, "4", update.PriceUSD.String())
+		default:
+			assert.Equal(t, "bsc", update.ChainSlug)
+			assert.Equal(t, "5", update.PriceUSD.String())
+		}
+	}
+}
+
+func TestMarketDataSinkTakeBatch(t *testing.T) {
+	sink := NewMarketDataSink(2)
+	for _, address := range []string{"a", "b", "c"} {
+		sink.Add(MarketDataUpdate{ChainSlug: "solana", ContractAddress: address, PriceUSD: decimal.RequireFromString("1")})
+	}
+
+	assert.Len(t, sink.take(2), 2)
+	assert.Equal(t, 1, sink.Len())
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/metrics"
 	"back_ai_gun_data/pkg/model"
 	"back_ai_gun_data/pkg/model/dto"
 	"back_ai_gun_data/pkg/model/dto_cache"
@@ -10,6 +12,7 @@ import (
 	"back_ai_gun_data/producer"
 	"back_ai_gun_data/services/remote_service"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -18,20 +21,32 @@ import (
 
 var top3 = 3
 
+var (
+	detectionRounds = metrics.NewCounterVec("detection_rounds_total", "Detection rounds by result.", "result")
+	newTokensFound  = metrics.NewHistogram("detection_new_tokens", "New tokens found per successful search round.",
+		[]float64{0, 1, 2, 3, 5, 10, 20, 50})
+	top3Changes = metrics.NewCounter("detection_top3_changes_total", "Rounds where a new token entered the top 3.")
+)
+
 const (
-	detectionInterval = 30 * time.Second // 30秒检测间隔
-	maxDetections     = 10               // 最多检测10次
+	detectionInterval    = 30 * time.Second // 30秒检测间隔
+	maxDetections        = 10               // 最多检测10次
+	newTokensTaskTimeout = time.Minute      // 新币入库和通知的超时时间
 )
 
-func ProcessMessageData(ctx context.Context, data *model.IntelligenceMessage) error {
+// ProcessMessageData 处理情报：刷新行情后按准入结果多轮检测新币，run 为空时不记录检查点
+func ProcessMessageData(ctx context.Context, data *model.IntelligenceMessage, decision AdmissionDecision, run *IntelligenceRun) error {
 	entities := analyzeEntities(data)
 
-	err := UpdateMarketData(ctx, data.ID)
-	if err != nil {
-		lr.E().Error(err)
+	// 从检查点继续时行情已刷新过
+	if run == nil || run.Rounds == 0 {
+		err := UpdateMarketData(ctx, data.ID)
+		if err != nil {
+			lr.E().Error(err)
+		}
 	}
 
-	if err := processRankingAndHotData(ctx, data, entities); err != nil {
+	if err := processRankingAndHotData(ctx, data, entities, decision, run); err != nil {
 		lr.E().Error(err)
 		return err
 	}
@@ -39,17 +54,84 @@ func ProcessMessageData(ctx context.Context, data *model.IntelligenceMessage) er
 	return nil
 }
 
-func processRankingAndHotData(ctx context.Context, data *model.IntelligenceMessage, entities map[string]interface{}) error {
+func processRankingAndHotData(ctx context.Context, data *model.IntelligenceMessage, entities map[string]interface{}, decision AdmissionDecision, run *IntelligenceRun) error {
 	//time.Sleep(detectionInterval)
 
+	searchNames, cacheTokens, err := prepareDetection(ctx, data)
+	if err != nil {
+		return err
+	}
+	if len(cacheTokens) == 0 {
+		return nil
+	}
+
+	startRound := 0
+	if run != nil {
+		startRound = run.Rounds
+		if startRound > 0 {
+			lr.I().Infof("Resume detection for intelligence %s from round %d", data.ID, startRound)
+		}
+	}
+	advanceJob, finishJob := startDetectionJob(data.ID, decision, startRound)
+	defer finishJob()
+
+	for detectionCount := startRound; detectionCount < decision.MaxDetections; {
+
+		if err := ctx.Err(); err != nil {
+			lr.I().Infof("Context done, stopping detection for intelligence %s: %v", data.ID, err)
+			return err
+		}
+
+		if err := executeDetectionAndProcessing(ctx, data.ID, searchNames, cacheTokens); err != nil {
+			lr.E().Errorf("Detection %d failed: %v", detectionCount+1, err)
+			// 继续下一次检测，不中断流程
+		}
+		// 记录已完成的轮数，只在这里递增
+		detectionCount++
+		advanceJob(detectionCount)
+		if err := run.Checkpoint(ctx, detectionCount); err != nil {
+			if errors.Is(err, ErrIntelligenceRunSuperseded) {
+				lr.I().Infof("Intelligence %s run superseded, stopping detection", data.ID)
+				return nil
+			}
+			lr.E().Errorf("Failed to checkpoint intelligence %s: %v", data.ID, err)
+		}
+		// 如果不是最后一次检测，等待下次检测；退出或超时时立即结束
+		if detectionCount < decision.MaxDetections {
+			select {
+			case <-ctx.Done():
+				lr.I().Infof("Context done, stopping detection for intelligence %s: %v", data.ID, ctx.Err())
+				return ctx.Err()
+			case <-time.After(decision.Interval):
+			}
+		}
+	}
+	return nil
+}
+
+// RunDetectionRound 立即对情报做一轮新币检测和排序，用于手动触发
+func RunDetectionRound(ctx context.Context, intelligenceID string) error {
+	searchNames, cacheTokens, err := prepareDetection(ctx, &model.IntelligenceMessage{BaseMessage: model.BaseMessage{ID: intelligenceID}})
+	if err != nil {
+		return err
+	}
+	if len(cacheTokens) == 0 {
+		return nil
+	}
+	return executeDetectionAndProcessing(ctx, intelligenceID, searchNames, cacheTokens)
+}
+
+// prepareDetection 读取情报的币缓存，补充数据库中同名或同地址的币，返回检测用的搜索名称和币列表
+// 缓存为空时返回空列表，不做检测
+func prepareDetection(ctx context.Context, data *model.IntelligenceMessage) ([]string, []dto_cache.IntelligenceToken, error) {
 	cacheTokens, err := ReadTokenCache(ctx, data.ID)
 	if err != nil {
 		lr.E().Error(err)
-		return err
+		return nil, nil, err
 	}
 	if len(cacheTokens) == 0 {
 		lr.I().Infof("No cacheTokens found in cache for intelligence %s", data.ID)
-		return nil
+		return nil, nil, nil
 	}
 
 	searchNames := make([]string, 0, len(cacheTokens))
@@ -63,35 +145,17 @@ func processRankingAndHotData(ctx context.Context, data *model.IntelligenceMessa
 		}
 	}
 
-	dtoTokens, err := dao.GetProjectChainDataByNamesAndAddresses(searchNames, searchAddresses)
+	dtoTokens, err := dao.GetProjectChainDataByNamesAndAddresses(ctx, searchNames, searchAddresses)
 	if err != nil {
 		lr.E().Error(err)
-		return err
+		return nil, nil, err
 	}
 
-	convertedTokens := convertProjectChainDataToCacheTokens(dtoTokens)
+	convertedTokens := convertProjectChainDataToCacheTokens(ctx, dtoTokens)
 	convertedTokens = deduplicateTokensAgainstExisting(convertedTokens, cacheTokens)
-	cacheTokens = append(cacheTokens, convertedTokens...)
-
-	for detectionCount := 0; detectionCount < maxDetections; detectionCount++ {
-
-		select {
-		case <-ctx.Done():
-			lr.I().Infof("Context cancelled, stopping detection for intelligence %s", data.ID)
-			return nil
-		default:
-			if err := executeDetectionAndProcessing(ctx, data.ID, searchNames, cacheTokens); err != nil {
-				lr.E().Errorf("Detection %d failed: %v", detectionCount+1, err)
-				// 继续下一次检测，不中断流程
-			}
-			detectionCount++
-			// 如果不是最后一次检测，等待下次检测
-			if detectionCount < maxDetections {
-				time.Sleep(detectionInterval)
-			}
-		}
-	}
-	return nil
+	// 数据库中的价格是入库时的价格，按情报发布时间修正预警价格
+	ensureWarningSnapshots(ctx, data.ID, resolvePublishedAt(ctx, data), convertedTokens)
+	return searchNames, append(cacheTokens, convertedTokens...), nil
 }
 
 func executeDetectionAndProcessing(ctx context.Context, intelligenceID string, searchNames []string, cacheTokens []dto_cache.IntelligenceToken) error {
@@ -108,11 +172,15 @@ func executeDetectionAndProcessing(ctx context.Context, intelligenceID string, s
 		remoteTokens, qErr := queryTokensByName(ctx, searchNames)
 		if qErr == nil {
 			searchResultsByName := make(map[string][]remote.GmGnToken)
+			supportedTokens := make([]remote.GmGnToken, 0, len(remoteTokens))
 			for _, t := range remoteTokens {
 				if t.IsSupportedChain() {
 					searchResultsByName[t.Name] = append(searchResultsByName[t.Name], t)
+					supportedTokens = append(supportedTokens, t)
 				}
 			}
+			// 搜索结果中的行情回写 project_chain_data
+			enqueueGmGnMarketData(supportedTokens)
 
 			for _, tokens := range searchResultsByName {
 				for _, token := range tokens {
@@ -123,46 +191,62 @@ func executeDetectionAndProcessing(ctx context.Context, intelligenceID string, s
 				}
 			}
 
+			newTokensFound.Observe(float64(len(newTokens)))
 			if len(newTokens) > 0 {
+				// 消息处理结束后 ctx 会被取消，异步任务使用独立的超时
+				asyncCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), newTokensTaskTimeout)
 				go func() {
+					defer cancel()
 					defer func() {
 						if r := recover(); r != nil {
 							lr.E().Errorf("Panic in checkAndSendTokens: %v", r)
 						}
 					}()
 
-					checkAndSendTokens(ctx, newTokens)
+					checkAndSendTokens(asyncCtx, newTokens)
 				}()
 			}
 		} else {
+			detectionRounds.WithLabelValues("error").Inc()
 			lr.E().Error(qErr)
 			return qErr
 		}
 	}
 
-	rankedTokens, err := remote_service.CallAdminRankingWithGmGnTokens(intelligenceID, oldTokens, newTokens)
+	rankedTokens, err := remote_service.CallAdminRankingWithGmGnTokens(ctx, intelligenceID, oldTokens, newTokens)
 	if err != nil {
+		detectionRounds.WithLabelValues("error").Inc()
 		lr.E().Error(err)
 		return err
 	}
+	detectionRounds.WithLabelValues("ok").Inc()
 
-	hasNewTokenInTop3 := false
-	if len(rankedTokens) < top3 {
-		top3 = len(rankedTokens)
-	}
 	oldTokenKeys := make(map[string]dto_cache.IntelligenceToken, len(cacheTokens))
 	for _, t := range cacheTokens {
 		oldTokenKeys[t.GetUniqueKey()] = t
 	}
-	for i := 0; i < top3; i++ {
-		if _, exists := oldTokenKeys[rankedTokens[i].GetUniqueKey()]; !exists {
+	// 跑路盘不展示，不计入前三
+	hasNewTokenInTop3 := false
+	shown := 0
+	for _, token := range rankedTokens {
+		if shown >= top3 {
+			break
+		}
+		oldToken, exists := oldTokenKeys[token.GetUniqueKey()]
+		if exists && oldToken.IsRugged {
+			continue
+		}
+		shown++
+		if !exists {
 			hasNewTokenInTop3 = true
 			break
 		}
 	}
 
 	if hasNewTokenInTop3 {
+		top3Changes.Inc()
 		finalCache := make([]dto_cache.IntelligenceToken, 0, len(rankedTokens))
+		shownInCache := 0
 
 		// 遍历排序后的结果，按顺序添加，并更新更新时间
 		for _, token := range rankedTokens {
@@ -170,22 +254,34 @@ func executeDetectionAndProcessing(ctx context.Context, intelligenceID string, s
 			updatedToken := token
 			updatedToken.UpdatedAt = dto_cache.CustomTime{Time: time.Now()}
 
-			if _, exists := oldTokenKeys[token.GetUniqueKey()]; exists {
+			if oldToken, exists := oldTokenKeys[token.GetUniqueKey()]; exists {
+				// 恢复排序服务不回传的本地状态（行情来源、跑路盘标记等）
+				updatedToken.MergeLocalState(&oldToken)
 				finalCache = append(finalCache, updatedToken)
+				if !updatedToken.IsRugged {
+					shownInCache++
+				}
 			} else {
-				if len(finalCache) < top3 {
+				if shownInCache < top3 {
 					finalCache = append(finalCache, updatedToken)
+					shownInCache++
 				}
 			}
 		}
 
+		// 新进入缓存的币记录发布时刻的预警价格
+		ensureWarningSnapshots(ctx, intelligenceID, time.Time{}, finalCache)
+		DemoteRuggedTokens(finalCache)
+
 		if err := writeTokenCache(ctx, intelligenceID, finalCache); err != nil {
 			lr.E().Error(err)
 			// 缓存写入失败不影响后续流程，继续处理热点数据
+		} else {
+			recordRankingHistory(ctx, intelligenceID, finalCache)
 		}
 	}
 
-	if err := SyncShowedTokensToIntelligence(intelligenceID); err != nil {
+	if err := SyncShowedTokensToIntelligence(ctx, intelligenceID); err != nil {
 		lr.E().Error(err)
 	}
 
@@ -244,7 +340,12 @@ func startTokenDetectionTask(ctx context.Context, intelligenceID string, searchN
 			}
 
 			// 等待下次检测
-			time.Sleep(detectionInterval)
+			select {
+			case <-ctx.Done():
+				lr.I().Infof("Context cancelled, stopping token detection for intelligence %s", intelligenceID)
+				return
+			case <-time.After(detectionInterval):
+			}
 		}
 	}
 
@@ -345,9 +446,9 @@ func analyzeEntities(data *model.IntelligenceMessage) map[string]interface{} {
 	return result
 }
 
-func createOrGetEntityFromETL(tokenName string) (*dto.Entity, error) {
+func createOrGetEntityFromETL(ctx context.Context, tokenName string) (*dto.Entity, error) {
 	// 检查是否已存在实体
-	existingEntity, err := dao.GetEntityBySlugAndType(tokenName, "token")
+	existingEntity, err := dao.GetEntityBySlugAndType(ctx, tokenName, "token")
 	if err != nil {
 		lr.E().Error(err)
 		return nil, err
@@ -373,14 +474,14 @@ func createOrGetEntityFromETL(tokenName string) (*dto.Entity, error) {
 }
 
 // createIntelligenceEntityRelation 创建情报实体关联
-func createIntelligenceEntityRelation(intelligenceID, entityID string) error {
+func createIntelligenceEntityRelation(ctx context.Context, intelligenceID, entityID string) error {
 	relation := &dto.EntityIntelligence{
 		IntelligenceID: intelligenceID,
 		EntityID:       entityID,
 		Type:           stringPtr("token"),
 	}
 
-	return dao.CreateEntityIntelligence(relation)
+	return dao.CreateEntityIntelligence(ctx, relation)
 }
 
 // triggerCoinDataSearch 触发币数据搜索
@@ -408,7 +509,7 @@ func getTokenNameSet(entities map[string]interface{}) map[string]bool {
 	return tokenNameSet
 }
 
-func convertProjectChainDataToCacheTokens(dtoTokens []*dto.ProjectChainData) []dto_cache.IntelligenceToken {
+func convertProjectChainDataToCacheTokens(ctx context.Context, dtoTokens []*dto.ProjectChainData) []dto_cache.IntelligenceToken {
 	if len(dtoTokens) == 0 {
 		return make([]dto_cache.IntelligenceToken, 0)
 	}
@@ -422,7 +523,7 @@ func convertProjectChainDataToCacheTokens(dtoTokens []*dto.ProjectChainData) []d
 	}
 
 	// 批量查询链信息
-	chainMap, err := dao.GetChainsByIDs(chainIDs)
+	chainMap, err := dao.GetChainsByIDs(ctx, chainIDs)
 	if err != nil {
 		lr.E().Errorf("Failed to get chains by IDs: %v", err)
 		// 如果查询失败，使用默认值继续处理
@@ -474,25 +575,21 @@ func convertProjectChainDataToCacheTokens(dtoTokens []*dto.ProjectChainData) []d
 			}
 		}
 
-		// 创建本地变量并设置默认值
-		var currentPriceUSD string
-		var currentMarketCap string
-
-		// 安全赋值，判断数据源是否为空
-		if dtoToken.Price24Hours != nil {
-			currentPriceUSD = fmt.Sprintf("%.8f", *dtoToken.Price24Hours)
-		}
-		if dtoToken.MarketCap24Hours != nil {
-			currentMarketCap = fmt.Sprintf("%.2f", *dtoToken.MarketCap24Hours)
-		}
+		// 数据源为空时为无值，不会被格式化截断
+		currentPriceUSD := decimal.NewFromFloatPtr(dtoToken.Price24Hours)
+		currentMarketCap := decimal.NewFromFloatPtr(dtoToken.MarketCap24Hours)
 
 		// 构建市场统计信息
 		stats := dto_cache.CoinMarketStats{
-			WarningPriceUSD:     currentPriceUSD,
-			WarningMarketCap:    currentMarketCap,
-			CurrentPriceUSD:     currentPriceUSD,
-			CurrentMarketCap:    currentMarketCap,
-			HighestIncreaseRate: "",
+			WarningPriceUSD:  currentPriceUSD,
+			WarningMarketCap: currentMarketCap,
+			CurrentPriceUSD:  currentPriceUSD,
+			CurrentMarketCap: currentMarketCap,
+			Volume24h:        decimal.NewFromFloatPtr(dtoToken.TradingVolume24Hours),
+		}
+		// 行情时间，用于判断种子价格的新鲜度
+		if dtoToken.MarketUpdatedAt != nil {
+			stats.QuotedAt = dto_cache.CustomTime{Time: *dtoToken.MarketUpdatedAt}
 		}
 
 		var symbol string
@@ -534,37 +631,6 @@ func convertProjectChainDataToCacheTokens(dtoTokens []*dto.ProjectChainData) []d
 	return cacheTokens
 }
 
-//func saveNewTokenToProjectChainData(ctx context.Context, token remote.GmGnToken) error {
-//	chainID, err := dao.GetChainIDBySlug(token.Network)
-//	if err != nil {
-//		lr.E().Error()
-//		return err
-//	}
-//	if chainID == "" {
-//		return fmt.Errorf("chain not found for network %s", token.Network)
-//	}
-//
-//	existingData, err := dao.GetProjectChainDataByChainIDAndContractAddress(chainID, token.Address)
-//	if err != nil {
-//		lr.E().Error()
-//		return err
-//	}
-//	if existingData != nil {
-//		//lr.I().Infof("Token already exists in project_chain_data: name=%s, address=%s, chain=%s",
-//		//	token.Name, token.Address, token.Network)
-//		return nil
-//	}
-//
-//	projectChainData := token.ToProjectChainData(chainID)
-//
-//	if err := dao.CreateProjectChainData(projectChainData); err != nil {
-//		lr.E().Error(err)
-//		return err
-//	}
-//
-//	return nil
-//}
-
 func deduplicateTokensAgainstExisting(tokens []dto_cache.IntelligenceToken, existingTokens []dto_cache.IntelligenceToken) []dto_cache.IntelligenceToken {
 	if len(tokens) == 0 {
 		return tokens
@@ -605,7 +671,7 @@ func deduplicateTokensAgainstExisting(tokens []dto_cache.IntelligenceToken, exis
 	return result
 }
 
-// checkAndSendTokens 异步检查关注状态并发送消息
+// checkAndSendTokens 异步检查关注状态、新币入库并发送消息
 func checkAndSendTokens(ctx context.Context, tokens []remote.GmGnToken) {
 	if len(tokens) == 0 {
 		return
@@ -624,13 +690,33 @@ func checkAndSendTokens(ctx context.Context, tokens []remote.GmGnToken) {
 	}
 
 	// 获取未关注的项目链数据（is_follow为false或不存在）
-	unfollowedTokens, err := dao.GetUnfollowedProjectChainData(searchNames, searchAddresses)
+	// 必须在入库前查询：新入库的币 is_follow 为空，入库后查询会把库里原本没有的币也当作未关注发送
+	unfollowedTokens, err := dao.GetUnfollowedProjectChainData(ctx, searchNames, searchAddresses)
 	if err != nil {
 		lr.E().Errorf("Failed to get unfollowed project chain data: %v", err)
 		return
 	}
+	tokensToSend := filterUnfollowedTokens(tokens, unfollowedTokens)
+
+	// 发送判定完成后再入库，入库失败不影响发送
+	if _, err := IngestNewTokens(ctx, tokens); err != nil {
+		lr.E().Error(err)
+	}
+
+	// 发送消息
+	// 已在独立的 goroutine 中，同步发送以记录发送和去重数量
+	if len(tokensToSend) > 0 {
+		result, err := producer.SendNewTokensMessage(ctx, tokensToSend)
+		if err != nil {
+			lr.E().Errorf("Failed to send new tokens message: %v", err)
+			return
+		}
+		lr.I().Infof("Sent %d, suppressed %d unfollowed tokens out of %d total new tokens", result.Sent, result.Suppressed, len(tokens))
+	}
+}
 
-	// 过滤出需要发送的币
+// filterUnfollowedTokens 过滤出地址在未关注列表中的币，库里没有记录的币不发送
+func filterUnfollowedTokens(tokens []remote.GmGnToken, unfollowedTokens []*dto.ProjectChainData) []remote.GmGnToken {
 	var tokensToSend []remote.GmGnToken
 	for _, token := range tokens {
 		// 检查是否在未关注列表中
@@ -646,10 +732,5 @@ func checkAndSendTokens(ctx context.Context, tokens []remote.GmGnToken) {
 			tokensToSend = append(tokensToSend, token)
 		}
 	}
-
-	// 发送消息
-	if len(tokensToSend) > 0 {
-		producer.SendNewTokensMessageAsync(ctx, tokensToSend)
-		lr.I().Infof("Sent %d unfollowed tokens out of %d total new tokens", len(tokensToSend), len(tokens))
-	}
+	return tokensToSend
 }
@@ -5,6 +5,8 @@ import (
 	"back_ai_gun_data/pkg/dao"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model"
+	"back_ai_gun_data/pkg/model/dto"
+	"back_ai_gun_data/pkg/model/remote"
 	"back_ai_gun_data/services/remote_service"
 	"context"
 	"testing"
@@ -28,6 +30,22 @@ func Test_processRankingAndHotData(t *testing.T) {
 	entities := analyzeEntities(data)
 	ctx := context.Background()
 
-	err := processRankingAndHotData(ctx, data, entities)
+	err := processRankingAndHotData(ctx, data, entities, normalAdmission("test"), nil)
 	assert.NoError(t, err)
 }
+
+func TestFilterUnfollowedTokens(t *testing.T) {
+	tokens := []remote.GmGnToken{
+		{Name: "A", Address: "addr-a"}, // 库里已有且未关注
+		{Name: "B", Address: "addr-b"}, // 库里已有且已关注
+		{Name: "C", Address: "addr-c"}, // 库里没有记录，本轮才入库
+	}
+	// 入库前查询的未关注列表只包含原本就在库里的未关注币
+	unfollowed := []*dto.ProjectChainData{{ContractAddress: "addr-a"}}
+
+	result := filterUnfollowedTokens(tokens, unfollowed)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "addr-a", result[0].Address)
+
+	assert.Empty(t, filterUnfollowedTokens(tokens, nil))
+}
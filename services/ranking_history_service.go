@@ -0,0 +1,92 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dryrun"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RankingHistoryKeyPrefix 情报排序历史，最新的在前
+const RankingHistoryKeyPrefix = "dogex:intelligence:ranking_history:"
+
+// RankingSnapshot 一次排序写入缓存后的结果
+type RankingSnapshot struct {
+	RankedAt time.Time     `json:"ranked_at"`
+	Tokens   []RankedToken `json:"tokens"`
+}
+
+// RankedToken 排序结果中的币
+type RankedToken struct {
+	Name            string `json:"name"`
+	Symbol          string `json:"symbol"`
+	Chain           string `json:"chain"`
+	ContractAddress string `json:"contract_address"`
+	IsRugged        bool   `json:"is_rugged,omitempty"`
+}
+
+func newRankingSnapshot(tokens []dto_cache.IntelligenceToken, rankedAt time.Time) RankingSnapshot {
+	snapshot := RankingSnapshot{RankedAt: rankedAt, Tokens: make([]RankedToken, 0, len(tokens))}
+	for _, token := range tokens {
+		snapshot.Tokens = append(snapshot.Tokens, RankedToken{
+			Name:            token.Name,
+			Symbol:          token.Symbol,
+			Chain:           token.Chain.Slug,
+			ContractAddress: token.ContractAddress,
+			IsRugged:        token.IsRugged,
+		})
+	}
+	return snapshot
+}
+
+// recordRankingHistory 记录排序结果，只保留最近 RANKING_HISTORY_SIZE 条，与币缓存同时过期
+// dry-run 时币缓存的修改已记录，这里不再写入
+func recordRankingHistory(ctx context.Context, intelligenceID string, tokens []dto_cache.IntelligenceToken) {
+	if dryrun.Enabled() || consts.RANKING_HISTORY_SIZE <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(newRankingSnapshot(tokens, time.Now()))
+	if err != nil {
+		lr.E().Error(err)
+		return
+	}
+	key := RankingHistoryKeyPrefix + intelligenceID
+	_, err = cache.MainRedis().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, int64(consts.RANKING_HISTORY_SIZE-1))
+		pipe.Expire(ctx, key, CacheExpiration)
+		return nil
+	})
+	if err != nil {
+		lr.E().Errorf("Failed to record ranking history of intelligence %s: %v", intelligenceID, err)
+	}
+}
+
+// GetRankingHistory 获取情报最近的排序历史，最新的在前
+func GetRankingHistory(ctx context.Context, intelligenceID string, limit int) ([]RankingSnapshot, error) {
+	if limit <= 0 {
+		limit = consts.RANKING_HISTORY_SIZE
+	}
+	values, err := cache.MainRedis().LRange(ctx, RankingHistoryKeyPrefix+intelligenceID, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]RankingSnapshot, 0, len(values))
+	for _, value := range values {
+		var snapshot RankingSnapshot
+		if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
+			lr.E().Errorf("Failed to decode ranking history of intelligence %s: %v", intelligenceID, err)
+			continue
+		}
+		history = append(history, snapshot)
+	}
+	return history, nil
+}
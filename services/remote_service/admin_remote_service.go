@@ -1,9 +1,11 @@
 package remote_service
 
 import (
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
 	"back_ai_gun_data/pkg/model/dto_cache"
+	"context"
 	"time"
 
 	"back_ai_gun_data/pkg/model/remote"
@@ -20,9 +22,10 @@ func getAdminHost() string {
 	return "https://api.idogex.ai"
 }
 
-func callAdminRanking(req dto.RankReq) ([]dto.IntelligenceTokenRankResp, error) {
+func callAdminRanking(ctx context.Context, req dto.RankReq) ([]dto.IntelligenceTokenRankResp, error) {
 	urlIns := getAdminHost() + AdminRankingURL
 	resp, err := Cli().R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(req).
 		Post(urlIns)
@@ -49,14 +52,14 @@ func convertCacheToTokenReq(cacheTokens []dto_cache.IntelligenceToken) []dto.Old
 	return tokenList
 }
 
-func CallAdminRankingWithGmGnTokens(intelligenceID string, oldTokens []dto_cache.IntelligenceToken, newTokens []remote.GmGnToken) ([]dto_cache.IntelligenceToken, error) {
+func CallAdminRankingWithGmGnTokens(ctx context.Context, intelligenceID string, oldTokens []dto_cache.IntelligenceToken, newTokens []remote.GmGnToken) ([]dto_cache.IntelligenceToken, error) {
 	req := dto.RankReq{
 		IntelligenceID:      intelligenceID,
 		IntelligenceHotData: convertCacheToTokenReq(oldTokens),
 		TokenList:           convertGmGnTokensToNewTokenReq(newTokens),
 	}
 
-	rankedTokens, err := callAdminRanking(req)
+	rankedTokens, err := callAdminRanking(ctx, req)
 	if err != nil {
 		lr.E().Error(err)
 		return nil, err
@@ -77,7 +80,7 @@ func ConvertSortResponseToCache(dtoTokens []dto.IntelligenceTokenRankResp) []dto
 	result := make([]dto_cache.IntelligenceToken, 0, len(dtoTokens))
 	for _, dtoToken := range dtoTokens {
 		// 判断是否为外部API数据结构
-		isExternalAPI := dtoToken.Network != "" || dtoToken.PriceUSD != "" || dtoToken.Volume24h != ""
+		isExternalAPI := dtoToken.Network != "" || dtoToken.PriceUSD.IsValid() || dtoToken.Volume24h.IsValid()
 
 		// 解析时间字符串
 		var createdAt, updatedAt dto_cache.CustomTime
@@ -117,11 +120,13 @@ func ConvertSortResponseToCache(dtoTokens []dto.IntelligenceTokenRankResp) []dto
 				ContractAddress: contractAddress,
 				Logo:            dtoToken.Logo,
 				Stats: dto_cache.CoinMarketStats{
-					WarningPriceUSD:     "0", // 外部API没有预警价格
-					WarningMarketCap:    "0", // 外部API没有预警市值
+					WarningPriceUSD:     decimal.Zero, // 外部API没有预警价格
+					WarningMarketCap:    decimal.Zero, // 外部API没有预警市值
 					CurrentPriceUSD:     dtoToken.PriceUSD,
 					CurrentMarketCap:    dtoToken.CurrentMarketCap,
-					HighestIncreaseRate: "0", // 外部API没有涨幅信息
+					HighestIncreaseRate: decimal.Zero, // 外部API没有涨幅信息
+					Liquidity:           dtoToken.Liquidity,
+					Volume24h:           dtoToken.Volume24h,
 				},
 				Chain: dto_cache.ChainInfo{
 					ID:        "",               // 外部API没有链ID
@@ -131,8 +136,9 @@ func ConvertSortResponseToCache(dtoTokens []dto.IntelligenceTokenRankResp) []dto
 					Slug:      dtoToken.Network, // 使用Network字段作为Slug
 					Logo:      "",               // 外部API没有链Logo
 				},
-				CreatedAt: dto_cache.CustomTime{}, // 外部API没有创建时间
-				UpdatedAt: dto_cache.CustomTime{}, // 外部API没有更新时间
+				IsInternal: dtoToken.IsInternal,
+				CreatedAt:  dto_cache.CustomTime{}, // 外部API没有创建时间
+				UpdatedAt:  dto_cache.CustomTime{}, // 外部API没有更新时间
 			}
 		} else {
 			// 内部数据结构处理（原有逻辑）
@@ -151,6 +157,8 @@ func ConvertSortResponseToCache(dtoTokens []dto.IntelligenceTokenRankResp) []dto
 					CurrentPriceUSD:     dtoToken.Stats.CurrentPriceUSD,
 					CurrentMarketCap:    dtoToken.Stats.CurrentMarketCap,
 					HighestIncreaseRate: dtoToken.Stats.HighestIncreaseRate,
+					Liquidity:           dtoToken.Stats.Liquidity,
+					Volume24h:           dtoToken.Stats.Volume24h,
 				},
 				Chain: dto_cache.ChainInfo{
 					ID:        dtoToken.Chain.ID,
@@ -160,8 +168,9 @@ func ConvertSortResponseToCache(dtoTokens []dto.IntelligenceTokenRankResp) []dto
 					Slug:      dtoToken.Chain.Slug,
 					Logo:      dtoToken.Chain.Logo,
 				},
-				CreatedAt: createdAt,
-				UpdatedAt: updatedAt,
+				IsInternal: dtoToken.IsInternal,
+				CreatedAt:  createdAt,
+				UpdatedAt:  updatedAt,
 			}
 		}
 
@@ -2,10 +2,12 @@ package remote_service
 
 import (
 	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/decimal"
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/pkg/model/dto"
 	"back_ai_gun_data/pkg/model/dto_cache"
 	"back_ai_gun_data/utils"
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
@@ -22,10 +24,10 @@ var cacheTokens = []dto_cache.IntelligenceToken{
 		Logo:     "https://assets.coingecko.com/coins/images/1/large/bitcoin.png?1547033579",
 		Decimals: 18,
 		Stats: dto_cache.CoinMarketStats{
-			CurrentPriceUSD:  "50000",
-			CurrentMarketCap: "100000000",
-			WarningPriceUSD:  "60000",
-			WarningMarketCap: "120000000",
+			CurrentPriceUSD:  decimal.RequireFromString("50000"),
+			CurrentMarketCap: decimal.RequireFromString("100000000"),
+			WarningPriceUSD:  decimal.RequireFromString("60000"),
+			WarningMarketCap: decimal.RequireFromString("120000000"),
 		},
 	},
 	{
@@ -35,10 +37,10 @@ var cacheTokens = []dto_cache.IntelligenceToken{
 		Logo:     "https://assets.coingecko.com/coins/images/279/large/ethereum.png?1595348880",
 		Decimals: 18,
 		Stats: dto_cache.CoinMarketStats{
-			CurrentPriceUSD:  "2000",
-			CurrentMarketCap: "4000000",
-			WarningPriceUSD:  "3000",
-			WarningMarketCap: "5000000",
+			CurrentPriceUSD:  decimal.RequireFromString("2000"),
+			CurrentMarketCap: decimal.RequireFromString("4000000"),
+			WarningPriceUSD:  decimal.RequireFromString("3000"),
+			WarningMarketCap: decimal.RequireFromString("5000000"),
 		},
 	},
 }
@@ -50,7 +52,7 @@ func TestCallAdminRanking(t *testing.T) {
 
 	intelligenceID := "0198f0a9-0e77-721b-99df-b94e851375d1"
 	//rankedCoins, err := callAdminRanking(intelligenceID, dtoCacheSliceToDTO(cacheTokens), dtoCacheSliceToDTO(cacheTokens))
-	rankedCoins, err := callAdminRanking(dto.RankReq{
+	rankedCoins, err := callAdminRanking(context.Background(), dto.RankReq{
 		IntelligenceID: intelligenceID,
 		TokenList: []dto.NewTokenReq{
 			{
@@ -93,13 +95,13 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					Logo:             "",
 					Name:             "American Bitcoin",
 					Network:          "solana",
-					PriceUSD:         "4.5295194983839864e-05",
+					PriceUSD:         decimal.RequireFromString("4.5295194983839864e-05"),
 					Symbol:           "ABTC",
-					TotalSupply:      "",
-					Volume24h:        "14256.278341192165",
+					TotalSupply:      decimal.RequireFromString(""),
+					Volume24h:        decimal.RequireFromString("14256.278341192165"),
 					IsInternal:       false,
-					Liquidity:        "13769.771650744993",
-					CurrentMarketCap: "45184.689723956646",
+					Liquidity:        decimal.RequireFromString("13769.771650744993"),
+					CurrentMarketCap: decimal.RequireFromString("45184.689723956646"),
 				},
 				{
 					ContractAddress:  "3SmtvPSgYUS8HWFN7DiiGTA733r8QXim3MV1idJipump",
@@ -109,13 +111,13 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					Logo:             "",
 					Name:             "American Bitcoin",
 					Network:          "solana",
-					PriceUSD:         "1.4474646916910041e-05",
+					PriceUSD:         decimal.RequireFromString("1.4474646916910041e-05"),
 					Symbol:           "ABTC",
-					TotalSupply:      "",
-					Volume24h:        "1911.1736697801687",
+					TotalSupply:      decimal.RequireFromString(""),
+					Volume24h:        decimal.RequireFromString("1911.1736697801687"),
 					IsInternal:       false,
-					Liquidity:        "7626.083573756391",
-					CurrentMarketCap: "14474.64691691004",
+					Liquidity:        decimal.RequireFromString("7626.083573756391"),
+					CurrentMarketCap: decimal.RequireFromString("14474.64691691004"),
 				},
 			},
 			expected: []dto_cache.IntelligenceToken{
@@ -129,11 +131,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress: "95KycpufBV37vuceYuwuNdyBV2a1HXu94ceEv6eUpump",
 					Logo:            "",
 					Stats: dto_cache.CoinMarketStats{
-						WarningPriceUSD:     "0",
-						WarningMarketCap:    "0",
-						CurrentPriceUSD:     "4.5295194983839864e-05",
-						CurrentMarketCap:    "45184.689723956646",
-						HighestIncreaseRate: "0",
+						WarningPriceUSD:     decimal.RequireFromString("0"),
+						WarningMarketCap:    decimal.RequireFromString("0"),
+						CurrentPriceUSD:     decimal.RequireFromString("4.5295194983839864e-05"),
+						CurrentMarketCap:    decimal.RequireFromString("45184.689723956646"),
+						HighestIncreaseRate: decimal.RequireFromString("0"),
 					},
 					Chain: dto_cache.ChainInfo{
 						ID:        "",
@@ -156,11 +158,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress: "3SmtvPSgYUS8HWFN7DiiGTA733r8QXim3MV1idJipump",
 					Logo:            "",
 					Stats: dto_cache.CoinMarketStats{
-						WarningPriceUSD:     "0",
-						WarningMarketCap:    "0",
-						CurrentPriceUSD:     "1.4474646916910041e-05",
-						CurrentMarketCap:    "14474.64691691004",
-						HighestIncreaseRate: "0",
+						WarningPriceUSD:     decimal.RequireFromString("0"),
+						WarningMarketCap:    decimal.RequireFromString("0"),
+						CurrentPriceUSD:     decimal.RequireFromString("1.4474646916910041e-05"),
+						CurrentMarketCap:    decimal.RequireFromString("14474.64691691004"),
+						HighestIncreaseRate: decimal.RequireFromString("0"),
 					},
 					Chain: dto_cache.ChainInfo{
 						ID:        "",
@@ -198,11 +200,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					CreatedAt: "2005-08-29T14:17:56.273",
 					UpdatedAt: "2005-09-02T18:14:38.681",
 					Stats: dto.CoinMarketStats{
-						WarningPriceUSD:     "0",
-						WarningMarketCap:    "0",
-						CurrentPriceUSD:     "0",
-						CurrentMarketCap:    "0",
-						HighestIncreaseRate: "0",
+						WarningPriceUSD:     decimal.RequireFromString("0"),
+						WarningMarketCap:    decimal.RequireFromString("0"),
+						CurrentPriceUSD:     decimal.RequireFromString("0"),
+						CurrentMarketCap:    decimal.RequireFromString("0"),
+						HighestIncreaseRate: decimal.RequireFromString("0"),
 					},
 				},
 			},
@@ -217,11 +219,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress: "0x4caa35c26d34297252f695bccf7908818507b949",
 					Logo:            "",
 					Stats: dto_cache.CoinMarketStats{
-						WarningPriceUSD:     "0",
-						WarningMarketCap:    "0",
-						CurrentPriceUSD:     "0",
-						CurrentMarketCap:    "0",
-						HighestIncreaseRate: "0",
+						WarningPriceUSD:     decimal.RequireFromString("0"),
+						WarningMarketCap:    decimal.RequireFromString("0"),
+						CurrentPriceUSD:     decimal.RequireFromString("0"),
+						CurrentMarketCap:    decimal.RequireFromString("0"),
+						HighestIncreaseRate: decimal.RequireFromString("0"),
 					},
 					Chain: dto_cache.ChainInfo{
 						ID:        "019782be-e551-78b8-8582-62a47fa81f77",
@@ -244,8 +246,8 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress:  "95KycpufBV37vuceYuwuNdyBV2a1HXu94ceEv6eUpump",
 					Name:             "External Token",
 					Network:          "ethereum",
-					PriceUSD:         "1.0",
-					CurrentMarketCap: "1000000",
+					PriceUSD:         decimal.RequireFromString("1.0"),
+					CurrentMarketCap: decimal.RequireFromString("1000000"),
 				},
 				// 内部数据
 				{
@@ -257,7 +259,7 @@ func TestConvertSortResponseToCache(t *testing.T) {
 						Slug: "ethereum",
 					},
 					Stats: dto.CoinMarketStats{
-						CurrentPriceUSD: "2.0",
+						CurrentPriceUSD: decimal.RequireFromString("2.0"),
 					},
 				},
 			},
@@ -272,11 +274,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress: "95KycpufBV37vuceYuwuNdyBV2a1HXu94ceEv6eUpump",
 					Logo:            "",
 					Stats: dto_cache.CoinMarketStats{
-						WarningPriceUSD:     "0",
-						WarningMarketCap:    "0",
-						CurrentPriceUSD:     "1.0",
-						CurrentMarketCap:    "1000000",
-						HighestIncreaseRate: "0",
+						WarningPriceUSD:     decimal.RequireFromString("0"),
+						WarningMarketCap:    decimal.RequireFromString("0"),
+						CurrentPriceUSD:     decimal.RequireFromString("1.0"),
+						CurrentMarketCap:    decimal.RequireFromString("1000000"),
+						HighestIncreaseRate: decimal.RequireFromString("0"),
 					},
 					Chain: dto_cache.ChainInfo{
 						ID:        "",
@@ -299,11 +301,11 @@ func TestConvertSortResponseToCache(t *testing.T) {
 					ContractAddress: "0x123",
 					Logo:            "",
 					Stats: dto_cache.CoinMarketStats{
-						WarningPriceUSD:     "",
-						WarningMarketCap:    "",
-						CurrentPriceUSD:     "2.0",
-						CurrentMarketCap:    "",
-						HighestIncreaseRate: "",
+						WarningPriceUSD:     decimal.RequireFromString(""),
+						WarningMarketCap:    decimal.RequireFromString(""),
+						CurrentPriceUSD:     decimal.RequireFromString("2.0"),
+						CurrentMarketCap:    decimal.RequireFromString(""),
+						HighestIncreaseRate: decimal.RequireFromString(""),
 					},
 					Chain: dto_cache.ChainInfo{
 						ID:        "",
@@ -375,8 +377,8 @@ func TestConvertSortResponseToCacheEdgeCases(t *testing.T) {
 				ContractAddress:  "",
 				Name:             "",
 				Network:          "",
-				PriceUSD:         "",
-				CurrentMarketCap: "",
+				PriceUSD:         decimal.RequireFromString(""),
+				CurrentMarketCap: decimal.RequireFromString(""),
 			},
 		}
 
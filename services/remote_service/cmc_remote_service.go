@@ -0,0 +1,211 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	cmcInfoURL   = "/v2/cryptocurrency/info"
+	cmcQuotesURL = "/v2/cryptocurrency/quotes/latest"
+
+	// info 接口单次解析的合约地址数量
+	cmcInfoBatchSize = 20
+	// CMC 未收录的地址在该时间后重新解析，新上架的币可以查到
+	cmcIDMissTTL = 6 * time.Hour
+)
+
+func getCMCHost() string {
+	return "https://pro-api.coinmarketcap.com"
+}
+
+// cmcIDCache 合约地址到 CMC ID 的缓存，未收录的地址 id 为 0 并在 cmcIDMissTTL 后过期
+var cmcIDCache sync.Map // remote.PriceKey -> cmcIDEntry
+
+type cmcIDEntry struct {
+	id       int
+	expireAt time.Time // 为零表示不过期
+}
+
+// cmcRef 待解析 CMC ID 的币及其在 CMC 的平台名称
+type cmcRef struct {
+	ref      remote.TokenRef
+	platform string
+}
+
+// cmcPriceProvider 通过 CoinMarketCap 获取行情
+// 先用 info 接口把合约地址解析为 CMC ID，再批量查询 quotes
+type cmcPriceProvider struct{}
+
+func (p *cmcPriceProvider) Name() string {
+	return consts.SOURCE_API_CMC
+}
+
+func (p *cmcPriceProvider) GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error) {
+	result := make(map[string]remote.TokenQuote, len(refs))
+	if consts.CMC_API_KEY == "" {
+		return result, nil
+	}
+
+	slugs := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		slugs = append(slugs, ref.Chain)
+	}
+	chains, err := getChainsBySlugs(ctx, slugs)
+	if err != nil {
+		return result, err
+	}
+
+	cmcRefs := make([]cmcRef, 0, len(refs))
+	for _, ref := range refs {
+		chainInfo, ok := chains[strings.ToLower(ref.Chain)]
+		if !ok || chainInfo.CoinMarketCapChainName == nil || *chainInfo.CoinMarketCapChainName == "" {
+			continue
+		}
+		cmcRefs = append(cmcRefs, cmcRef{ref: ref, platform: *chainInfo.CoinMarketCapChainName})
+	}
+
+	resolved, err := resolveCMCIDs(ctx, cmcRefs)
+	if err != nil {
+		// 部分批次失败时仍查询已解析的币
+		lr.E().Error(err)
+	}
+
+	refByID := make(map[int][]remote.TokenRef)
+	ids := make([]string, 0, len(resolved))
+	for _, item := range cmcRefs {
+		id, ok := resolved[item.ref.GetUniqueKey()]
+		if !ok {
+			continue
+		}
+		if _, ok := refByID[id]; !ok {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		refByID[id] = append(refByID[id], item.ref)
+	}
+
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var quotes remote.CMCQuotesResponse
+	if err := getCMC(ctx, cmcQuotesURL, map[string]string{"id": strings.Join(ids, ",")}, &quotes); err != nil {
+		return result, err
+	}
+
+	for _, quote := range quotes.Data {
+		usd := quote.Quote.USD
+		timestamp := time.Now()
+		if t, err := time.Parse(time.RFC3339, usd.LastUpdated); err == nil {
+			timestamp = t
+		}
+		for _, ref := range refByID[quote.ID] {
+			result[ref.GetUniqueKey()] = remote.TokenQuote{
+				Chain:     ref.Chain,
+				Address:   ref.Address,
+				PriceUSD:  usd.Price,
+				MarketCap: usd.MarketCap,
+				Volume24h: usd.Volume24h,
+				Source:    consts.SOURCE_API_CMC,
+				Timestamp: timestamp,
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// resolveCMCIDs 按批解析合约地址的 CMC ID，返回已收录的币，要求 CMC 返回的平台与链配置一致
+// 某一批失败时跳过该批继续解析，返回最后一个错误
+func resolveCMCIDs(ctx context.Context, refs []cmcRef) (map[string]int, error) {
+	result := make(map[string]int, len(refs))
+	now := time.Now()
+
+	pending := make([]cmcRef, 0, len(refs))
+	for _, item := range refs {
+		key := item.ref.GetUniqueKey()
+		if v, ok := cmcIDCache.Load(key); ok {
+			entry := v.(cmcIDEntry)
+			if entry.expireAt.IsZero() || now.Before(entry.expireAt) {
+				if entry.id > 0 {
+					result[key] = entry.id
+				}
+				continue
+			}
+		}
+		pending = append(pending, item)
+	}
+
+	var lastErr error
+	for start := 0; start < len(pending); start += cmcInfoBatchSize {
+		batch := pending[start:min(start+cmcInfoBatchSize, len(pending))]
+		addresses := make([]string, 0, len(batch))
+		for _, item := range batch {
+			addresses = append(addresses, item.ref.Address)
+		}
+
+		// skip_invalid 时未收录的地址不影响同批其他地址
+		var info remote.CMCInfoResponse
+		err := getCMC(ctx, cmcInfoURL, map[string]string{"address": strings.Join(addresses, ","), "skip_invalid": "true"}, &info)
+		// 400 表示地址都未收录，其余错误不缓存
+		if err != nil && info.Status.ErrorCode != 400 {
+			lastErr = err
+			continue
+		}
+
+		for _, item := range batch {
+			id := matchCMCID(info, item)
+			entry := cmcIDEntry{id: id}
+			if id == 0 {
+				entry.expireAt = now.Add(cmcIDMissTTL)
+			} else {
+				result[item.ref.GetUniqueKey()] = id
+			}
+			cmcIDCache.Store(item.ref.GetUniqueKey(), entry)
+		}
+	}
+	return result, lastErr
+}
+
+// matchCMCID 在 info 响应中查找合约地址和平台都匹配的币，未找到返回 0
+func matchCMCID(info remote.CMCInfoResponse, item cmcRef) int {
+	for _, coin := range info.Data {
+		for _, addr := range coin.ContractAddress {
+			if strings.EqualFold(addr.ContractAddress, item.ref.Address) && strings.EqualFold(addr.Platform.Name, item.platform) {
+				return coin.ID
+			}
+		}
+	}
+	return 0
+}
+
+func getCMC(ctx context.Context, path string, params map[string]string, out interface{}) error {
+	resp, err := Cli().R().
+		SetContext(ctx).
+		SetHeader("X-CMC_PRO_API_KEY", consts.CMC_API_KEY).
+		SetQueryParams(params).
+		Get(getCMCHost() + path)
+	if err != nil {
+		lr.E().Error("CMC request failed: ", err)
+		return fmt.Errorf("cmc request failed: %w", err)
+	}
+
+	if err := jsoniter.Unmarshal(resp.Body(), out); err != nil {
+		lr.E().Errorf("Failed to unmarshal cmc response: %v", err)
+		return err
+	}
+
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("cmc http error: %d", resp.StatusCode())
+	}
+	return nil
+}
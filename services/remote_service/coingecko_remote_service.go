@@ -0,0 +1,201 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	coinGeckoTokenPriceURL = "/api/v3/simple/token_price/%s"
+	// CoinGecko 单次查询的合约地址数量
+	coinGeckoPriceBatchSize = 30
+)
+
+func getCoinGeckoHost() string {
+	return "https://api.coingecko.com"
+}
+
+// coinGeckoPriceProvider 通过 CoinGecko simple/token_price 获取行情
+// 链映射使用 chain.coin_gecko_chain_name（即 CoinGecko 的 asset platform id）
+type coinGeckoPriceProvider struct{}
+
+func (p *coinGeckoPriceProvider) Name() string {
+	return consts.SOURCE_API_COINGECKO
+}
+
+func (p *coinGeckoPriceProvider) GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error) {
+	result := make(map[string]remote.TokenQuote, len(refs))
+
+	refsByChain := make(map[string][]remote.TokenRef)
+	slugs := make([]string, 0)
+	for _, ref := range refs {
+		chain := strings.ToLower(ref.Chain)
+		if _, ok := refsByChain[chain]; !ok {
+			slugs = append(slugs, chain)
+		}
+		refsByChain[chain] = append(refsByChain[chain], ref)
+	}
+
+	chains, err := getChainsBySlugs(ctx, slugs)
+	if err != nil {
+		return result, err
+	}
+
+	var lastErr error
+	for chain, chainRefs := range refsByChain {
+		chainInfo, ok := chains[chain]
+		if !ok || chainInfo.CoinGeckoChainName == nil || *chainInfo.CoinGeckoChainName == "" {
+			continue
+		}
+		platform := *chainInfo.CoinGeckoChainName
+
+		for start := 0; start < len(chainRefs); start += coinGeckoPriceBatchSize {
+			end := start + coinGeckoPriceBatchSize
+			if end > len(chainRefs) {
+				end = len(chainRefs)
+			}
+			batch := chainRefs[start:end]
+
+			prices, err := queryCoinGeckoTokenPrices(ctx, platform, batch)
+			if err != nil {
+				// 某条链失败时继续查询其他链，未查到的币交给下一个价格源
+				lr.E().Errorf("CoinGecko price query failed for chain %s: %v", chain, err)
+				lastErr = err
+				continue
+			}
+
+			for _, ref := range batch {
+				price, ok := prices[strings.ToLower(ref.Address)]
+				if !ok {
+					continue
+				}
+				timestamp := time.Now()
+				if price.LastUpdatedAt > 0 {
+					timestamp = time.Unix(price.LastUpdatedAt, 0)
+				}
+				result[ref.GetUniqueKey()] = remote.TokenQuote{
+					Chain:     ref.Chain,
+					Address:   ref.Address,
+					PriceUSD:  price.USD,
+					MarketCap: price.USDMarketCap,
+					Volume24h: price.USD24hVol,
+					Source:    consts.SOURCE_API_COINGECKO,
+					Timestamp: timestamp,
+				}
+			}
+		}
+	}
+
+	if len(result) == 0 && lastErr != nil {
+		return result, lastErr
+	}
+	return result, nil
+}
+
+// queryCoinGeckoTokenPrices 查询同一平台下多个合约地址的价格，返回小写地址到价格的映射
+func queryCoinGeckoTokenPrices(ctx context.Context, platform string, refs []remote.TokenRef) (map[string]remote.CoinGeckoTokenPrice, error) {
+	addresses := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		addresses = append(addresses, ref.Address)
+	}
+
+	apiURL := getCoinGeckoHost() + fmt.Sprintf(coinGeckoTokenPriceURL, platform)
+	resp, err := Cli().R().
+		SetContext(ctx).
+		SetHeader("x-cg-demo-api-key", consts.COIN_GECKO_KEY).
+		SetQueryParams(map[string]string{
+			"contract_addresses":      strings.Join(addresses, ","),
+			"vs_currencies":           "usd",
+			"include_market_cap":      "true",
+			"include_24hr_vol":        "true",
+			"include_last_updated_at": "true",
+		}).
+		Get(apiURL)
+	if err != nil {
+		lr.E().Error("queryCoinGeckoTokenPrices failed: ", err)
+		return nil, fmt.Errorf("query coingecko token price failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("coingecko http error: %d", resp.StatusCode())
+	}
+
+	var data map[string]remote.CoinGeckoTokenPrice
+	if err := jsoniter.Unmarshal(resp.Body(), &data); err != nil {
+		lr.E().Errorf("Failed to unmarshal coingecko response: %v", err)
+		return nil, err
+	}
+
+	prices := make(map[string]remote.CoinGeckoTokenPrice, len(data))
+	for address, price := range data {
+		prices[strings.ToLower(address)] = price
+	}
+	return prices, nil
+}
+
+const (
+	coinGeckoMarketChartRangeURL = "/api/v3/coins/%s/contract/%s/market_chart/range"
+	// 查询历史价格时向前后扩展的时间范围，也是找不到区间内价格点时的容差
+	coinGeckoHistoryTolerance = time.Hour
+)
+
+// coinGeckoHistoricalPriceProvider 通过 CoinGecko market_chart/range 获取历史价格
+// 1天内的范围 CoinGecko 返回5分钟粒度的数据
+type coinGeckoHistoricalPriceProvider struct{}
+
+func (p *coinGeckoHistoricalPriceProvider) Name() string {
+	return consts.SOURCE_API_COINGECKO
+}
+
+func (p *coinGeckoHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	chains, err := getChainsBySlugs(ctx, []string{ref.Chain})
+	if err != nil {
+		return nil, err
+	}
+	chainInfo, ok := chains[strings.ToLower(ref.Chain)]
+	if !ok || chainInfo.CoinGeckoChainName == nil || *chainInfo.CoinGeckoChainName == "" {
+		return nil, ErrHistoricalPriceNotFound
+	}
+
+	apiURL := getCoinGeckoHost() + fmt.Sprintf(coinGeckoMarketChartRangeURL, *chainInfo.CoinGeckoChainName, ref.Address)
+	resp, err := Cli().R().
+		SetContext(ctx).
+		SetHeader("x-cg-demo-api-key", consts.COIN_GECKO_KEY).
+		SetQueryParams(map[string]string{
+			"vs_currency": "usd",
+			"from":        fmt.Sprintf("%d", at.Add(-coinGeckoHistoryTolerance).Unix()),
+			"to":          fmt.Sprintf("%d", at.Add(coinGeckoHistoryTolerance).Unix()),
+		}).
+		Get(apiURL)
+	if err != nil {
+		lr.E().Error("query coingecko market chart failed: ", err)
+		return nil, fmt.Errorf("query coingecko market chart failed: %w", err)
+	}
+
+	// 合约未收录时返回404
+	if resp.StatusCode() == 404 {
+		return nil, ErrHistoricalPriceNotFound
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("coingecko http error: %d", resp.StatusCode())
+	}
+
+	var chart remote.CoinGeckoMarketChart
+	if err := jsoniter.Unmarshal(resp.Body(), &chart); err != nil {
+		lr.E().Errorf("Failed to unmarshal coingecko market chart: %v", err)
+		return nil, err
+	}
+
+	candle := buildOHLCV(&chart, at, HistoricalCandleInterval, coinGeckoHistoryTolerance, consts.SOURCE_API_COINGECKO)
+	if candle == nil {
+		return nil, ErrHistoricalPriceNotFound
+	}
+	return candle, nil
+}
@@ -0,0 +1,154 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	geckoTerminalTokenPoolsURL = "/api/v2/networks/%s/tokens/%s/pools"
+	geckoTerminalPoolOHLCVURL  = "/api/v2/networks/%s/pools/%s/ohlcv/minute"
+	// 查询历史K线时向前的容差，超过该时间没有成交视为查不到
+	geckoTerminalHistoryTolerance = time.Hour
+)
+
+func getGeckoTerminalHost() string {
+	return "https://api.geckoterminal.com"
+}
+
+// geckoTerminalNetworks CoinGecko asset platform id 对应的 GeckoTerminal network id
+var geckoTerminalNetworks = map[string]string{
+	"solana":              "solana",
+	"ethereum":            "eth",
+	"binance-smart-chain": "bsc",
+	"base":                "base",
+	"arbitrum-one":        "arbitrum",
+	"optimistic-ethereum": "optimism",
+	"polygon-pos":         "polygon_pos",
+	"avalanche":           "avax",
+	"tron":                "tron",
+	"sui":                 "sui-network",
+	"the-open-network":    "ton",
+}
+
+// geckoTerminalHistoricalPriceProvider 通过 GeckoTerminal 交易池K线获取历史价格
+// CoinGecko 未收录的新币通常已有 DEX 交易池，使用流动性最高的交易池的5分钟K线
+type geckoTerminalHistoricalPriceProvider struct{}
+
+func (p *geckoTerminalHistoricalPriceProvider) Name() string {
+	return consts.SOURCE_API_GECKOTERMINAL
+}
+
+func (p *geckoTerminalHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	chains, err := getChainsBySlugs(ctx, []string{ref.Chain})
+	if err != nil {
+		return nil, err
+	}
+	chainInfo, ok := chains[strings.ToLower(ref.Chain)]
+	if !ok || chainInfo.CoinGeckoChainName == nil {
+		return nil, ErrHistoricalPriceNotFound
+	}
+	network, ok := geckoTerminalNetworks[*chainInfo.CoinGeckoChainName]
+	if !ok {
+		return nil, ErrHistoricalPriceNotFound
+	}
+
+	pool, err := p.topPool(ctx, network, ref.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := getGeckoTerminalHost() + fmt.Sprintf(geckoTerminalPoolOHLCVURL, network, pool)
+	resp, err := Cli().R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{
+			"aggregate":        fmt.Sprintf("%d", int(HistoricalCandleInterval/time.Minute)),
+			"before_timestamp": fmt.Sprintf("%d", at.Unix()),
+			"limit":            fmt.Sprintf("%d", int(geckoTerminalHistoryTolerance/HistoricalCandleInterval)+1),
+			"currency":         "usd",
+			"token":            ref.Address,
+		}).
+		Get(apiURL)
+	if err != nil {
+		lr.E().Error("query geckoterminal ohlcv failed: ", err)
+		return nil, fmt.Errorf("query geckoterminal ohlcv failed: %w", err)
+	}
+	if resp.StatusCode() == 404 {
+		return nil, ErrHistoricalPriceNotFound
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("geckoterminal http error: %d", resp.StatusCode())
+	}
+
+	var ohlcv remote.GeckoTerminalOHLCV
+	if err := jsoniter.Unmarshal(resp.Body(), &ohlcv); err != nil {
+		lr.E().Errorf("Failed to unmarshal geckoterminal ohlcv: %v", err)
+		return nil, err
+	}
+
+	candle := pickGeckoTerminalCandle(ohlcv.Data.Attributes.OHLCVList, at, HistoricalCandleInterval, geckoTerminalHistoryTolerance)
+	if candle == nil {
+		return nil, ErrHistoricalPriceNotFound
+	}
+	return candle, nil
+}
+
+// topPool 代币流动性最高的交易池地址
+func (p *geckoTerminalHistoricalPriceProvider) topPool(ctx context.Context, network, address string) (string, error) {
+	apiURL := getGeckoTerminalHost() + fmt.Sprintf(geckoTerminalTokenPoolsURL, network, address)
+	resp, err := Cli().R().SetContext(ctx).Get(apiURL)
+	if err != nil {
+		lr.E().Error("query geckoterminal token pools failed: ", err)
+		return "", fmt.Errorf("query geckoterminal token pools failed: %w", err)
+	}
+	if resp.StatusCode() == 404 {
+		return "", ErrHistoricalPriceNotFound
+	}
+	if resp.StatusCode() != 200 {
+		return "", fmt.Errorf("geckoterminal http error: %d", resp.StatusCode())
+	}
+
+	var pools remote.GeckoTerminalPools
+	if err := jsoniter.Unmarshal(resp.Body(), &pools); err != nil {
+		lr.E().Errorf("Failed to unmarshal geckoterminal token pools: %v", err)
+		return "", err
+	}
+	if len(pools.Data) == 0 || pools.Data[0].Attributes.Address == "" {
+		return "", ErrHistoricalPriceNotFound
+	}
+	return pools.Data[0].Attributes.Address, nil
+}
+
+// pickGeckoTerminalCandle 取结束时间不晚于 at 的最近一根K线，Close 即 at 时刻的成交价
+// 期间没有成交时没有K线，结束时间早于 at 超过容差视为查不到
+func pickGeckoTerminalCandle(list [][6]decimal.Decimal, at time.Time, interval, tolerance time.Duration) *remote.OHLCV {
+	var best *remote.OHLCV
+	for _, item := range list {
+		start := time.Unix(int64(item[0].Float64()), 0)
+		end := start.Add(interval)
+		if end.After(at) || at.Sub(end) > tolerance {
+			continue
+		}
+		if best != nil && !start.After(best.Timestamp) {
+			continue
+		}
+		best = &remote.OHLCV{
+			Timestamp: start,
+			Open:      item[1],
+			High:      item[2],
+			Low:       item[3],
+			Close:     item[4],
+			Volume:    item[5],
+			Source:    consts.SOURCE_API_GECKOTERMINAL,
+		}
+	}
+	return best
+}
@@ -0,0 +1,68 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"strings"
+	"time"
+)
+
+// GMGN 单次查询的地址数量，limit 上限为 100
+const gmgnPriceBatchSize = 30
+
+// gmgnPriceProvider 通过 GMGN 地址查询获取行情
+type gmgnPriceProvider struct{}
+
+func (p *gmgnPriceProvider) Name() string {
+	return consts.SOURCE_API_GMGN
+}
+
+func (p *gmgnPriceProvider) GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error) {
+	result := make(map[string]remote.TokenQuote, len(refs))
+
+	for start := 0; start < len(refs); start += gmgnPriceBatchSize {
+		end := start + gmgnPriceBatchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+		batch := refs[start:end]
+
+		wanted := make(map[string]struct{}, len(batch))
+		addresses := make([]string, 0, len(batch))
+		for _, ref := range batch {
+			wanted[ref.GetUniqueKey()] = struct{}{}
+			addresses = append(addresses, ref.Address)
+		}
+
+		// q 支持地址查询，多个用逗号分隔
+		tokens, err := QueryTokensByNameWithLimit(ctx, strings.Join(addresses, ","), "", 100)
+		if err != nil {
+			lr.E().Error(err)
+			return result, err
+		}
+
+		now := time.Now()
+		for _, token := range tokens {
+			key := remote.PriceKey(token.Network, token.Address)
+			if _, ok := wanted[key]; !ok {
+				continue
+			}
+			isInternal := token.IsInternal
+			result[key] = remote.TokenQuote{
+				Chain:      token.Network,
+				Address:    token.Address,
+				PriceUSD:   token.PriceUSD,
+				MarketCap:  token.MarketCap,
+				Liquidity:  token.Liquidity,
+				Volume24h:  token.Volume24h,
+				IsInternal: &isInternal,
+				Source:     consts.SOURCE_API_GMGN,
+				Timestamp:  now,
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -118,9 +118,9 @@ func QueryTokensByNameWithLimit(ctx context.Context, name string, chain string,
 	return fillteredTokens, nil
 }
 
-func QueryTokenSecurity(address string, platform string) (*remote.TokenSecurityResp, error) {
+func QueryTokenSecurity(ctx context.Context, address string, platform string) (*remote.TokenSecurityResp, error) {
 	apiURL := GetHost() + fmt.Sprintf(tokenSecurityURL, address) + "?platform=" + platform
-	resp, err := Cli().R().Get(apiURL)
+	resp, err := Cli().R().SetContext(ctx).Get(apiURL)
 	if err != nil {
 		lr.E().Error("QueryTokenSecurity failed: ", err)
 		return nil, err
@@ -3,6 +3,7 @@ package remote_service
 import (
 	"back_ai_gun_data/pkg/lr"
 	"back_ai_gun_data/utils"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,7 +25,7 @@ func TestQueryTokenSecurity(t *testing.T) {
 	Init()
 	tokenAddress := "3Q6KfoGoa3zZ65bPcwND4XW2oBxGqisPhCmLSHzQpump"
 
-	res, err := QueryTokenSecurity(tokenAddress, "solana")
+	res, err := QueryTokenSecurity(context.Background(), tokenAddress, "solana")
 	assert.NoError(t, err)
 	t.Log(utils.ToJson(res))
 }
@@ -0,0 +1,207 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	HistoricalPriceCacheKeyPrefix = "dogex:price:history:"
+	// 历史K线不会变化，缓存较长时间
+	historicalPriceCacheTTL = 7 * 24 * time.Hour
+	// 查不到的结果缓存较短时间，避免反复请求
+	historicalPriceMissTTL = 30 * time.Minute
+	// 历史K线粒度
+	HistoricalCandleInterval = 5 * time.Minute
+)
+
+// ErrHistoricalPriceNotFound 价格源没有该时间点的数据
+var ErrHistoricalPriceNotFound = errors.New("historical price not found")
+
+// HistoricalPriceProvider 历史价格源接口，返回结束于 at 的K线，Close 即 at 时刻的价格
+type HistoricalPriceProvider interface {
+	Name() string
+	GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error)
+}
+
+var historicalPriceProvider HistoricalPriceProvider
+
+// GetHistoricalPriceProvider 获取默认历史价格源（带Redis缓存）
+func GetHistoricalPriceProvider() HistoricalPriceProvider {
+	return historicalPriceProvider
+}
+
+// initHistoricalPriceProvider CoinGecko 未收录时使用 GeckoTerminal 的 DEX 交易池K线
+func initHistoricalPriceProvider() {
+	historicalPriceProvider = NewFallbackHistoricalPriceProvider(
+		NewCachedHistoricalPriceProvider(&coinGeckoHistoricalPriceProvider{}),
+		NewCachedHistoricalPriceProvider(&geckoTerminalHistoricalPriceProvider{}),
+	)
+}
+
+// FallbackHistoricalPriceProvider 按顺序查询多个历史价格源，返回第一个查到的结果
+type FallbackHistoricalPriceProvider struct {
+	providers []HistoricalPriceProvider
+}
+
+func NewFallbackHistoricalPriceProvider(providers ...HistoricalPriceProvider) *FallbackHistoricalPriceProvider {
+	return &FallbackHistoricalPriceProvider{providers: providers}
+}
+
+func (p *FallbackHistoricalPriceProvider) Name() string {
+	names := make([]string, 0, len(p.providers))
+	for _, provider := range p.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// GetOHLCV 价格源查不到或出错时继续下一个；都查不到返回 ErrHistoricalPriceNotFound，有出错的返回最后一个错误
+func (p *FallbackHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	var lastErr error
+	for _, provider := range p.providers {
+		candle, err := provider.GetOHLCV(ctx, ref, at)
+		if err == nil {
+			return candle, nil
+		}
+		if !errors.Is(err, ErrHistoricalPriceNotFound) {
+			lr.E().Errorf("Historical price provider %s failed for %s: %v", provider.Name(), ref.GetUniqueKey(), err)
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrHistoricalPriceNotFound
+}
+
+// CachedHistoricalPriceProvider 历史价格缓存，按K线时间分桶
+type CachedHistoricalPriceProvider struct {
+	inner HistoricalPriceProvider
+}
+
+func NewCachedHistoricalPriceProvider(inner HistoricalPriceProvider) *CachedHistoricalPriceProvider {
+	return &CachedHistoricalPriceProvider{inner: inner}
+}
+
+func (p *CachedHistoricalPriceProvider) Name() string {
+	return p.inner.Name()
+}
+
+func (p *CachedHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	key := fmt.Sprintf("%s%s:%s:%d", HistoricalPriceCacheKeyPrefix, p.inner.Name(), ref.GetUniqueKey(), at.Truncate(HistoricalCandleInterval).Unix())
+
+	cached, err := cache.Get(ctx, key)
+	if err == nil {
+		if cached == "" {
+			return nil, ErrHistoricalPriceNotFound
+		}
+		var candle remote.OHLCV
+		if err := jsoniter.UnmarshalFromString(cached, &candle); err == nil {
+			return &candle, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		lr.E().Errorf("Failed to read historical price cache %s: %v", key, err)
+	}
+
+	candle, err := p.inner.GetOHLCV(ctx, ref, at)
+	if err != nil {
+		if errors.Is(err, ErrHistoricalPriceNotFound) {
+			if err := cache.Set(ctx, key, "", historicalPriceMissTTL); err != nil {
+				lr.E().Error(err)
+			}
+		}
+		return nil, err
+	}
+
+	data, err := jsoniter.MarshalToString(candle)
+	if err == nil {
+		if err := cache.Set(ctx, key, data, historicalPriceCacheTTL); err != nil {
+			lr.E().Error(err)
+		}
+	}
+	return candle, nil
+}
+
+// buildOHLCV 由价格点构建结束于 at 的K线
+// 区间内没有价格点时使用容差范围内 at 之前最近的点，at 之前没有数据时使用 at 之后最近的点
+func buildOHLCV(chart *remote.CoinGeckoMarketChart, at time.Time, interval, tolerance time.Duration, source string) *remote.OHLCV {
+	start := at.Add(-interval)
+
+	var candle *remote.OHLCV
+	lastBefore, firstAfter := -1, -1
+	for i, point := range chart.Prices {
+		ts := pointTime(point[0])
+		if ts.After(at) {
+			if firstAfter < 0 && ts.Sub(at) <= tolerance {
+				firstAfter = i
+			}
+			continue
+		}
+		if at.Sub(ts) <= tolerance {
+			lastBefore = i
+		}
+		if ts.Before(start) {
+			continue
+		}
+
+		price := point[1]
+		if candle == nil {
+			candle = &remote.OHLCV{Timestamp: start, Open: price, High: price, Low: price, Source: source}
+		}
+		if price.GreaterThan(candle.High) {
+			candle.High = price
+		}
+		if price.LessThan(candle.Low) {
+			candle.Low = price
+		}
+		candle.Close = price
+	}
+
+	if candle == nil {
+		index := lastBefore
+		if index < 0 {
+			index = firstAfter
+		}
+		if index < 0 {
+			return nil
+		}
+		price := chart.Prices[index][1]
+		candle = &remote.OHLCV{Timestamp: start, Open: price, High: price, Low: price, Close: price, Source: source}
+	}
+
+	candle.MarketCap = nearestValue(chart.MarketCaps, at, tolerance)
+	candle.Volume = nearestValue(chart.TotalVolumes, at, tolerance)
+	return candle
+}
+
+// nearestValue 取容差范围内离 at 最近的点的值，没有时返回无值
+func nearestValue(points [][2]decimal.Decimal, at time.Time, tolerance time.Duration) decimal.Decimal {
+	var result decimal.Decimal
+	best := tolerance + 1
+	for _, point := range points {
+		diff := pointTime(point[0]).Sub(at)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < best {
+			best = diff
+			result = point[1]
+		}
+	}
+	return result
+}
+
+func pointTime(ms decimal.Decimal) time.Time {
+	return time.UnixMilli(int64(ms.Float64()))
+}
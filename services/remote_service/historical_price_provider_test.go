@@ -0,0 +1,155 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newChartPoint(at time.Time, value string) [2]decimal.Decimal {
+	return [2]decimal.Decimal{decimal.NewFromInt(at.UnixMilli()), decimal.RequireFromString(value)}
+}
+
+func TestBuildOHLCV(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("区间内的价格点", func(t *testing.T) {
+		chart := &remote.CoinGeckoMarketChart{
+			Prices: [][2]decimal.Decimal{
+				newChartPoint(at.Add(-10*time.Minute), "0.5"),
+				newChartPoint(at.Add(-4*time.Minute), "1"),
+				newChartPoint(at.Add(-2*time.Minute), "3"),
+				newChartPoint(at.Add(-1*time.Minute), "2"),
+				newChartPoint(at.Add(time.Minute), "10"),
+			},
+			MarketCaps: [][2]decimal.Decimal{
+				newChartPoint(at.Add(-1*time.Minute), "2000"),
+				newChartPoint(at.Add(4*time.Minute), "10000"),
+			},
+		}
+
+		candle := buildOHLCV(chart, at, 5*time.Minute, time.Hour, "test")
+		if assert.NotNil(t, candle) {
+			assert.Equal(t, "1", candle.Open.String())
+			assert.Equal(t, "3", candle.High.String())
+			assert.Equal(t, "1", candle.Low.String())
+			assert.Equal(t, "2", candle.Close.String())
+			assert.Equal(t, "2000", candle.MarketCap.String())
+			assert.False(t, candle.Volume.IsValid())
+		}
+	})
+
+	t.Run("区间内没有价格点时使用之前最近的点", func(t *testing.T) {
+		chart := &remote.CoinGeckoMarketChart{
+			Prices: [][2]decimal.Decimal{
+				newChartPoint(at.Add(-30*time.Minute), "1"),
+				newChartPoint(at.Add(-20*time.Minute), "2"),
+				newChartPoint(at.Add(10*time.Minute), "5"),
+			},
+		}
+
+		candle := buildOHLCV(chart, at, 5*time.Minute, time.Hour, "test")
+		if assert.NotNil(t, candle) {
+			assert.Equal(t, "2", candle.Close.String())
+		}
+	})
+
+	t.Run("之前没有数据时使用之后最近的点", func(t *testing.T) {
+		chart := &remote.CoinGeckoMarketChart{
+			Prices: [][2]decimal.Decimal{
+				newChartPoint(at.Add(10*time.Minute), "5"),
+				newChartPoint(at.Add(20*time.Minute), "6"),
+			},
+		}
+
+		candle := buildOHLCV(chart, at, 5*time.Minute, time.Hour, "test")
+		if assert.NotNil(t, candle) {
+			assert.Equal(t, "5", candle.Close.String())
+		}
+	})
+
+	t.Run("超出容差范围", func(t *testing.T) {
+		chart := &remote.CoinGeckoMarketChart{
+			Prices: [][2]decimal.Decimal{newChartPoint(at.Add(-2*time.Hour), "1")},
+		}
+		assert.Nil(t, buildOHLCV(chart, at, 5*time.Minute, time.Hour, "test"))
+	})
+}
+
+type stubHistoricalPriceProvider struct {
+	name   string
+	candle *remote.OHLCV
+	err    error
+}
+
+func (p *stubHistoricalPriceProvider) Name() string {
+	return p.name
+}
+
+func (p *stubHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	return p.candle, p.err
+}
+
+func TestFallbackHistoricalPriceProvider(t *testing.T) {
+	lr.Init()
+	ref := remote.TokenRef{Chain: "solana", Address: "AAA"}
+	candle := &remote.OHLCV{Close: decimal.RequireFromString("1"), Source: "dex"}
+
+	// 前一个价格源查不到或出错时使用下一个
+	provider := NewFallbackHistoricalPriceProvider(
+		&stubHistoricalPriceProvider{name: "a", err: ErrHistoricalPriceNotFound},
+		&stubHistoricalPriceProvider{name: "b", err: errors.New("timeout")},
+		&stubHistoricalPriceProvider{name: "c", candle: candle},
+	)
+	assert.Equal(t, "a,b,c", provider.Name())
+	got, err := provider.GetOHLCV(context.Background(), ref, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, candle, got)
+
+	provider = NewFallbackHistoricalPriceProvider(
+		&stubHistoricalPriceProvider{name: "a", err: ErrHistoricalPriceNotFound},
+		&stubHistoricalPriceProvider{name: "b", err: ErrHistoricalPriceNotFound},
+	)
+	_, err = provider.GetOHLCV(context.Background(), ref, time.Now())
+	assert.ErrorIs(t, err, ErrHistoricalPriceNotFound)
+
+	provider = NewFallbackHistoricalPriceProvider(
+		&stubHistoricalPriceProvider{name: "a", err: errors.New("timeout")},
+		&stubHistoricalPriceProvider{name: "b", err: ErrHistoricalPriceNotFound},
+	)
+	_, err = provider.GetOHLCV(context.Background(), ref, time.Now())
+	assert.EqualError(t, err, "timeout")
+}
+
+func TestPickGeckoTerminalCandle(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 2, 0, 0, time.UTC)
+	candle := func(start time.Time, close string) [6]decimal.Decimal {
+		return [6]decimal.Decimal{
+			decimal.NewFromInt(start.Unix()),
+			decimal.RequireFromString("1"), decimal.RequireFromString("3"), decimal.RequireFromString("0.5"),
+			decimal.RequireFromString(close), decimal.RequireFromString("100"),
+		}
+	}
+
+	// 按时间倒序，跳过包含 at 之后成交的K线
+	list := [][6]decimal.Decimal{
+		candle(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), "9"),
+		candle(time.Date(2025, 6, 1, 11, 50, 0, 0, time.UTC), "2"),
+		candle(time.Date(2025, 6, 1, 11, 40, 0, 0, time.UTC), "1"),
+	}
+	got := pickGeckoTerminalCandle(list, at, 5*time.Minute, time.Hour)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "2", got.Close.String())
+		assert.Equal(t, time.Date(2025, 6, 1, 11, 50, 0, 0, time.UTC), got.Timestamp.UTC())
+	}
+
+	// 最近的成交超过容差
+	list = [][6]decimal.Decimal{candle(at.Add(-2*time.Hour), "1")}
+	assert.Nil(t, pickGeckoTerminalCandle(list, at, 5*time.Minute, time.Hour))
+}
@@ -0,0 +1,327 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceProvider 价格源接口，按 (chain, address) 批量查询行情
+// 返回值以 remote.PriceKey(chain, address) 为键，查不到的币不出现在结果中
+type PriceProvider interface {
+	Name() string
+	GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error)
+}
+
+var priceProvider PriceProvider
+
+// GetPriceProvider 获取默认价格源（按链回退 + 异常值剔除）
+func GetPriceProvider() PriceProvider {
+	return priceProvider
+}
+
+func initPriceProvider() {
+	providers := []PriceProvider{
+		&gmgnPriceProvider{},
+		&coinGeckoPriceProvider{},
+		&cmcPriceProvider{},
+	}
+	priceProvider = NewCompositePriceProvider(
+		providers,
+		parseProviderOrder(consts.PRICE_PROVIDERS),
+		parseChainProviderOrder(consts.PRICE_PROVIDERS_BY_CHAIN),
+		float64(consts.PRICE_OUTLIER_MAX_RATIO),
+	)
+}
+
+const (
+	// 异常读数之间的倍数不超过该值视为一致
+	outlierAgreeRatio = 2
+	// 异常读数超过该时间未再出现则重新计数
+	outlierCandidateTTL = 30 * time.Minute
+)
+
+// CompositePriceProvider 组合价格源
+// 按链配置的顺序依次查询，前一个价格源缺失或被判定为异常值的币交给下一个价格源
+// 同一个币连续 confirmations 次偏离参考价格且读数一致时接受，参考价格随之更新
+type CompositePriceProvider struct {
+	providers     map[string]PriceProvider
+	defaultOrder  []string
+	chainOrder    map[string][]string
+	maxRatio      float64
+	confirmations int
+
+	outlierMutex    sync.Mutex
+	outliers        map[string]*outlierCandidate // PriceKey -> 连续的异常读数
+	outliersSweptAt time.Time                    // 上次清理过期读数的时间
+}
+
+// outlierCandidate 连续偏离参考价格的读数
+type outlierCandidate struct {
+	price  decimal.Decimal
+	count  int
+	seenAt time.Time
+}
+
+func NewCompositePriceProvider(providers []PriceProvider, defaultOrder []string, chainOrder map[string][]string, maxRatio float64) *CompositePriceProvider {
+	providerMap := make(map[string]PriceProvider, len(providers))
+	for _, p := range providers {
+		providerMap[p.Name()] = p
+	}
+	if len(defaultOrder) == 0 {
+		for _, p := range providers {
+			defaultOrder = append(defaultOrder, p.Name())
+		}
+	}
+	if chainOrder == nil {
+		chainOrder = make(map[string][]string)
+	}
+
+	return &CompositePriceProvider{
+		providers:     providerMap,
+		defaultOrder:  defaultOrder,
+		chainOrder:    chainOrder,
+		maxRatio:      maxRatio,
+		confirmations: consts.PRICE_OUTLIER_CONFIRMATIONS,
+		outliers:      make(map[string]*outlierCandidate),
+	}
+}
+
+func (c *CompositePriceProvider) Name() string {
+	return "composite"
+}
+
+func (c *CompositePriceProvider) GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error) {
+	result := make(map[string]remote.TokenQuote, len(refs))
+
+	// 按链分组，每条链使用各自的价格源顺序
+	refsByChain := make(map[string][]remote.TokenRef)
+	for _, ref := range refs {
+		if ref.Chain == "" || ref.Address == "" {
+			continue
+		}
+		chain := strings.ToLower(ref.Chain)
+		refsByChain[chain] = append(refsByChain[chain], ref)
+	}
+
+	var lastErr error
+	for chain, chainRefs := range refsByChain {
+		pending := chainRefs
+		for _, name := range c.orderFor(chain) {
+			if len(pending) == 0 {
+				break
+			}
+			provider, ok := c.providers[name]
+			if !ok {
+				continue
+			}
+
+			quotes, err := provider.GetPrices(ctx, pending)
+			if err != nil {
+				lr.E().Errorf("Price provider %s failed for chain %s: %v", name, chain, err)
+				lastErr = err
+				// 出错时仍然使用已返回的部分结果
+			}
+
+			missing := make([]remote.TokenRef, 0, len(pending))
+			for _, ref := range pending {
+				key := ref.GetUniqueKey()
+				quote, exists := quotes[key]
+				if !exists {
+					missing = append(missing, ref)
+					continue
+				}
+				if reason := c.rejectReason(ref, quote); reason != "" {
+					if count, confirmed := c.confirmOutlier(key, quote); confirmed {
+						lr.I().Infof("Accepted %s quote for %s after %d consistent readings: %s", name, key, count, reason)
+					} else {
+						lr.I().Infof("Rejected %s quote for %s: %s", name, key, reason)
+						missing = append(missing, ref)
+						continue
+					}
+				} else {
+					c.clearOutlier(key)
+				}
+				result[key] = quote
+			}
+			pending = missing
+		}
+	}
+
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
+
+func (c *CompositePriceProvider) orderFor(chain string) []string {
+	if order, ok := c.chainOrder[strings.ToLower(chain)]; ok && len(order) > 0 {
+		return order
+	}
+	return c.defaultOrder
+}
+
+// rejectReason 判断行情是否为异常值，返回空字符串表示正常
+func (c *CompositePriceProvider) rejectReason(ref remote.TokenRef, quote remote.TokenQuote) string {
+	if !quote.PriceUSD.IsPositive() {
+		return "non-positive price " + quote.PriceUSD.String()
+	}
+
+	if c.maxRatio <= 1 {
+		return ""
+	}
+	ratio, ok := quote.PriceUSD.Ratio(ref.RefPrice)
+	if !ok {
+		return ""
+	}
+
+	maxRatio := decimal.NewFromFloat(c.maxRatio)
+	minRatio := decimal.NewFromInt(1).Div(maxRatio)
+	if ratio.GreaterThan(maxRatio) || ratio.LessThan(minRatio) {
+		return "deviates from reference price " + ref.RefPrice.String() + " by ratio " + ratio.Round(6).String()
+	}
+	return ""
+}
+
+// confirmOutlier 记录一次偏离参考价格的读数，与上次读数一致时累计，达到 confirmations 次返回 true
+func (c *CompositePriceProvider) confirmOutlier(key string, quote remote.TokenQuote) (int, bool) {
+	if c.confirmations <= 0 || !quote.PriceUSD.IsPositive() {
+		return 0, false
+	}
+
+	c.outlierMutex.Lock()
+	defer c.outlierMutex.Unlock()
+
+	now := time.Now()
+	candidate, ok := c.outliers[key]
+	if ok && now.Sub(candidate.seenAt) <= outlierCandidateTTL && agrees(candidate.price, quote.PriceUSD) {
+		candidate.count++
+	} else {
+		c.sweepOutliers(now)
+		candidate = &outlierCandidate{count: 1}
+		c.outliers[key] = candidate
+	}
+	candidate.price = quote.PriceUSD
+	candidate.seenAt = now
+
+	if candidate.count < c.confirmations {
+		return candidate.count, false
+	}
+	delete(c.outliers, key)
+	return candidate.count, true
+}
+
+// sweepOutliers 删除过期的异常读数，只出现过一次异常、之后不再查询的币不会一直留在内存中
+// 最多每 outlierCandidateTTL 清理一次，调用方持有 outlierMutex
+func (c *CompositePriceProvider) sweepOutliers(now time.Time) {
+	if now.Sub(c.outliersSweptAt) < outlierCandidateTTL {
+		return
+	}
+	c.outliersSweptAt = now
+	for key, candidate := range c.outliers {
+		if now.Sub(candidate.seenAt) > outlierCandidateTTL {
+			delete(c.outliers, key)
+		}
+	}
+}
+
+func (c *CompositePriceProvider) clearOutlier(key string) {
+	c.outlierMutex.Lock()
+	delete(c.outliers, key)
+	c.outlierMutex.Unlock()
+}
+
+// agrees 两次读数的倍数不超过 outlierAgreeRatio
+func agrees(a, b decimal.Decimal) bool {
+	ratio, ok := b.Ratio(a)
+	if !ok {
+		return false
+	}
+	agreeRatio := decimal.NewFromInt(outlierAgreeRatio)
+	return !ratio.GreaterThan(agreeRatio) && !ratio.LessThan(decimal.NewFromInt(1).Div(agreeRatio))
+}
+
+// parseProviderOrder 解析价格源顺序，eg "gmgn,coin_gecko"
+func parseProviderOrder(s string) []string {
+	var order []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// parseChainProviderOrder 解析按链的价格源顺序，eg "sui:coin_gecko,gmgn;base:gmgn"
+func parseChainProviderOrder(s string) map[string][]string {
+	result := make(map[string][]string)
+	for _, item := range strings.Split(s, ";") {
+		chain, order, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		chain = strings.ToLower(strings.TrimSpace(chain))
+		if chain == "" {
+			continue
+		}
+		result[chain] = parseProviderOrder(order)
+	}
+	return result
+}
+
+// 链信息缓存，价格源需要根据链slug获取 CoinGecko / CMC 的链名称
+const chainCacheTTL = 10 * time.Minute
+
+type cachedChain struct {
+	chain    *dto.Chain
+	expireAt time.Time
+}
+
+var chainCache sync.Map // 小写slug -> cachedChain
+
+// getChainsBySlugs 批量获取链信息，带本地缓存；未找到的链不出现在结果中
+func getChainsBySlugs(ctx context.Context, slugs []string) (map[string]*dto.Chain, error) {
+	result := make(map[string]*dto.Chain, len(slugs))
+	var missing []string
+	now := time.Now()
+	for _, slug := range slugs {
+		key := strings.ToLower(slug)
+		if v, ok := chainCache.Load(key); ok {
+			cached := v.(cachedChain)
+			if now.Before(cached.expireAt) {
+				if cached.chain != nil {
+					result[key] = cached.chain
+				}
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	chains, err := dao.GetChainsBySlugs(ctx, missing)
+	if err != nil {
+		lr.E().Error(err)
+		return nil, err
+	}
+	for _, slug := range missing {
+		chain := chains[slug]
+		chainCache.Store(slug, cachedChain{chain: chain, expireAt: now.Add(chainCacheTTL)})
+		if chain != nil {
+			result[slug] = chain
+		}
+	}
+
+	return result, nil
+}
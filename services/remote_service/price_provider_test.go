@@ -0,0 +1,185 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePriceProvider struct {
+	name   string
+	prices map[string]string // PriceKey -> price
+	err    error
+	calls  int
+}
+
+func (p *fakePriceProvider) Name() string {
+	return p.name
+}
+
+func (p *fakePriceProvider) GetPrices(ctx context.Context, refs []remote.TokenRef) (map[string]remote.TokenQuote, error) {
+	p.calls++
+	result := make(map[string]remote.TokenQuote)
+	for _, ref := range refs {
+		if price, ok := p.prices[ref.GetUniqueKey()]; ok {
+			result[ref.GetUniqueKey()] = remote.TokenQuote{Chain: ref.Chain, Address: ref.Address, PriceUSD: decimal.RequireFromString(price), Source: p.name}
+		}
+	}
+	return result, p.err
+}
+
+func TestCompositePriceProvider(t *testing.T) {
+	lr.Init()
+
+	refs := []remote.TokenRef{
+		{Chain: "solana", Address: "AAA"},
+		{Chain: "solana", Address: "BBB", RefPrice: decimal.RequireFromString("1")},
+		{Chain: "sui", Address: "0xCCC"},
+	}
+
+	tests := []struct {
+		name       string
+		primary    *fakePriceProvider
+		secondary  *fakePriceProvider
+		chainOrder map[string][]string
+		expected   map[string]string // PriceKey -> source
+	}{
+		{
+			name:      "主价格源缺失时回退",
+			primary:   &fakePriceProvider{name: "a", prices: map[string]string{"solana:aaa": "1"}},
+			secondary: &fakePriceProvider{name: "b", prices: map[string]string{"solana:bbb": "1.2", "sui:ccc": "3"}},
+			expected:  map[string]string{"solana:aaa": "a", "solana:bbb": "b", "sui:ccc": "b"},
+		},
+		{
+			name:      "异常值剔除后回退",
+			primary:   &fakePriceProvider{name: "a", prices: map[string]string{"solana:aaa": "0", "solana:bbb": "1000"}},
+			secondary: &fakePriceProvider{name: "b", prices: map[string]string{"solana:aaa": "2", "solana:bbb": "0.9"}},
+			expected:  map[string]string{"solana:aaa": "b", "solana:bbb": "b"},
+		},
+		{
+			name:       "按链配置顺序",
+			primary:    &fakePriceProvider{name: "a", prices: map[string]string{"sui:ccc": "3"}},
+			secondary:  &fakePriceProvider{name: "b", prices: map[string]string{"sui:ccc": "4"}},
+			chainOrder: map[string][]string{"sui": {"b", "a"}},
+			expected:   map[string]string{"sui:ccc": "b"},
+		},
+		{
+			name:      "主价格源出错时回退",
+			primary:   &fakePriceProvider{name: "a", err: errors.New("timeout")},
+			secondary: &fakePriceProvider{name: "b", prices: map[string]string{"solana:aaa": "1"}},
+			expected:  map[string]string{"solana:aaa": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewCompositePriceProvider([]PriceProvider{tt.primary, tt.secondary}, nil, tt.chainOrder, 50)
+			quotes, err := provider.GetPrices(context.Background(), refs)
+			assert.NoError(t, err)
+
+			sources := make(map[string]string, len(quotes))
+			for key, quote := range quotes {
+				sources[key] = quote.Source
+			}
+			assert.Equal(t, tt.expected, sources)
+		})
+	}
+}
+
+func TestCompositePriceProviderConfirmsOutliers(t *testing.T) {
+	lr.Init()
+
+	primary := &fakePriceProvider{name: "a", prices: map[string]string{"solana:bbb": "100"}}
+	secondary := &fakePriceProvider{name: "b", prices: map[string]string{"solana:bbb": "120"}}
+	provider := NewCompositePriceProvider([]PriceProvider{primary, secondary}, nil, nil, 50)
+	provider.confirmations = 3
+	refs := []remote.TokenRef{{Chain: "solana", Address: "BBB", RefPrice: decimal.RequireFromString("1")}}
+
+	// 两个价格源的读数一致，第二轮第一个读数达到确认次数
+	quotes, err := provider.GetPrices(context.Background(), refs)
+	assert.NoError(t, err)
+	assert.Empty(t, quotes)
+
+	quotes, err = provider.GetPrices(context.Background(), refs)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", quotes["solana:bbb"].Source)
+	assert.Empty(t, provider.outliers)
+
+	// 读数不一致时重新计数
+	primary.prices["solana:bbb"] = "500"
+	secondary.prices["solana:bbb"] = "100"
+	quotes, _ = provider.GetPrices(context.Background(), refs)
+	assert.Empty(t, quotes)
+	assert.Equal(t, 1, provider.outliers["solana:bbb"].count)
+
+	// 正常读数清除计数
+	secondary.prices["solana:bbb"] = "1.5"
+	quotes, _ = provider.GetPrices(context.Background(), refs)
+	assert.Equal(t, "b", quotes["solana:bbb"].Source)
+	assert.Empty(t, provider.outliers)
+}
+
+func TestCompositePriceProviderSweepsOutliers(t *testing.T) {
+	provider := NewCompositePriceProvider(nil, nil, nil, 50)
+	provider.confirmations = 3
+	provider.outliers["solana:stale"] = &outlierCandidate{price: decimal.RequireFromString("1"), count: 1, seenAt: time.Now().Add(-2 * outlierCandidateTTL)}
+	provider.outliers["solana:fresh"] = &outlierCandidate{price: decimal.RequireFromString("1"), count: 1, seenAt: time.Now()}
+
+	// 记录新的异常读数时清理不再出现的币
+	count, confirmed := provider.confirmOutlier("solana:new", remote.TokenQuote{PriceUSD: decimal.RequireFromString("100")})
+	assert.Equal(t, 1, count)
+	assert.False(t, confirmed)
+	assert.NotContains(t, provider.outliers, "solana:stale")
+	assert.Contains(t, provider.outliers, "solana:fresh")
+	assert.Contains(t, provider.outliers, "solana:new")
+}
+
+func TestCompositePriceProviderAllFailed(t *testing.T) {
+	lr.Init()
+
+	provider := NewCompositePriceProvider([]PriceProvider{
+		&fakePriceProvider{name: "a", err: errors.New("a down")},
+		&fakePriceProvider{name: "b", err: errors.New("b down")},
+	}, nil, nil, 50)
+
+	quotes, err := provider.GetPrices(context.Background(), []remote.TokenRef{{Chain: "bsc", Address: "0x1"}})
+	assert.Error(t, err)
+	assert.Empty(t, quotes)
+}
+
+func TestParseChainProviderOrder(t *testing.T) {
+	order := parseChainProviderOrder("Sui: coin_gecko, gmgn ;base:gmgn;;invalid")
+	assert.Equal(t, map[string][]string{
+		"sui":  {"coin_gecko", "gmgn"},
+		"base": {"gmgn"},
+	}, order)
+}
+
+func TestResolveCMCIDsFromCache(t *testing.T) {
+	listed := cmcRef{ref: remote.TokenRef{Chain: "bsc", Address: "0xListed"}, platform: "BNB Smart Chain (BEP20)"}
+	unlisted := cmcRef{ref: remote.TokenRef{Chain: "bsc", Address: "0xUnlisted"}, platform: "BNB Smart Chain (BEP20)"}
+	cmcIDCache.Store(listed.ref.GetUniqueKey(), cmcIDEntry{id: 42})
+	cmcIDCache.Store(unlisted.ref.GetUniqueKey(), cmcIDEntry{expireAt: time.Now().Add(time.Hour)})
+	t.Cleanup(func() {
+		cmcIDCache.Delete(listed.ref.GetUniqueKey())
+		cmcIDCache.Delete(unlisted.ref.GetUniqueKey())
+	})
+
+	// 缓存都有效时不请求 CMC
+	ids, err := resolveCMCIDs(context.Background(), []cmcRef{listed, unlisted})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{listed.ref.GetUniqueKey(): 42}, ids)
+
+	info := remote.CMCInfoResponse{Data: map[string]remote.CMCInfo{"42": {ID: 42, ContractAddress: []remote.CMCContractAddress{
+		{ContractAddress: "0xlisted"},
+	}}}}
+	info.Data["42"].ContractAddress[0].Platform.Name = "bnb smart chain (bep20)"
+	assert.Equal(t, 42, matchCMCID(info, listed))
+	assert.Equal(t, 0, matchCMCID(info, unlisted))
+}
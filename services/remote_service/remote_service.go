@@ -31,6 +31,10 @@ func Init() {
 		}
 		return nil
 	})
+	trackUpstreams(cli)
+
+	initPriceProvider()
+	initHistoricalPriceProvider()
 }
 
 func Cli() *resty.Client {
@@ -0,0 +1,251 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/metrics"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 每个上游最多保留的请求结果数
+const maxUpstreamOutcomes = 1000
+
+var (
+	upstreamDuration = metrics.NewHistogramVec("upstream_request_duration_seconds", "Upstream request latency per upstream and endpoint.", nil, "upstream", "endpoint")
+	upstreamErrors   = metrics.NewCounterVec("upstream_request_errors_total", "Failed upstream requests per upstream and endpoint, reason is the HTTP status or network.",
+		"upstream", "endpoint", "reason")
+)
+
+// 各接口的路径模板，%s 匹配任意一段，用作指标的 endpoint 标签，不在其中的记为 other
+var endpointTemplates = []string{
+	queryTokensURL, tokenSecurityURL, AdminRankingURL,
+	cmcInfoURL, cmcQuotesURL,
+	coinGeckoTokenPriceURL, coinGeckoMarketChartRangeURL,
+	geckoTerminalTokenPoolsURL, geckoTerminalPoolOHLCVURL,
+}
+
+// 上游 host 对应的名称，用作指标的 upstream 标签
+var upstreamNames = map[string]string{
+	hostOf(GetHost()):              "gmgn",
+	hostOf(getAdminHost()):         "admin",
+	hostOf(getCMCHost()):           "cmc",
+	hostOf(getCoinGeckoHost()):     "coingecko",
+	hostOf(getGeckoTerminalHost()): "geckoterminal",
+}
+
+// UpstreamStat 上游在统计窗口内的请求情况
+type UpstreamStat struct {
+	Host        string  `json:"host"`
+	Requests    int     `json:"requests"`
+	Failures    int     `json:"failures"`
+	SuccessRate float64 `json:"success_rate"` // 没有请求时为1
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// Healthy 请求数达到 UPSTREAM_MIN_REQUESTS 且成功率低于 UPSTREAM_MIN_SUCCESS_PERCENT 时不可用
+func (s UpstreamStat) Healthy() bool {
+	return s.Requests < consts.UPSTREAM_MIN_REQUESTS || s.SuccessRate*100 >= float64(consts.UPSTREAM_MIN_SUCCESS_PERCENT)
+}
+
+type upstreamOutcome struct {
+	at      time.Time
+	ok      bool
+	latency time.Duration
+}
+
+type upstreamRecorder struct {
+	outcomes  []upstreamOutcome
+	lastError string
+}
+
+var (
+	upstreamMutex sync.Mutex
+	upstreams     = make(map[string]*upstreamRecorder)
+)
+
+// recordUpstream 记录一次请求结果和耗时，网络错误、429 和 5xx 视为失败
+func recordUpstream(host string, failure string, latency time.Duration) {
+	if host == "" {
+		return
+	}
+	now := time.Now()
+
+	upstreamMutex.Lock()
+	defer upstreamMutex.Unlock()
+	recorder, ok := upstreams[host]
+	if !ok {
+		recorder = &upstreamRecorder{}
+		upstreams[host] = recorder
+	}
+	recorder.prune(now)
+	if len(recorder.outcomes) >= maxUpstreamOutcomes {
+		recorder.outcomes = recorder.outcomes[1:]
+	}
+	recorder.outcomes = append(recorder.outcomes, upstreamOutcome{at: now, ok: failure == "", latency: latency})
+	if failure != "" {
+		recorder.lastError = failure
+	}
+}
+
+// prune 移除统计窗口之前的结果
+func (r *upstreamRecorder) prune(now time.Time) {
+	cutoff := now.Add(-consts.UPSTREAM_WINDOW)
+	index := sort.Search(len(r.outcomes), func(i int) bool { return r.outcomes[i].at.After(cutoff) })
+	r.outcomes = r.outcomes[index:]
+}
+
+// UpstreamStats 各上游在统计窗口内的成功率，按 host 排序
+func UpstreamStats() []UpstreamStat {
+	now := time.Now()
+
+	upstreamMutex.Lock()
+	defer upstreamMutex.Unlock()
+	stats := make([]UpstreamStat, 0, len(upstreams))
+	for host, recorder := range upstreams {
+		recorder.prune(now)
+		stat := UpstreamStat{Host: host, Requests: len(recorder.outcomes), SuccessRate: 1}
+		for _, outcome := range recorder.outcomes {
+			if !outcome.ok {
+				stat.Failures++
+			}
+		}
+		if stat.Requests > 0 {
+			stat.SuccessRate = float64(stat.Requests-stat.Failures) / float64(stat.Requests)
+		}
+		if stat.Failures > 0 {
+			stat.LastError = recorder.lastError
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+// UpstreamLoad 所有上游在最近 window 内的平均耗时和失败比例，用于按上游负载调整并发
+// window 超过统计窗口时按统计窗口计算，没有请求时返回 0
+func UpstreamLoad(window time.Duration) (time.Duration, float64) {
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	upstreamMutex.Lock()
+	defer upstreamMutex.Unlock()
+	var requests, failures int
+	var latency time.Duration
+	for _, recorder := range upstreams {
+		recorder.prune(now)
+		index := sort.Search(len(recorder.outcomes), func(i int) bool { return recorder.outcomes[i].at.After(cutoff) })
+		for _, outcome := range recorder.outcomes[index:] {
+			requests++
+			latency += outcome.latency
+			if !outcome.ok {
+				failures++
+			}
+		}
+	}
+	if requests == 0 {
+		return 0, 0
+	}
+	return latency / time.Duration(requests), float64(failures) / float64(requests)
+}
+
+// trackUpstreams 在客户端上记录每个上游的请求结果和耗时
+func trackUpstreams(client *resty.Client) {
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		host := requestHost(resp.Request)
+		upstream, endpoint := upstreamName(host), endpointLabel(resp.Request)
+		upstreamDuration.WithLabelValues(upstream, endpoint).Observe(resp.Time().Seconds())
+
+		failure := ""
+		code := resp.StatusCode()
+		if code >= http.StatusInternalServerError || code == http.StatusTooManyRequests {
+			failure = resp.Status()
+		}
+		if code >= http.StatusBadRequest {
+			upstreamErrors.WithLabelValues(upstream, endpoint, strconv.Itoa(code)).Inc()
+		}
+		recordUpstream(host, failure, resp.Time())
+		return nil
+	})
+	client.OnError(func(req *resty.Request, err error) {
+		// 有响应的错误已在 OnAfterResponse 中记录
+		var responseErr *resty.ResponseError
+		if errors.As(err, &responseErr) {
+			return
+		}
+		host := requestHost(req)
+		upstream, endpoint := upstreamName(host), endpointLabel(req)
+		var latency time.Duration
+		if !req.Time.IsZero() {
+			latency = time.Since(req.Time)
+			upstreamDuration.WithLabelValues(upstream, endpoint).Observe(latency.Seconds())
+		}
+		upstreamErrors.WithLabelValues(upstream, endpoint, "network").Inc()
+		recordUpstream(host, err.Error(), latency)
+	})
+}
+
+func upstreamName(host string) string {
+	if name, ok := upstreamNames[host]; ok {
+		return name
+	}
+	return host
+}
+
+// endpointLabel 按路径模板归类请求，避免地址等参数导致标签过多
+func endpointLabel(req *resty.Request) string {
+	path := ""
+	if req != nil && req.RawRequest != nil && req.RawRequest.URL != nil {
+		path = req.RawRequest.URL.Path
+	} else if req != nil {
+		if u, err := url.Parse(req.URL); err == nil {
+			path = u.Path
+		}
+	}
+
+	segments := strings.Split(path, "/")
+	for _, template := range endpointTemplates {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if part != "%s" && part != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.ReplaceAll(template, "%s", "*")
+		}
+	}
+	return "other"
+}
+
+func hostOf(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+func requestHost(req *resty.Request) string {
+	if req == nil {
+		return ""
+	}
+	if req.RawRequest != nil && req.RawRequest.URL != nil {
+		return req.RawRequest.URL.Host
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package remote_service
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamStats(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := resty.New()
+	trackUpstreams(client)
+
+	for i := 0; i < consts.UPSTREAM_MIN_REQUESTS; i++ {
+		if i == 2 {
+			status = http.StatusBadGateway
+		}
+		_, _ = client.R().Get(server.URL)
+	}
+	// 连接失败也计入
+	_, _ = client.R().Get("http://127.0.0.1:1")
+
+	var stat UpstreamStat
+	for _, s := range UpstreamStats() {
+		if s.Host == server.Listener.Addr().String() {
+			stat = s
+		}
+	}
+	assert.Equal(t, consts.UPSTREAM_MIN_REQUESTS, stat.Requests)
+	assert.Equal(t, consts.UPSTREAM_MIN_REQUESTS-2, stat.Failures)
+	assert.Equal(t, "502 Bad Gateway", stat.LastError)
+	assert.False(t, stat.Healthy())
+
+	for _, s := range UpstreamStats() {
+		if s.Host == "127.0.0.1:1" {
+			assert.Equal(t, 1, s.Failures)
+			assert.True(t, s.Healthy(), "too few requests to judge")
+		}
+	}
+}
+
+func TestUpstreamLoad(t *testing.T) {
+	upstreamMutex.Lock()
+	saved := upstreams
+	upstreams = make(map[string]*upstreamRecorder)
+	upstreamMutex.Unlock()
+	defer func() {
+		upstreamMutex.Lock()
+		upstreams = saved
+		upstreamMutex.Unlock()
+	}()
+
+	latency, errorRate := UpstreamLoad(time.Minute)
+	assert.Zero(t, latency)
+	assert.Zero(t, errorRate)
+
+	recordUpstream("a.example.com", "", 100*time.Millisecond)
+	recordUpstream("a.example.com", "502 Bad Gateway", 500*time.Millisecond)
+	recordUpstream("b.example.com", "", 300*time.Millisecond)
+	recordUpstream("b.example.com", "", 300*time.Millisecond)
+
+	latency, errorRate = UpstreamLoad(time.Minute)
+	assert.Equal(t, 300*time.Millisecond, latency)
+	assert.Equal(t, 0.25, errorRate)
+
+	// 只统计最近 window 内的请求
+	upstreamMutex.Lock()
+	for i := range upstreams["a.example.com"].outcomes {
+		upstreams["a.example.com"].outcomes[i].at = time.Now().Add(-2 * time.Minute)
+	}
+	upstreamMutex.Unlock()
+	latency, errorRate = UpstreamLoad(time.Minute)
+	assert.Equal(t, 300*time.Millisecond, latency)
+	assert.Zero(t, errorRate)
+}
+
+func TestEndpointLabel(t *testing.T) {
+	for path, expected := range map[string]string{
+		GetHost() + "/api/v1/ai/tokens?q=pepe":                                          "/api/v1/ai/tokens",
+		GetHost() + "/api/v1/ai/tokens/0xabc/security?platform=eth":                     "/api/v1/ai/tokens/*/security",
+		getAdminHost() + AdminRankingURL:                                                "/api/v1/sort/",
+		getCoinGeckoHost() + "/api/v3/coins/ethereum/contract/0xabc/market_chart/range": "/api/v3/coins/*/contract/*/market_chart/range",
+		GetHost() + "/api/v2/unknown":                                                   "other",
+	} {
+		req := resty.New().R()
+		req.URL = path
+		assert.Equal(t, expected, endpointLabel(req), path)
+	}
+	assert.Equal(t, "gmgn", upstreamName(hostOf(GetHost())))
+	assert.Equal(t, "example.com", upstreamName("example.com"))
+}
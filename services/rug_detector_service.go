@@ -0,0 +1,133 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/producer"
+	"back_ai_gun_data/utils"
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	RugReasonLiquidityPull = "liquidity_pull"
+	RugReasonZeroVolume    = "zero_volume"
+	RugReasonFrozen        = "frozen"
+)
+
+// RugDetector 跑路盘检测配置
+type RugDetector struct {
+	LiquidityDropRatio decimal.Decimal // 两次刷新之间流动性下降比例阈值，eg 0.7 表示下降70%
+	MinLiquidity       decimal.Decimal // 上次流动性低于该值时不判定流动性撤出
+	FrozenRounds       int             // 价格连续未变动的刷新次数阈值
+}
+
+func defaultRugDetector() RugDetector {
+	return RugDetector{
+		LiquidityDropRatio: decimal.FromString(consts.RUG_LIQUIDITY_DROP_RATIO),
+		MinLiquidity:       decimal.FromString(consts.RUG_MIN_LIQUIDITY_USD),
+		FrozenRounds:       consts.RUG_FROZEN_ROUNDS,
+	}
+}
+
+// Detect 比较刷新前后的行情，返回跑路原因，正常返回空字符串
+func (d RugDetector) Detect(prev, current dto_cache.CoinMarketStats) string {
+	// 流动性撤出：上次流动性足够大，本次下降超过阈值
+	if d.LiquidityDropRatio.IsPositive() && prev.Liquidity.IsPositive() && current.Liquidity.IsValid() &&
+		!prev.Liquidity.LessThan(d.MinLiquidity) {
+		drop := prev.Liquidity.Sub(current.Liquidity).Div(prev.Liquidity)
+		if !drop.LessThan(d.LiquidityDropRatio) {
+			return RugReasonLiquidityPull
+		}
+	}
+
+	// 交易量归零：上次有交易量，本次24小时交易量为0
+	if prev.Volume24h.IsPositive() && current.Volume24h.IsValid() && current.Volume24h.IsZero() {
+		return RugReasonZeroVolume
+	}
+
+	// 价格冻结：连续多次刷新价格完全不变且没有交易量，
+	// CoinGecko / CMC 的缓存报价和冷门币也会出现价格不变，只看价格会误判
+	if d.FrozenRounds > 0 && current.UnchangedRounds >= d.FrozenRounds &&
+		current.Volume24h.IsValid() && current.Volume24h.IsZero() {
+		return RugReasonFrozen
+	}
+
+	return ""
+}
+
+// markRugged 标记跑路盘，已标记的币不重复标记
+func markRugged(token *dto_cache.IntelligenceToken, reason string) bool {
+	if token.IsRugged || reason == "" {
+		return false
+	}
+	token.IsRugged = true
+	token.RugReason = reason
+	token.RuggedAt = dto_cache.CustomTime{Time: time.Now()}
+	return true
+}
+
+// clearFrozenRug 价格冻结判定的币价格重新变动后取消标记，其余原因的标记不可恢复
+func clearFrozenRug(token *dto_cache.IntelligenceToken) bool {
+	if !token.IsRugged || token.RugReason != RugReasonFrozen || token.Stats.UnchangedRounds > 0 {
+		return false
+	}
+	token.IsRugged = false
+	token.RugReason = ""
+	token.RuggedAt = dto_cache.CustomTime{}
+	return true
+}
+
+// DemoteRuggedTokens 将跑路盘移到列表末尾，其余币保持原有顺序
+func DemoteRuggedTokens(tokens []dto_cache.IntelligenceToken) {
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return !tokens[i].IsRugged && tokens[j].IsRugged
+	})
+}
+
+// PublishableTokens 去掉跑路盘后的币，跑路盘保留在缓存中记录状态，但不展示也不预警
+func PublishableTokens(tokens []dto_cache.IntelligenceToken) []dto_cache.IntelligenceToken {
+	result := make([]dto_cache.IntelligenceToken, 0, len(tokens))
+	for _, token := range tokens {
+		if !token.IsRugged {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// publishRugEvents 发送本次新判定的跑路盘事件，ruggedPrev 为新判定的币刷新前的行情
+func publishRugEvents(ctx context.Context, intelligenceID string, tokens []dto_cache.IntelligenceToken, ruggedPrev map[string]dto_cache.CoinMarketStats) {
+	for i := range tokens {
+		token := &tokens[i]
+		prev, ok := ruggedPrev[token.GetUniqueKey()]
+		if !ok {
+			continue
+		}
+
+		event := producer.TokenEventMessage{
+			ID:                utils.GenerateUUIDV7(),
+			EventType:         producer.TokenEventRugged,
+			IntelligenceID:    intelligenceID,
+			Reason:            token.RugReason,
+			Name:              token.Name,
+			Symbol:            token.Symbol,
+			ContractAddress:   token.ContractAddress,
+			Chain:             token.Chain.Slug,
+			PreviousLiquidity: prev.Liquidity,
+			CurrentLiquidity:  token.Stats.Liquidity,
+			Volume24h:         token.Stats.Volume24h,
+			CurrentPriceUSD:   token.Stats.CurrentPriceUSD,
+			CurrentMarketCap:  token.Stats.CurrentMarketCap,
+			OccurredAt:        token.RuggedAt.Time,
+		}
+		if err := producer.SendTokenEventMessage(ctx, event); err != nil {
+			lr.E().Errorf("Failed to send rug event for %s: %v", token.GetUniqueKey(), err)
+			continue
+		}
+		lr.I().Infof("Token %s of intelligence %s marked as rugged: %s", token.GetUniqueKey(), intelligenceID, token.RugReason)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRugTestStats(liquidity, volume string, unchangedRounds int) dto_cache.CoinMarketStats {
+	return dto_cache.CoinMarketStats{
+		Liquidity:       decimal.FromString(liquidity),
+		Volume24h:       decimal.FromString(volume),
+		UnchangedRounds: unchangedRounds,
+	}
+}
+
+func TestRugDetectorDetect(t *testing.T) {
+	detector := RugDetector{
+		LiquidityDropRatio: decimal.RequireFromString("0.7"),
+		MinLiquidity:       decimal.RequireFromString("1000"),
+		FrozenRounds:       3,
+	}
+
+	tests := []struct {
+		name     string
+		prev     dto_cache.CoinMarketStats
+		current  dto_cache.CoinMarketStats
+		expected string
+	}{
+		{name: "流动性下降超过阈值", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("20000", "5000", 0), expected: RugReasonLiquidityPull},
+		{name: "流动性归零", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("0", "5000", 0), expected: RugReasonLiquidityPull},
+		{name: "流动性下降未超过阈值", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("40000", "5000", 0), expected: ""},
+		{name: "小池子不判定流动性撤出", prev: newRugTestStats("500", "5000", 0), current: newRugTestStats("10", "5000", 0), expected: ""},
+		{name: "本次没有流动性数据", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("", "5000", 0), expected: ""},
+		{name: "交易量归零", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("90000", "0", 0), expected: RugReasonZeroVolume},
+		{name: "一直没有交易量", prev: newRugTestStats("100000", "0", 0), current: newRugTestStats("90000", "0", 0), expected: ""},
+		{name: "价格冻结", prev: newRugTestStats("100000", "0", 2), current: newRugTestStats("100000", "0", 3), expected: RugReasonFrozen},
+		{name: "价格不变但有交易量", prev: newRugTestStats("100000", "5000", 2), current: newRugTestStats("100000", "5000", 3), expected: ""},
+		{name: "价格不变但没有交易量数据", prev: newRugTestStats("100000", "", 2), current: newRugTestStats("100000", "", 3), expected: ""},
+		{name: "正常波动", prev: newRugTestStats("100000", "5000", 0), current: newRugTestStats("120000", "8000", 0), expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, detector.Detect(tt.prev, tt.current))
+		})
+	}
+}
+
+func TestDemoteRuggedTokens(t *testing.T) {
+	tokens := []dto_cache.IntelligenceToken{
+		{Name: "A", IsRugged: true},
+		{Name: "B"},
+		{Name: "C", IsRugged: true},
+		{Name: "D"},
+	}
+
+	DemoteRuggedTokens(tokens)
+
+	names := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		names = append(names, token.Name)
+	}
+	assert.Equal(t, []string{"B", "D", "A", "C"}, names)
+}
+
+func TestPublishableTokens(t *testing.T) {
+	tokens := []dto_cache.IntelligenceToken{
+		{Name: "A", IsRugged: true},
+		{Name: "B"},
+		{Name: "C", IsRugged: true},
+	}
+
+	publishable := PublishableTokens(tokens)
+	assert.Len(t, publishable, 1)
+	assert.Equal(t, "B", publishable[0].Name)
+	assert.Len(t, tokens, 3)
+}
+
+func TestMarkRugged(t *testing.T) {
+	token := &dto_cache.IntelligenceToken{Name: "A"}
+
+	assert.False(t, markRugged(token, ""))
+	assert.True(t, markRugged(token, RugReasonLiquidityPull))
+	assert.False(t, markRugged(token, RugReasonFrozen))
+	assert.Equal(t, RugReasonLiquidityPull, token.RugReason)
+}
+
+func TestClearFrozenRug(t *testing.T) {
+	token := &dto_cache.IntelligenceToken{Name: "A"}
+	token.Stats.UnchangedRounds = 3
+	assert.True(t, markRugged(token, RugReasonFrozen))
+
+	// 价格仍未变动，保留标记
+	assert.False(t, clearFrozenRug(token))
+	assert.True(t, token.IsRugged)
+
+	// 价格重新变动，取消标记
+	token.Stats.UnchangedRounds = 0
+	assert.True(t, clearFrozenRug(token))
+	assert.False(t, token.IsRugged)
+	assert.Empty(t, token.RugReason)
+	assert.True(t, token.RuggedAt.IsZero())
+
+	// 其他原因的标记不取消
+	assert.True(t, markRugged(token, RugReasonLiquidityPull))
+	assert.False(t, clearFrozenRug(token))
+	assert.True(t, token.IsRugged)
+}
@@ -2,19 +2,31 @@ package services
 
 import (
 	"back_ai_gun_data/pkg/cache"
+	"back_ai_gun_data/pkg/dryrun"
 	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/metrics"
 	"back_ai_gun_data/pkg/model/dto_cache"
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
 	IntelligenceCoinCacheKeyPrefix = "dogex:intelligence:latest_entities:intelligence_id:"
 )
 
+// 缓存大小按每次写入统计，不按情报区分，避免时间序列随情报数量增长
+var (
+	tokenCacheTokens = metrics.NewHistogram("token_cache_tokens", "Tokens per intelligence cache write.",
+		[]float64{1, 3, 5, 10, 20, 50, 100, 200})
+	tokenCacheBytes = metrics.NewHistogram("token_cache_bytes", "Serialized size of each intelligence cache write.",
+		prometheus.ExponentialBuckets(1024, 4, 8))
+)
+
 //var chainName = map[string]struct{}{
 //	"Base":             {},
 //	"Polygon zkEVM":    {},
@@ -102,9 +114,47 @@ func ReadTokenCache(ctx context.Context, intelligenceID string) ([]dto_cache.Int
 	return coins, nil
 }
 
+// PurgeTokenCache 删除情报的币缓存和排序历史
+func PurgeTokenCache(ctx context.Context, intelligenceID string) error {
+	key := IntelligenceCoinCacheKeyPrefix + intelligenceID
+	if dryrun.Enabled() {
+		dryrun.Record(ctx, dryrun.Diff{Sink: dryrun.SinkCache, Op: "del", Target: key,
+			Summary: fmt.Sprintf("would purge token cache of intelligence %s", intelligenceID)})
+		return nil
+	}
+	if err := cache.Del(ctx, key, RankingHistoryKeyPrefix+intelligenceID); err != nil {
+		lr.E().Errorf("Failed to purge token cache of intelligence %s: %v", intelligenceID, err)
+		return err
+	}
+	return nil
+}
+
+// tokenKeys 缓存币的标识，按排序顺序
+func tokenKeys(coins []dto_cache.IntelligenceToken) []string {
+	keys := make([]string, 0, len(coins))
+	for i := range coins {
+		keys = append(keys, coins[i].GetUniqueKey())
+	}
+	return keys
+}
+
 func writeTokenCache(ctx context.Context, intelligenceID string, coins []dto_cache.IntelligenceToken) error {
 	key := IntelligenceCoinCacheKeyPrefix + intelligenceID
 
+	if dryrun.Enabled() {
+		before, err := ReadTokenCache(ctx, intelligenceID)
+		if err != nil {
+			return err
+		}
+		after := tokenKeys(coins)
+		dryrun.Record(ctx, dryrun.Diff{Sink: dryrun.SinkCache, Op: "set", Target: key,
+			Summary: fmt.Sprintf("would set %d tokens of intelligence %s, top3 %v", len(after), intelligenceID, after[:min(3, len(after))]),
+			Before:  tokenKeys(before),
+			After:   after,
+		})
+		return nil
+	}
+
 	dataBytes, err := json.Marshal(coins)
 	if err != nil {
 		lr.E().Error(err)
@@ -127,6 +177,8 @@ func writeTokenCache(ctx context.Context, intelligenceID string, coins []dto_cac
 		lr.E().Error(err)
 		return err
 	}
+	tokenCacheTokens.Observe(float64(len(coins)))
+	tokenCacheBytes.Observe(float64(len(dataBytes)))
 
 	return nil
 }
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/consts"
+	"back_ai_gun_data/pkg/dao"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/utils"
+	"context"
+	"strings"
+	"time"
+)
+
+// IngestNewTokens 将检测中发现的新币写入 project_chain_data
+// 按 (chain_id, contract_address) 幂等，已存在的记录不做修改；返回实际插入的条数
+func IngestNewTokens(ctx context.Context, tokens []remote.GmGnToken) (int64, error) {
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	chainIDs := resolveChainIDs(ctx, tokens)
+	rows := buildIngestionRows(tokens, chainIDs, time.Now())
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	linkIngestionEntities(ctx, rows)
+
+	inserted, err := dao.BatchCreateProjectChainData(ctx, rows)
+	if err != nil {
+		lr.E().Errorf("Failed to ingest new tokens: %v", err)
+		return 0, err
+	}
+
+	if inserted > 0 {
+		lr.I().Infof("Ingested %d/%d new tokens into project_chain_data", inserted, len(rows))
+	}
+	return inserted, nil
+}
+
+// resolveChainIDs 查询新币所在链的ID，返回小写slug到链ID的映射，查不到的链不出现在结果中
+func resolveChainIDs(ctx context.Context, tokens []remote.GmGnToken) map[string]string {
+	chainIDs := make(map[string]string)
+	resolved := make(map[string]bool)
+	for _, token := range tokens {
+		slug := strings.ToLower(token.Network)
+		if slug == "" || resolved[slug] {
+			continue
+		}
+		resolved[slug] = true
+
+		chainID, err := dao.GetChainIDBySlug(ctx, slug)
+		if err != nil {
+			lr.E().Errorf("Failed to get chain id for network %s: %v", slug, err)
+			continue
+		}
+		if chainID == "" {
+			lr.I().Infof("Chain not found for network %s, skip ingestion", slug)
+			continue
+		}
+		chainIDs[slug] = chainID
+	}
+	return chainIDs
+}
+
+// buildIngestionRows 过滤不支持的链和无效地址，按链和地址去重后构建待写入的记录
+func buildIngestionRows(tokens []remote.GmGnToken, chainIDs map[string]string, now time.Time) []*dto.ProjectChainData {
+	source := consts.SOURCE_API_GMGN
+	seen := make(map[string]bool, len(tokens))
+	rows := make([]*dto.ProjectChainData, 0, len(tokens))
+
+	for i := range tokens {
+		token := tokens[i]
+		if !token.IsSupportedChain() || token.Name == "" {
+			continue
+		}
+		if !utils.IsValidContractAddress(token.Network, token.Address) {
+			lr.I().Infof("Skip ingesting token %s with invalid address %q on %s", token.Name, token.Address, token.Network)
+			continue
+		}
+		chainID, ok := chainIDs[strings.ToLower(token.Network)]
+		if !ok {
+			continue
+		}
+
+		key := remote.PriceKey(token.Network, token.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		row := token.ToProjectChainData(chainID)
+		row.ID = utils.GenerateUUIDV7()
+		row.CreatedAt = now
+		row.UpdatedAt = now
+		row.Source = &source
+		// 入库时的行情即当前行情
+		if row.Price24Hours != nil {
+			row.MarketUpdatedAt = &now
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// linkIngestionEntities 按标准化名称匹配 project 类型的实体
+func linkIngestionEntities(ctx context.Context, rows []*dto.ProjectChainData) {
+	slugs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Name != nil {
+			slugs = append(slugs, utils.NormalizeName(*row.Name))
+		}
+	}
+
+	entities, err := dao.GetEntitiesBySlugsAndType(ctx, slugs, EntityTypeProject)
+	if err != nil {
+		// 实体关联失败不影响入库，后续由 BindAllEntities 补充
+		lr.E().Error(err)
+		return
+	}
+
+	for _, row := range rows {
+		if row.Name == nil {
+			continue
+		}
+		if entity, ok := entities[utils.NormalizeName(*row.Name)]; ok {
+			entityID := entity.ID
+			row.EntityID = &entityID
+		}
+	}
+}
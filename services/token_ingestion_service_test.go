@@ -0,0 +1,43 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/remote"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildIngestionRows(t *testing.T) {
+	lr.Init()
+
+	now := time.Now()
+	chainIDs := map[string]string{"solana": "chain-sol", "bsc": "chain-bsc"}
+	tokens := []remote.GmGnToken{
+		{Name: "Valid", Network: "solana", Address: "So11111111111111111111111111111111111111112", PriceUSD: decimal.RequireFromString("1.5")},
+		{Name: "Duplicate", Network: "Solana", Address: "So11111111111111111111111111111111111111112"},
+		{Name: "Evm", Network: "bsc", Address: "0x55d398326f99059fF775485246999027B3197955"},
+		{Name: "BadAddress", Network: "bsc", Address: "0x123"},
+		{Name: "UnknownChain", Network: "ethereum", Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"},
+		{Name: "Unsupported", Network: "tron", Address: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"},
+		{Name: "", Network: "solana", Address: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"},
+	}
+
+	rows := buildIngestionRows(tokens, chainIDs, now)
+	if !assert.Len(t, rows, 2) {
+		return
+	}
+
+	assert.Equal(t, "Valid", *rows[0].Name)
+	assert.Equal(t, "chain-sol", *rows[0].ChainID)
+	assert.NotEmpty(t, rows[0].ID)
+	assert.Equal(t, "gmgn", *rows[0].Source)
+	assert.Equal(t, now, *rows[0].MarketUpdatedAt)
+
+	assert.Equal(t, "Evm", *rows[1].Name)
+	assert.Equal(t, "chain-bsc", *rows[1].ChainID)
+	assert.Nil(t, rows[1].MarketUpdatedAt)
+	assert.NotEqual(t, rows[0].ID, rows[1].ID)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"errors"
+	"time"
+)
+
+// warningSnapshotRetryWindow 情报发布后该时间内重试使用当前价格兜底的预警快照
+// 新币的K线通常延迟收录；查不到的结果缓存 30 分钟，窗口内每个价格源最多每 30 分钟请求一次
+const warningSnapshotRetryWindow = 6 * time.Hour
+
+// ensureWarningSnapshots 为尚未记录预警快照的币设置情报发布时刻的预警价格和市值
+// 使用当前价格兜底的快照在发布后 warningSnapshotRetryWindow 内重新查询历史价格
+// publishedAt 为空时从数据库查询
+func ensureWarningSnapshots(ctx context.Context, intelligenceID string, publishedAt time.Time, tokens []dto_cache.IntelligenceToken) {
+	now := time.Now()
+	needed := false
+	for i := range tokens {
+		if tokens[i].Stats.WarningAt.IsZero() || tokens[i].Stats.WarningIsFallback {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return
+	}
+
+	if publishedAt.IsZero() {
+		publishedAt = getIntelligencePublishedAt(ctx, intelligenceID)
+	}
+	retry := !publishedAt.IsZero() && now.Sub(publishedAt) < warningSnapshotRetryWindow
+	provider := remote_service.GetHistoricalPriceProvider()
+	fallbackCount, recoveredCount := 0, 0
+	for i := range tokens {
+		switch {
+		case tokens[i].Stats.WarningAt.IsZero():
+			snapshotWarningStats(ctx, provider, &tokens[i], publishedAt, now)
+			if tokens[i].Stats.WarningIsFallback {
+				fallbackCount++
+			}
+		case tokens[i].Stats.WarningIsFallback && retry:
+			// 仍查不到时保留首次兜底的价格
+			if historicalWarningStats(ctx, provider, &tokens[i], publishedAt) {
+				recoveredCount++
+			}
+		}
+	}
+
+	if fallbackCount > 0 {
+		lr.I().Infof("Used current price as warning price for %d tokens of intelligence %s", fallbackCount, intelligenceID)
+	}
+	if recoveredCount > 0 {
+		lr.I().Infof("Replaced fallback warning price with historical price for %d tokens of intelligence %s", recoveredCount, intelligenceID)
+	}
+}
+
+// snapshotWarningStats 按发布时间查询历史价格设置预警价格和市值，查不到时使用当前价格并标记
+func snapshotWarningStats(ctx context.Context, provider remote_service.HistoricalPriceProvider, token *dto_cache.IntelligenceToken, publishedAt, now time.Time) {
+	stats := &token.Stats
+
+	// 刚发布的情报还没有历史K线，当前价格即发布时价格
+	if !publishedAt.IsZero() && now.Sub(publishedAt) < remote_service.HistoricalCandleInterval && stats.CurrentPriceUSD.IsPositive() {
+		setWarningStats(stats, stats.CurrentPriceUSD, stats.CurrentMarketCap, publishedAt, false)
+		return
+	}
+
+	if historicalWarningStats(ctx, provider, token, publishedAt) {
+		return
+	}
+
+	setWarningStats(stats, stats.CurrentPriceUSD, stats.CurrentMarketCap, now, true)
+}
+
+// historicalWarningStats 按发布时刻的历史K线设置预警价格和市值，查不到时返回 false 且不修改
+func historicalWarningStats(ctx context.Context, provider remote_service.HistoricalPriceProvider, token *dto_cache.IntelligenceToken, publishedAt time.Time) bool {
+	if publishedAt.IsZero() || provider == nil || token.ContractAddress == "" || token.Chain.Slug == "" {
+		return false
+	}
+
+	stats := &token.Stats
+	ref := remote.TokenRef{Chain: token.Chain.Slug, Address: token.ContractAddress}
+	candle, err := provider.GetOHLCV(ctx, ref, publishedAt)
+	if err != nil {
+		if !errors.Is(err, remote_service.ErrHistoricalPriceNotFound) {
+			lr.E().Errorf("Failed to get historical price for %s at %s: %v", ref.GetUniqueKey(), publishedAt.Format(time.RFC3339), err)
+		}
+		return false
+	}
+	if !candle.Close.IsPositive() {
+		return false
+	}
+
+	marketCap := candle.MarketCap
+	if !marketCap.IsPositive() {
+		// 价格源没有历史市值时按当前市值/当前价格的供应量折算
+		marketCap = stats.CurrentMarketCap.Mul(candle.Close).Div(stats.CurrentPriceUSD)
+	}
+	setWarningStats(stats, candle.Close, marketCap, publishedAt, false)
+	return true
+}
+
+func setWarningStats(stats *dto_cache.CoinMarketStats, price, marketCap decimal.Decimal, at time.Time, fallback bool) {
+	stats.WarningPriceUSD = price
+	stats.WarningMarketCap = marketCap
+	stats.WarningAt = dto_cache.CustomTime{Time: at}
+	stats.WarningIsFallback = fallback
+
+	// 预警市值变化后重新计算涨幅
+	stats.HighestIncreaseRate = decimal.Zero
+	if rate, ok := stats.CurrentMarketCap.Ratio(marketCap); ok && stats.CurrentMarketCap.IsPositive() {
+		stats.HighestIncreaseRate = rate
+	}
+}
+
+// resolvePublishedAt 优先使用消息中的发布时间，解析失败时查询数据库
+func resolvePublishedAt(ctx context.Context, data *model.IntelligenceMessage) time.Time {
+	if t := model.ParsePublishedAt(data.Data.PublishedAt); !t.IsZero() {
+		return t
+	}
+	return getIntelligencePublishedAt(ctx, data.ID)
+}
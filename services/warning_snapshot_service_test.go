@@ -0,0 +1,117 @@
+package services
+
+import (
+	"back_ai_gun_data/pkg/decimal"
+	"back_ai_gun_data/pkg/lr"
+	"back_ai_gun_data/pkg/model/dto_cache"
+	"back_ai_gun_data/pkg/model/remote"
+	"back_ai_gun_data/services/remote_service"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHistoricalPriceProvider struct {
+	candle *remote.OHLCV
+	calls  int
+}
+
+func (p *fakeHistoricalPriceProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeHistoricalPriceProvider) GetOHLCV(ctx context.Context, ref remote.TokenRef, at time.Time) (*remote.OHLCV, error) {
+	p.calls++
+	if p.candle == nil {
+		return nil, remote_service.ErrHistoricalPriceNotFound
+	}
+	return p.candle, nil
+}
+
+func newSnapshotTestToken(price, marketCap string) *dto_cache.IntelligenceToken {
+	return &dto_cache.IntelligenceToken{
+		ContractAddress: "AAA",
+		Chain:           dto_cache.ChainInfo{Slug: "solana"},
+		Stats: dto_cache.CoinMarketStats{
+			CurrentPriceUSD:  decimal.RequireFromString(price),
+			CurrentMarketCap: decimal.RequireFromString(marketCap),
+		},
+	}
+}
+
+func TestSnapshotWarningStats(t *testing.T) {
+	lr.Init()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	publishedAt := now.Add(-2 * time.Hour)
+
+	t.Run("使用发布时刻的历史价格", func(t *testing.T) {
+		provider := &fakeHistoricalPriceProvider{candle: &remote.OHLCV{Close: decimal.RequireFromString("0.5"), MarketCap: decimal.RequireFromString("500")}}
+		token := newSnapshotTestToken("2", "2000")
+
+		snapshotWarningStats(context.Background(), provider, token, publishedAt, now)
+		assert.Equal(t, "0.5", token.Stats.WarningPriceUSD.String())
+		assert.Equal(t, "500", token.Stats.WarningMarketCap.String())
+		assert.Equal(t, publishedAt, token.Stats.WarningAt.Time)
+		assert.False(t, token.Stats.WarningIsFallback)
+		assert.Equal(t, "4", token.Stats.HighestIncreaseRate.String())
+	})
+
+	t.Run("历史价格没有市值时按供应量折算", func(t *testing.T) {
+		provider := &fakeHistoricalPriceProvider{candle: &remote.OHLCV{Close: decimal.RequireFromString("0.5")}}
+		token := newSnapshotTestToken("2", "2000")
+
+		snapshotWarningStats(context.Background(), provider, token, publishedAt, now)
+		assert.Equal(t, "500", token.Stats.WarningMarketCap.String())
+	})
+
+	t.Run("查不到历史价格时使用当前价格并标记", func(t *testing.T) {
+		provider := &fakeHistoricalPriceProvider{}
+		token := newSnapshotTestToken("2", "2000")
+
+		snapshotWarningStats(context.Background(), provider, token, publishedAt, now)
+		assert.Equal(t, "2", token.Stats.WarningPriceUSD.String())
+		assert.Equal(t, now, token.Stats.WarningAt.Time)
+		assert.True(t, token.Stats.WarningIsFallback)
+	})
+
+	t.Run("刚发布的情报不查询历史价格", func(t *testing.T) {
+		provider := &fakeHistoricalPriceProvider{}
+		token := newSnapshotTestToken("2", "2000")
+
+		snapshotWarningStats(context.Background(), provider, token, now.Add(-time.Minute), now)
+		assert.Equal(t, 0, provider.calls)
+		assert.False(t, token.Stats.WarningIsFallback)
+		assert.Equal(t, "2", token.Stats.WarningPriceUSD.String())
+	})
+}
+
+func TestHistoricalWarningStatsRetry(t *testing.T) {
+	lr.Init()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	publishedAt := now.Add(-2 * time.Hour)
+	token := newSnapshotTestToken("2", "2000")
+
+	// 查不到时使用当前价格兜底
+	provider := &fakeHistoricalPriceProvider{}
+	snapshotWarningStats(context.Background(), provider, token, publishedAt, now)
+	assert.True(t, token.Stats.WarningIsFallback)
+
+	// 重试仍查不到时保留首次兜底的价格
+	token.Stats.CurrentPriceUSD = decimal.RequireFromString("4")
+	token.Stats.CurrentMarketCap = decimal.RequireFromString("4000")
+	assert.False(t, historicalWarningStats(context.Background(), provider, token, publishedAt))
+	assert.Equal(t, "2", token.Stats.WarningPriceUSD.String())
+	assert.Equal(t, now, token.Stats.WarningAt.Time)
+
+	// 查到历史价格后替换兜底价格
+	provider.candle = &remote.OHLCV{Close: decimal.RequireFromString("1"), MarketCap: decimal.RequireFromString("1000")}
+	assert.True(t, historicalWarningStats(context.Background(), provider, token, publishedAt))
+	assert.False(t, token.Stats.WarningIsFallback)
+	assert.Equal(t, "1", token.Stats.WarningPriceUSD.String())
+	assert.Equal(t, publishedAt, token.Stats.WarningAt.Time)
+	assert.Equal(t, "4", token.Stats.HighestIncreaseRate.String())
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
 	"runtime"
 	"strings"
 	"unsafe"
@@ -39,6 +40,32 @@ func NormalizeName(name string) string {
 	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
 }
 
+var (
+	evmAddressRegexp    = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	solanaAddressRegexp = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+	// sui 合约地址格式：0x包地址::模块::类型
+	suiAddressRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{1,64}::[A-Za-z_][A-Za-z0-9_]*::[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// IsValidContractAddress 校验合约地址格式，未知的链只要求非空且不含空白字符
+func IsValidContractAddress(network, address string) bool {
+	switch strings.ToLower(network) {
+	case "ethereum", "bsc", "polygon", "arbitrum", "optimism", "avalanche", "base":
+		return evmAddressRegexp.MatchString(address)
+	case "solana":
+		return solanaAddressRegexp.MatchString(address)
+	case "sui":
+		return suiAddressRegexp.MatchString(address)
+	default:
+		return address != "" && !strings.ContainsAny(address, " \t\r\n")
+	}
+}
+
+// IsEVMAddress 是否为EVM地址，EVM地址大小写不敏感，其余链（如solana base58）区分大小写
+func IsEVMAddress(address string) bool {
+	return evmAddressRegexp.MatchString(address)
+}
+
 func ToJson(v any) string {
 	b, _ := jsoniter.MarshalIndent(v, "", "  ")
 	return "\n" + string(b)